@@ -1,190 +1,529 @@
 package main
 
 import (
+	"CoinFlip/internal/admin"
+	"CoinFlip/internal/api"
 	"CoinFlip/internal/config"
 	"CoinFlip/internal/game"
+	"CoinFlip/internal/health"
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/metrics"
+	"CoinFlip/internal/risk"
 	"CoinFlip/internal/storage/postgres"
+	"CoinFlip/internal/tracing"
 	"CoinFlip/internal/ws"
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func main() {
-	cfg := config.Load()
-	ctx := context.Background()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fatal("config: invalid", logging.KeyErr, err)
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fatal("logging setup failed", logging.KeyErr, err)
+	}
+	slog.SetDefault(logger)
+	ctx := logging.WithLogger(context.Background(), logger)
+	logger.Info("config: effective", "config", cfg.Effective())
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Endpoint:    cfg.OTLPEndpoint,
+		Insecure:    cfg.OTLPInsecure,
+		ServiceName: cfg.TraceServiceName,
+		SampleRatio: cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		fatal("tracing: setup failed", logging.KeyErr, err)
+	}
+	defer func() { _ = shutdownTracing(context.Background()) }()
+	if cfg.OTLPEndpoint != "" {
+		logger.Info("tracing: otlp exporter", "endpoint", cfg.OTLPEndpoint, "sample_ratio", cfg.TraceSampleRatio)
+	}
 
 	dbPool, err := postgres.NewPool(ctx, cfg.PostgresDSN)
 	if err != nil {
-		log.Fatalf("postgres connect err=%v", err)
+		fatal("postgres: connect failed", logging.KeyErr, err)
 	}
 	defer dbPool.Close()
-	log.Println("postgres: connected")
+	logger.Info("postgres: connected")
 
 	itemsRepo := postgres.NewItemsRepo(dbPool)
 	usersRepo := postgres.NewUsersRepo(dbPool)
 	gamesRepo := postgres.NewGamesRepo(dbPool)
 	betsRepo := postgres.NewBetsRepo(dbPool)
 	seriesRepo := postgres.NewSeriesRepo(dbPool)
+	adminRepo := postgres.NewAdminRepo(dbPool)
+	tablesRepo := postgres.NewTablesRepo(dbPool)
+	duelsRepo := postgres.NewDuelsRepo(dbPool)
+	jackpotRepo := postgres.NewJackpotRepo(dbPool)
+	autoplayRepo := postgres.NewAutoplayRepo(dbPool)
+	tournamentsRepo := postgres.NewTournamentsRepo(dbPool)
 
 	nextGameID, err := gamesRepo.NextGameID(ctx)
 	if err != nil {
-		log.Fatalf("games next game id err=%v", err)
+		fatal("games: next game id failed", logging.KeyErr, err)
 	}
 
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisAddr,
-		Password: cfg.RedisPassword,
-		DB:       cfg.RedisDB,
-	})
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("redis connect err=%v", err)
+	var rdb *redis.Client
+	if cfg.AuthMode == "redis" || cfg.AuthSingleSession || cfg.TournamentsEnabled {
+		rdb = redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		})
+		rdb.AddHook(tracing.RedisHook{})
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			fatal("redis: connect failed", logging.KeyErr, err)
+		}
+		defer func() { _ = rdb.Close() }()
+		logger.Info("redis: connected")
+	}
+
+	tableRows, err := tablesRepo.ListActive(ctx)
+	if err != nil {
+		fatal("tables: list failed", logging.KeyErr, err)
+	}
+	if len(tableRows) == 0 {
+		fatal("tables: no active tables")
+	}
+
+	ids := game.NewGameIDs(nextGameID)
+	engines := make([]*game.Engine, 0, len(tableRows))
+	for _, t := range tableRows {
+		e, err := game.NewEngine(ctx, cfg, tableFromRow(t), ids)
+		if err != nil {
+			fatal("tables: engine setup failed", "table_id", t.ID, logging.KeyErr, err)
+		}
+		engines = append(engines, e)
+	}
+	tables := game.NewTables(engines...)
+	logger.Info("tables: loaded", "n", len(engines))
+
+	jackpotBalance, err := jackpotRepo.Balance(ctx)
+	if err != nil {
+		fatal("jackpot: load balance failed", logging.KeyErr, err)
+	}
+	jackpot, err := game.NewJackpot(game.JackpotRulesFromConfig(cfg), jackpotBalance)
+	if err != nil {
+		fatal("jackpot: setup failed", logging.KeyErr, err)
+	}
+	for _, e := range engines {
+		e.SetJackpot(jackpot)
+	}
+	logger.Info("jackpot: loaded", "balance", jackpotBalance)
+
+	staleDuelItems, err := duelsRepo.ExpireOpen(ctx)
+	if err != nil {
+		fatal("duels: expire open failed", logging.KeyErr, err)
+	}
+	if err := itemsRepo.UnlockItems(ctx, staleDuelItems); err != nil {
+		fatal("duels: unlock items failed", logging.KeyErr, err)
+	}
+	if len(staleDuelItems) > 0 {
+		logger.Info("duels: expired open duels from previous run", "items", len(staleDuelItems))
+	}
+
+	nextDuelID, err := duelsRepo.NextDuelID(ctx)
+	if err != nil {
+		fatal("duels: next duel id failed", logging.KeyErr, err)
+	}
+	duels, err := game.NewDuels(game.DuelRulesFromConfig(cfg), nextDuelID)
+	if err != nil {
+		fatal("duels: setup failed", logging.KeyErr, err)
+	}
+
+	autoplayRows, err := autoplayRepo.ListActive(ctx)
+	if err != nil {
+		fatal("autoplay: list active failed", logging.KeyErr, err)
+	}
+	active := make([]game.AutoplaySession, 0, len(autoplayRows))
+	for _, row := range autoplayRows {
+		active = append(active, autoplayFromRow(row))
+	}
+	autoplay, err := game.NewAutoplays(game.AutoplayRulesFromConfig(cfg), active)
+	if err != nil {
+		fatal("autoplay: setup failed", logging.KeyErr, err)
+	}
+	logger.Info("autoplay: loaded", "active", len(active))
+
+	tournaments, err := game.NewTournaments(game.TournamentRulesFromConfig(cfg))
+	if err != nil {
+		fatal("tournaments: setup failed", logging.KeyErr, err)
+	}
+	var leaderboard *ws.Leaderboard
+	if cfg.TournamentsEnabled {
+		leaderboard = ws.NewLeaderboard(rdb)
 	}
-	defer func() { _ = rdb.Close() }()
-	log.Println("redis: connected")
 
-	engine := game.NewEngine(cfg, nextGameID)
 	hub := ws.NewHub()
-	tokens := ws.NewTokenStore(rdb)
+	hub.SetCompression(cfg.WSCompression, cfg.WSCompressionMinBytes)
+
+	sessionPolicy, err := ws.ParseSessionPolicy(cfg.SessionPolicy)
+	if err != nil {
+		fatal("auth: bad session policy", logging.KeyErr, err)
+	}
+
+	var tokens *ws.TokenStore
+	var auth ws.Authenticator
+	switch cfg.AuthMode {
+	case "redis":
+		tokens = ws.NewTokenStore(rdb)
+		tokens.SetSessionPolicy(sessionPolicy, cfg.SessionMaxConcurrent, cfg.RedisTokenStaleSeconds)
+		auth = ws.NewRedisAuthenticator(tokens)
+
+	case "signed":
+		keys, err := ws.ParseSigningKeys(cfg.AuthSigningKeys)
+		if err != nil {
+			fatal("auth: bad signing keys", logging.KeyErr, err)
+		}
+		signed, err := ws.NewSignedTokens(keys, cfg.AuthActiveKid, time.Duration(cfg.AuthTokenTTLSeconds)*time.Second)
+		if err != nil {
+			fatal("auth: signed tokens setup failed", logging.KeyErr, err)
+		}
+		auth = signed
+
+	default:
+		fatal("auth: unknown mode", "mode", cfg.AuthMode)
+	}
+
+	if cfg.AuthSingleSession {
+		auth = ws.NewSingleSession(auth, rdb, time.Duration(cfg.RedisTokenStaleSeconds)*time.Second)
+	}
+	logger.Info("auth: configured",
+		"mode", cfg.AuthMode,
+		"single_session", cfg.AuthSingleSession,
+		"session_policy", string(sessionPolicy),
+		"max_sessions", sessionPolicy.Limit(cfg.SessionMaxConcurrent),
+	)
 
 	h := &ws.Handler{
 		Upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
+			CheckOrigin:       func(r *http.Request) bool { return true },
+			EnableCompression: cfg.WSCompression,
 		},
-		Engine:             engine,
+		ReadLimit:          int64(cfg.WSReadLimitBytes),
+		CompressionLevel:   cfg.WSCompressionLevel,
+		Schemas:            ws.NewSchemaValidator(cfg.WSMaxBetItems),
+		Tables:             tables,
 		Hub:                hub,
-		TokenStore:         tokens,
+		Auth:               auth,
+		SessionPolicy:      sessionPolicy,
+		MaxSessions:        cfg.SessionMaxConcurrent,
 		TokenTouchInterval: time.Duration(cfg.RedisTokenTouchSeconds) * time.Second,
+		TelegramBotToken:   cfg.TelegramBotToken,
+		TelegramAuthMaxAge: time.Duration(cfg.TelegramAuthMaxAgeSeconds) * time.Second,
+		TelegramSessionTTL: time.Duration(cfg.TelegramSessionTTLSeconds) * time.Second,
 		ItemsRepo:          itemsRepo,
 		UsersRepo:          usersRepo,
 		BetsRepo:           betsRepo,
 		SeriesRepo:         seriesRepo,
+		DuelsRepo:          duelsRepo,
+		AutoplayRepo:       autoplayRepo,
+		TournamentsRepo:    tournamentsRepo,
+		Duels:              duels,
+		Jackpot:            jackpot,
+		Autoplay:           autoplay,
+		Tournaments:        tournaments,
+		Leaderboard:        leaderboard,
+	}
+
+	for _, e := range tables.All() {
+		initialSnap := e.Snapshot()
+		if err := gamesRepo.EnsureRound(ctx, initialSnap.TableID, initialSnap.GameID, string(initialSnap.Phase), initialSnap.Hash, initialSnap.Seed); err != nil {
+			fatal("games: ensure initial round failed", "table_id", initialSnap.TableID, logging.KeyErr, err)
+		}
 	}
 
-	initialSnap := engine.Snapshot()
-	if err := gamesRepo.EnsureRound(ctx, initialSnap.GameID, string(initialSnap.Phase), initialSnap.Hash, initialSnap.Seed); err != nil {
-		log.Fatalf("games ensure initial round err=%v", err)
+	checker := health.NewChecker(5 * time.Second)
+	checker.Add("postgres", dbPool.Ping)
+	if rdb != nil {
+		checker.Add("redis", func(ctx context.Context) error { return rdb.Ping(ctx).Err() })
 	}
 
+	adminSrv := admin.NewServer(tables, hub, adminRepo, gamesRepo, betsRepo, itemsRepo, seriesRepo)
+
 	http.Handle("/ws", h)
+	http.Handle("/api/v1/", api.NewServer(gamesRepo, betsRepo, seriesRepo, jackpotRepo, usersRepo, tournamentsRepo, h, auth))
+	http.Handle("/metrics", metrics.Handler())
+	http.Handle("/admin/v1/", adminSrv)
+	http.HandleFunc("/healthz", checker.Healthz)
+	http.HandleFunc("/readyz", checker.Readyz)
+
+	var onlineInterval atomic.Int64
+	onlineInterval.Store(int64(cfg.OnlineInterval))
+
+	reloadCtx, stopReload := context.WithCancel(ctx)
+	defer stopReload()
+	go watchConfig(reloadCtx, cfg, tables, duels, jackpot, autoplay, tournaments, h.Schemas, &onlineInterval)
+
+	stopLoop := make(chan struct{})
+	loopDone := make(chan struct{})
 
 	go func() {
+		defer close(loopDone)
+
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
 
 		onlineTick := 0
 
-		for range ticker.C {
+		for {
+			select {
+			case <-ticker.C:
+			case <-stopLoop:
+				return
+			}
+
+			checker.Beat()
 			onlineTick++
 
 			if onlineTick%30 == 0 && tokens != nil {
 				n, err := tokens.CleanupStale(ctx, cfg.RedisTokenStaleSeconds)
 				if err != nil {
-					log.Printf("redis: cleanup stale err=%v", err)
+					logger.Warn("redis: cleanup stale failed", logging.KeyErr, err)
 				} else if n > 0 {
-					log.Printf("redis: cleanup stale unlocked=%d", n)
+					logger.Info("redis: cleanup stale", "unlocked", n)
 				}
 			}
 
-			online := hub.Online()
-			snapBefore := engine.Snapshot()
+			h.ExpireDuels(ctx)
 
-			if online == 0 && snapBefore.Phase == game.PhaseWaiting {
-				continue
+			if cfg.TournamentsEnabled && onlineTick%tournaments.Rules().RefreshSeconds == 0 {
+				go h.RefreshTournaments(ctx, time.Now())
 			}
 
-			phaseChanged, snap := engine.Tick(online > 0)
+			online := hub.Online()
+			metrics.OnlineUsers.Set(float64(online))
 
-			if phaseChanged {
-				switch snap.Phase {
-				case game.PhaseWaiting, game.PhaseBetting, game.PhaseGettingResult:
-					if err := gamesRepo.EnsureRound(ctx, snap.GameID, string(snap.Phase), snap.Hash, snap.Seed); err != nil {
-						log.Printf("games: ensure round err=%v", err)
-					}
-					if err := gamesRepo.SetPhase(ctx, snap.GameID, string(snap.Phase)); err != nil {
-						log.Printf("games: set phase err=%v", err)
-					}
+			for _, engine := range tables.All() {
+				tableOnline := hub.OnlineAt(engine.TableID())
+				hasPlayers := tableOnline > 0 || h.AutoplayPending(engine.TableID())
+				snapBefore := engine.Snapshot()
 
-				case game.PhaseFinished:
-					if err := gamesRepo.FinishRound(ctx, snap.GameID, string(snap.ResultSide), snap.Seed); err != nil {
-						log.Printf("games: finish round err=%v", err)
-					}
+				if !hasPlayers && snapBefore.Phase == game.PhaseWaiting {
+					continue
+				}
+
+				phaseChanged, snap := engine.Tick(hasPlayers)
+
+				if phaseChanged {
+					rctx, span := tracing.Start(ctx, "round."+string(snap.Phase), attribute.Int("game_id", snap.GameID), attribute.Int("table_id", snap.TableID))
+					rctx = logging.With(rctx, logging.KeyGameID, snap.GameID, "table_id", snap.TableID)
+					rlog := logging.FromContext(rctx)
+					jackpotSettled := true
+
+					switch snap.Phase {
+					case game.PhaseWaiting, game.PhaseBetting, game.PhaseGettingResult:
+						if err := gamesRepo.EnsureRound(rctx, snap.TableID, snap.GameID, string(snap.Phase), snap.Hash, snap.Seed); err != nil {
+							rlog.Error("games: ensure round failed", logging.KeyErr, err)
+						}
+						if err := gamesRepo.SetPhase(rctx, snap.GameID, string(snap.Phase)); err != nil {
+							rlog.Error("games: set phase failed", logging.KeyErr, err)
+						}
+
+					case game.PhaseFinished:
+						if err := gamesRepo.FinishRound(rctx, snap.GameID, string(snap.ResultSide), snap.Seed, snap.FaceNames(), snap.EdgeOdds); err != nil {
+							rlog.Error("games: finish round failed", logging.KeyErr, err)
+						}
+
+						if sr, ok := engine.SeriesResultsForGame(snap.GameID); ok {
+							for uid, res := range sr {
+								switch res.Outcome {
+								case "win":
+									if _, err := seriesRepo.MoveToAwaitingChoiceAfterWin(
+										rctx,
+										uid,
+										snap.GameID,
+										res.Side,
+										res.Wins,
+										res.Multiplier,
+										res.Claimable,
+									); err != nil {
+										rlog.Error("series: move awaiting_choice failed", logging.KeyUserID, uid, logging.KeyErr, err)
+									}
+
+								case "lose":
+									if _, err := seriesRepo.MarkLost(
+										rctx,
+										uid,
+										snap.GameID,
+										res.Side,
+										res.Wins,
+										res.Multiplier,
+									); err != nil {
+										rlog.Error("series: mark lost failed", logging.KeyUserID, uid, logging.KeyErr, err)
+									}
+
+								case "push":
+									refunded, err := seriesRepo.MarkPushed(
+										rctx,
+										uid,
+										snap.GameID,
+										res.Side,
+										res.Wins,
+										res.Multiplier,
+										res.Claimable,
+									)
+									if err != nil {
+										rlog.Error("series: mark pushed failed", logging.KeyUserID, uid, logging.KeyErr, err)
+									} else if len(refunded) > 0 {
+										if err := itemsRepo.UnlockItems(rctx, refunded); err != nil {
+											rlog.Error("items: unlock pushed series items failed", logging.KeyUserID, uid, logging.KeyErr, err)
+										}
+									}
+								}
+							}
+						}
 
-					if sr, ok := engine.SeriesResultsForGame(snap.GameID); ok {
-						for uid, res := range sr {
-							switch res.Outcome {
-							case "win":
-								if _, err := seriesRepo.MoveToAwaitingChoiceAfterWin(
-									ctx,
-									uid,
-									snap.GameID,
-									res.Side,
-									res.Wins,
-									res.Multiplier,
-									res.Claimable,
-								); err != nil {
-									log.Printf("series: move awaiting_choice err user=%d err=%v", uid, err)
+						if pr, ok := engine.PayoutForGame(snap.GameID); ok {
+							for _, st := range pr.Settlements {
+								refunded, err := betsRepo.Settle(rctx, snap.GameID, st.Mode, st.Winning, st.Pushed, st.Refund)
+								if err != nil {
+									rlog.Error("bets: settle failed", "mode", st.Mode, logging.KeyErr, err)
+									continue
+								}
+								if len(refunded) > 0 {
+									if err := itemsRepo.UnlockItems(rctx, refunded); err != nil {
+										rlog.Error("items: unlock refunded items failed", logging.KeyErr, err)
+									}
 								}
+								rlog.Info("bets: settled", "mode", st.Mode, "winning", st.Winning, "pushed", st.Pushed, "refund", st.Refund, "paid", st.Paid)
+							}
+							if n, err := betsRepo.RefundWalletBets(rctx, snap.GameID); err != nil {
+								rlog.Error("bets: wallet refund failed", logging.KeyErr, err)
+							} else if n > 0 {
+								rlog.Info("bets: wallet bets refunded", "users", n)
+							}
 
-							case "lose":
-								if _, err := seriesRepo.MarkLost(
-									ctx,
-									uid,
-									snap.GameID,
-									res.Side,
-									res.Wins,
-									res.Multiplier,
-								); err != nil {
-									log.Printf("series: mark lost err user=%d err=%v", uid, err)
+							if jr := pr.Jackpot; jr != nil && (jr.Contribution > 0 || jr.Amount > 0) {
+								winners := make([]postgres.JackpotWinRow, 0, len(jr.Winners))
+								for _, w := range jr.Winners {
+									winners = append(winners, postgres.JackpotWinRow{
+										UserID:    w.UserID,
+										Trigger:   w.Trigger,
+										StakeTon:  w.Stake,
+										AmountTon: w.Amount,
+									})
+								}
+								balance, err := jackpotRepo.Settle(rctx, snap.GameID, snap.TableID, jr.Contribution, winners)
+								if err != nil {
+									rlog.Error("jackpot: settle failed", "contribution", jr.Contribution, "amount", jr.Amount, logging.KeyErr, err)
+									jackpotSettled = false
+									if balance, err := jackpotRepo.Balance(rctx); err != nil {
+										rlog.Error("jackpot: reload balance failed", logging.KeyErr, err)
+									} else {
+										jackpot.Reset(balance)
+									}
+								} else {
+									rlog.Info("jackpot: settled", "contribution", jr.Contribution, "amount", jr.Amount, "balance", balance)
 								}
 							}
 						}
-					}
 
-					itemIDs, err := betsRepo.ItemIDsForGame(ctx, snap.GameID)
-					if err != nil {
-						log.Printf("bets: item ids for game err=%v", err)
-					} else if len(itemIDs) > 0 {
-						n, err := itemsRepo.ConsumeLockedItems(ctx, itemIDs)
+						itemIDs, err := betsRepo.ItemIDsForGame(rctx, snap.GameID)
 						if err != nil {
-							log.Printf("items: consume locked items err=%v", err)
-						} else {
-							log.Printf("items: consumed locked items game_id=%d n=%d", snap.GameID, n)
+							rlog.Error("bets: item ids for game failed", logging.KeyErr, err)
+						} else if len(itemIDs) > 0 {
+							n, err := itemsRepo.ConsumeLockedItems(rctx, itemIDs)
+							if err != nil {
+								rlog.Error("items: consume locked items failed", logging.KeyErr, err)
+							} else {
+								rlog.Info("items: consumed locked items", "n", n)
+							}
 						}
+
+						h.SettleAutoplay(rctx, snap.GameID)
+						h.ApplyBetStats(rctx)
+
 					}
 
-				}
+					if evt := ws.EventForPhase(snap); evt != nil {
+						hub.BroadcastTable(snap.TableID, evt)
+					}
 
-				if evt := ws.EventForPhase(snap); evt != nil {
-					hub.BroadcastJSON(evt)
-				}
+					if snap.Phase == game.PhaseBetting {
+						go h.RunAutoplay(logging.With(ctx, logging.KeyGameID, snap.GameID, "table_id", snap.TableID), engine)
+					}
 
-				if snap.Phase == game.PhaseFinished {
-					if sr, ok := engine.SeriesResultsForGame(snap.GameID); ok {
-						for uid, res := range sr {
-							hub.SendToUser(uid, ws.SeriesUpdate{
-								Event:      ws.EventSeriesUpdate,
-								GameID:     snap.GameID,
-								UserID:     uid,
-								Side:       res.Side,
-								Stake:      res.Stake,
-								Wins:       res.Wins,
-								Multiplier: res.Multiplier,
-								Claimable:  res.Claimable,
-								Stage:      string(res.Stage),
-								Active:     res.Active,
-								Outcome:    res.Outcome,
+					if snap.Phase == game.PhaseFinished {
+						if pr, ok := engine.PayoutForGame(snap.GameID); ok && pr.Pool != nil {
+							hub.BroadcastTable(snap.TableID, ws.PoolSettled{
+								Event:      ws.EventPoolSettled,
+								TableID:    snap.TableID,
+								GameID:     pr.GameID,
+								Hash:       pr.Hash,
+								ResultSide: string(pr.ResultSide),
+								Refunded:   pr.Refunded,
+								Pool:       *pr.Pool,
+								Results:    pr.Results,
 							})
 						}
+						if pr, ok := engine.PayoutForGame(snap.GameID); ok && pr.Jackpot != nil && jackpotSettled {
+							jr := pr.Jackpot
+							if jr.Contribution > 0 {
+								hub.BroadcastJSON(ws.JackpotUpdate{
+									Event:        ws.EventJackpotUpdate,
+									TableID:      snap.TableID,
+									GameID:       snap.GameID,
+									Contribution: jr.Contribution,
+									Balance:      jr.Balance,
+								})
+							}
+							if jr.Amount > 0 {
+								hub.BroadcastJSON(ws.JackpotWon{
+									Event:   ws.EventJackpotWon,
+									TableID: snap.TableID,
+									GameID:  snap.GameID,
+									Hash:    snap.Hash,
+									Seed:    snap.Seed,
+									Amount:  jr.Amount,
+									Balance: jr.Balance,
+									Winners: jr.Winners,
+								})
+							}
+						}
+						if sr, ok := engine.SeriesResultsForGame(snap.GameID); ok {
+							for uid, res := range sr {
+								hub.SendToUser(uid, ws.SeriesUpdate{
+									Event:      ws.EventSeriesUpdate,
+									TableID:    snap.TableID,
+									GameID:     snap.GameID,
+									UserID:     uid,
+									Side:       res.Side,
+									Stake:      res.Stake,
+									Wins:       res.Wins,
+									Multiplier: res.Multiplier,
+									Claimable:  res.Claimable,
+									Stage:      string(res.Stage),
+									Active:     res.Active,
+									Outcome:    res.Outcome,
+								})
+							}
+						}
 					}
+					span.End()
 				}
+
 			}
 
-			if online > 0 && cfg.OnlineInterval > 0 && onlineTick%cfg.OnlineInterval == 0 {
+			if every := int(onlineInterval.Load()); online > 0 && every > 0 && onlineTick%every == 0 {
 				hub.BroadcastJSON(ws.OnlineMsg{
 					Event:  ws.EventOnline,
 					Online: online,
@@ -193,7 +532,219 @@ func main() {
 		}
 	}()
 
-	log.Println("server: start addr=:8080")
-	err = http.ListenAndServe(":8080", nil)
-	log.Printf("server: stop err=%v", err)
+	srv := &http.Server{
+		Addr:        ":8080",
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("server: start", "addr", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	sigCtx, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	select {
+	case err := <-serveErr:
+		logger.Error("server: stop", logging.KeyErr, err)
+		return
+	case <-sigCtx.Done():
+	}
+	stopSignals()
+
+	logger.Info("server: shutting down")
+	checker.SetDraining()
+	h.Drain()
+	for _, e := range tables.All() {
+		e.StopBets()
+	}
+
+	var settled sync.WaitGroup
+	for _, e := range tables.All() {
+		settled.Add(1)
+		go func(e *game.Engine) {
+			defer settled.Done()
+			settleRound(ctx, e, adminSrv, time.Duration(cfg.ShutdownRoundTimeoutSeconds)*time.Second)
+		}(e)
+	}
+	settled.Wait()
+	close(stopLoop)
+	<-loopDone
+
+	hub.BroadcastJSON(ws.ServerClosing{Event: ws.EventServerClosing, ReconnectInMs: cfg.ShutdownReconnectHintMs})
+	reason := fmt.Sprintf(`{"reconnect_in_ms":%d}`, cfg.ShutdownReconnectHintMs)
+	n := hub.CloseAll(websocket.CloseServiceRestart, reason)
+	logger.Info("server: closed websocket connections", "n", n)
+
+	drainCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.ShutdownDrainTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	if err := h.Wait(drainCtx); err != nil {
+		logger.Warn("server: websocket drain incomplete", logging.KeyErr, err)
+	}
+	if err := srv.Shutdown(drainCtx); err != nil {
+		logger.Warn("server: http shutdown incomplete", logging.KeyErr, err)
+	}
+
+	logger.Info("server: stop")
+}
+
+func watchConfig(ctx context.Context, boot *config.Config, tables *game.Tables, duels *game.Duels, jackpot *game.Jackpot, autoplay *game.Autoplays, tournaments *game.Tournaments, schemas *ws.SchemaValidator, onlineInterval *atomic.Int64) {
+	lg := logging.FromContext(ctx)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	changed := make(chan struct{}, 1)
+	go config.WatchFile(ctx, boot.File, 2*time.Second, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	schemaMax := boot.WSMaxBetItems
+
+	for {
+		var source string
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			source = "sighup"
+		case <-changed:
+			source = "file"
+		}
+
+		next, err := config.Load(os.Args[1:])
+		if err != nil {
+			lg.Error("config: reload rejected", "source", source, logging.KeyErr, err)
+			continue
+		}
+		if need := next.MaxFrameBytes(); need > boot.WSReadLimitBytes {
+			lg.Error("config: reload rejected", "source", source, logging.KeyErr,
+				fmt.Errorf("item limits need %d byte frames, ws_read_limit_bytes is %d until restart", need, boot.WSReadLimitBytes))
+			continue
+		}
+		if keys := boot.RestartRequired(next); len(keys) > 0 {
+			lg.Warn("config: changes ignored until restart", "source", source, "keys", keys)
+		}
+
+		for _, engine := range tables.All() {
+			if err := engine.ScheduleConfig(next); err != nil {
+				lg.Error("config: reload rejected", "source", source, "table_id", engine.TableID(), logging.KeyErr, err)
+				continue
+			}
+			if th := risk.ThresholdsFromConfig(next); th != engine.Risk().Thresholds() {
+				if err := engine.Risk().SetThresholds(th); err != nil {
+					lg.Error("config: risk thresholds rejected", "source", source, "table_id", engine.TableID(), logging.KeyErr, err)
+				}
+			}
+		}
+		if err := duels.SetRules(game.DuelRulesFromConfig(next)); err != nil {
+			lg.Error("config: duel rules rejected", "source", source, logging.KeyErr, err)
+		}
+		if err := jackpot.SetRules(game.JackpotRulesFromConfig(next)); err != nil {
+			lg.Error("config: jackpot rules rejected", "source", source, logging.KeyErr, err)
+		}
+		if err := autoplay.SetRules(game.AutoplayRulesFromConfig(next)); err != nil {
+			lg.Error("config: autoplay rules rejected", "source", source, logging.KeyErr, err)
+		}
+		if err := tournaments.SetRules(game.TournamentRulesFromConfig(next)); err != nil {
+			lg.Error("config: tournament rules rejected", "source", source, logging.KeyErr, err)
+		}
+		if next.WSMaxBetItems > schemaMax {
+			schemaMax = next.WSMaxBetItems
+			schemas.SetMaxBetItems(schemaMax)
+		}
+		onlineInterval.Store(int64(next.OnlineInterval))
+
+		lg.Info("config: reloaded", "source", source, "config", next.Effective())
+	}
+}
+
+func settleRound(ctx context.Context, engine *game.Engine, adminSrv *admin.Server, timeout time.Duration) {
+	lg := logging.FromContext(ctx).With("table_id", engine.TableID())
+	deadline := time.Now().Add(timeout)
+
+	for {
+		snap := engine.Snapshot()
+		if snap.Phase == game.PhaseWaiting {
+			lg.Info("server: round settled", logging.KeyGameID, snap.GameID)
+			return
+		}
+
+		early := snap.Phase == game.PhaseBetting && !engine.RoundHasStakes()
+		if early || time.Now().After(deadline) {
+			res, ok, reason := adminSrv.VoidRound(ctx, engine, false)
+			if ok {
+				lg.Info("server: round voided on shutdown", logging.KeyGameID, res.GameID, "empty", early)
+				return
+			}
+			if snap.Phase != game.PhaseFinished {
+				lg.Warn("server: void on shutdown failed", logging.KeyGameID, snap.GameID, "reason", reason)
+				return
+			}
+		}
+
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func tableFromRow(t postgres.CoinflipTable) game.Table {
+	return game.Table{
+		ID:   t.ID,
+		Name: t.Name,
+		Overrides: game.TableOverrides{
+			BettingTime:           t.BettingTime,
+			TimeTillResult:        t.TimeTillResult,
+			NextGameDelay:         t.NextGameDelay,
+			SingleMultiplier:      t.SingleMultiplier,
+			SeriesFirstMultiplier: t.SeriesFirstMultiplier,
+			SeriesStepMultiplier:  t.SeriesStepMultiplier,
+			PayoutMode:            t.PayoutMode,
+			PoolRakePercent:       t.PoolRakePercent,
+			CoinCount:             t.CoinCount,
+			CoinEdgePercent:       t.CoinEdgePercent,
+			MinStakeTon:           t.MinStakeTon,
+			MaxStakeTon:           t.MaxStakeTon,
+			MaxBetItems:           t.MaxBetItems,
+			UserRoundCapTon:       t.UserRoundCapTon,
+			MaxRoundLiabilityTon:  t.MaxRoundLiabilityTon,
+			ItemTypes:             t.ItemTypes,
+		},
+	}
+}
+
+func autoplayFromRow(row postgres.AutoplaySession) game.AutoplaySession {
+	return game.AutoplaySession{
+		ID:     row.ID,
+		UserID: row.UserID,
+		Plan: game.AutoplayPlan{
+			TableID:       row.TableID,
+			Side:          row.Side,
+			Mode:          row.Mode,
+			Source:        row.Source,
+			StakeTon:      row.StakeTon,
+			ItemIDs:       row.ItemIDs,
+			Rounds:        row.Rounds,
+			StopLossTon:   row.StopLossTon,
+			TakeProfitTon: row.TakeProfitTon,
+		},
+		Progress: game.AutoplayProgress{
+			RoundsPlayed: row.RoundsPlayed,
+			WageredTon:   row.WageredTon,
+			NetTon:       row.NetTon,
+			LastGameID:   row.SettledGameID,
+		},
+		Status: row.Status,
+	}
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,418 @@
+package admin
+
+import (
+	"CoinFlip/internal/game"
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/storage/postgres"
+	"CoinFlip/internal/ws"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type Role string
+
+const (
+	RoleViewer     Role = "viewer"
+	RoleOperator   Role = "operator"
+	RoleAdmin      Role = "admin"
+	RoleSuperadmin Role = "superadmin"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer:     0,
+	RoleOperator:   1,
+	RoleAdmin:      2,
+	RoleSuperadmin: 3,
+}
+
+func (r Role) atLeast(min Role) bool {
+	have, ok := roleRank[r]
+	if !ok {
+		return false
+	}
+	return have >= roleRank[min]
+}
+
+type Server struct {
+	Tables *game.Tables
+	Hub    *ws.Hub
+
+	Admins *postgres.AdminRepo
+	Games  *postgres.GamesRepo
+	Bets   *postgres.BetsRepo
+	Items  *postgres.ItemsRepo
+	Series *postgres.SeriesRepo
+
+	mux *http.ServeMux
+}
+
+func NewServer(tables *game.Tables, hub *ws.Hub, admins *postgres.AdminRepo, games *postgres.GamesRepo, bets *postgres.BetsRepo, items *postgres.ItemsRepo, series *postgres.SeriesRepo) *Server {
+	s := &Server{
+		Tables: tables,
+		Hub:    hub,
+		Admins: admins,
+		Games:  games,
+		Bets:   bets,
+		Items:  items,
+		Series: series,
+		mux:    http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /admin/v1/status", s.require(RoleViewer, s.status))
+	s.mux.HandleFunc("GET /admin/v1/tables", s.require(RoleViewer, s.tables))
+	s.mux.HandleFunc("GET /admin/v1/exposure", s.require(RoleViewer, s.exposure))
+	s.mux.HandleFunc("POST /admin/v1/pause", s.require(RoleOperator, s.pause))
+	s.mux.HandleFunc("POST /admin/v1/resume", s.require(RoleOperator, s.resume))
+	s.mux.HandleFunc("POST /admin/v1/round/void", s.require(RoleAdmin, s.voidRound))
+	s.mux.HandleFunc("PUT /admin/v1/timings", s.require(RoleAdmin, s.setTimings))
+	s.mux.HandleFunc("POST /admin/v1/series/{user_id}/close", s.require(RoleAdmin, s.closeSeries))
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logging.Middleware(s.mux).ServeHTTP(w, r)
+}
+
+type adminKey struct{}
+
+func adminFrom(ctx context.Context) *postgres.AdminUser {
+	u, _ := ctx.Value(adminKey{}).(*postgres.AdminUser)
+	return u
+}
+
+func (s *Server) require(min Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="coinflip-admin"`)
+			writeErr(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+
+		lg := logging.FromContext(r.Context())
+
+		u, err := s.Admins.Authenticate(r.Context(), username, password)
+		if errors.Is(err, postgres.ErrInvalidCredentials) {
+			lg.Warn("admin: auth failed", "username", username)
+			writeErr(w, http.StatusUnauthorized, "invalid credentials")
+			return
+		}
+		if err != nil {
+			lg.Error("admin: auth error", "username", username, logging.KeyErr, err)
+			writeErr(w, http.StatusInternalServerError, "db error")
+			return
+		}
+
+		if !Role(u.Role).atLeast(min) {
+			lg.Warn("admin: forbidden", "admin_id", u.ID, "role", u.Role, "path", r.URL.Path)
+			writeErr(w, http.StatusForbidden, "insufficient role")
+			return
+		}
+
+		ctx := logging.With(r.Context(), "admin_id", u.ID)
+		next(w, r.WithContext(context.WithValue(ctx, adminKey{}, u)))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("admin: write response failed", logging.KeyErr, err)
+	}
+}
+
+func writeErr(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func (s *Server) logAction(ctx context.Context, action, targetTable, recordID string, oldData, newData any) {
+	u := adminFrom(ctx)
+	if u == nil {
+		return
+	}
+	if err := s.Admins.LogAction(ctx, u.ID, action, targetTable, recordID, oldData, newData); err != nil {
+		logging.FromContext(ctx).Error("admin: log action failed", "action", action, logging.KeyErr, err)
+	}
+	logging.FromContext(ctx).Info("admin: action", "username", u.Username, "action", action, "record_id", recordID)
+}
+
+func (s *Server) engine(w http.ResponseWriter, r *http.Request) (*game.Engine, bool) {
+	v := r.URL.Query().Get("table_id")
+	if v == "" {
+		return s.Tables.Default(), true
+	}
+
+	id, err := strconv.Atoi(v)
+	if err != nil || id <= 0 {
+		writeErr(w, http.StatusBadRequest, "bad table_id")
+		return nil, false
+	}
+	e, ok := s.Tables.Get(id)
+	if !ok {
+		writeErr(w, http.StatusNotFound, "unknown table")
+		return nil, false
+	}
+	return e, true
+}
+
+type statusResp struct {
+	TableID int          `json:"table_id"`
+	GameID  int          `json:"game_id"`
+	Phase   string       `json:"phase"`
+	Timer   int          `json:"timer"`
+	Paused  bool         `json:"paused"`
+	Online  int          `json:"online"`
+	Timings game.Timings `json:"timings"`
+}
+
+func (s *Server) currentStatus(e *game.Engine) statusResp {
+	snap := e.Snapshot()
+	return statusResp{
+		TableID: snap.TableID,
+		GameID:  snap.GameID,
+		Phase:   string(snap.Phase),
+		Timer:   snap.Timer,
+		Paused:  e.Paused(),
+		Online:  s.Hub.OnlineAt(snap.TableID),
+		Timings: e.Timings(),
+	}
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	e, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.currentStatus(e))
+}
+
+func (s *Server) tables(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Tables.Infos())
+}
+
+func (s *Server) exposure(w http.ResponseWriter, r *http.Request) {
+	e, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+
+	top := 50
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeErr(w, http.StatusBadRequest, "bad top")
+			return
+		}
+		top = n
+	}
+
+	writeJSON(w, http.StatusOK, e.Risk().Report(top))
+}
+
+func (s *Server) pause(w http.ResponseWriter, r *http.Request) {
+	e, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+
+	if !e.Pause() {
+		writeErr(w, http.StatusConflict, "already paused")
+		return
+	}
+
+	snap := e.Snapshot()
+	s.logAction(r.Context(), "coinflip_pause", "game_rounds", strconv.Itoa(snap.GameID), map[string]bool{"paused": false}, map[string]bool{"paused": true})
+	s.Hub.BroadcastTable(snap.TableID, ws.GameControl{Event: ws.EventGamePaused, TableID: snap.TableID, GameID: snap.GameID, Timer: snap.Timer})
+
+	writeJSON(w, http.StatusOK, s.currentStatus(e))
+}
+
+func (s *Server) resume(w http.ResponseWriter, r *http.Request) {
+	e, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+
+	if !e.Resume() {
+		writeErr(w, http.StatusConflict, "not paused")
+		return
+	}
+
+	snap := e.Snapshot()
+	s.logAction(r.Context(), "coinflip_resume", "game_rounds", strconv.Itoa(snap.GameID), map[string]bool{"paused": true}, map[string]bool{"paused": false})
+	s.Hub.BroadcastTable(snap.TableID, ws.GameControl{Event: ws.EventGameResumed, TableID: snap.TableID, GameID: snap.GameID, Timer: snap.Timer})
+
+	writeJSON(w, http.StatusOK, s.currentStatus(e))
+}
+
+func (s *Server) setTimings(w http.ResponseWriter, r *http.Request) {
+	e, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+
+	var t game.Timings
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&t); err != nil {
+		writeErr(w, http.StatusBadRequest, "bad json")
+		return
+	}
+
+	old, err := e.SetTimings(t)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.logAction(r.Context(), "coinflip_set_timings", "config", "timings", old, t)
+	writeJSON(w, http.StatusOK, s.currentStatus(e))
+}
+
+func (s *Server) voidRound(w http.ResponseWriter, r *http.Request) {
+	e, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+
+	res, ok, reason := s.VoidRound(r.Context(), e, s.Hub.OnlineAt(e.TableID()) > 0)
+	if !ok {
+		writeErr(w, http.StatusConflict, reason)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) VoidRound(ctx context.Context, e *game.Engine, hasOnline bool) (game.VoidResult, bool, string) {
+	res, ok, reason := e.VoidRound(hasOnline)
+	if !ok {
+		return res, false, reason
+	}
+	lg := logging.FromContext(ctx).With("table_id", e.TableID(), logging.KeyGameID, res.GameID)
+
+	itemIDs, idsErr := s.Bets.ItemIDsForGame(ctx, res.GameID)
+	if idsErr != nil {
+		lg.Error("admin: void item ids failed", logging.KeyErr, idsErr)
+	}
+
+	if err := s.Series.VoidRound(ctx, res.GameID); err != nil {
+		lg.Error("admin: void series failed", logging.KeyErr, err)
+	}
+	if err := s.Games.VoidRound(ctx, res.GameID); err != nil {
+		lg.Error("admin: void round failed", logging.KeyErr, err)
+	}
+	if _, err := s.Bets.RefundWalletBets(ctx, res.GameID); err != nil {
+		lg.Error("admin: void wallet refund failed", logging.KeyErr, err)
+	}
+
+	if idsErr == nil {
+		if err := s.Items.UnlockItems(ctx, itemIDs); err != nil {
+			lg.Error("admin: void unlock items failed", logging.KeyErr, err)
+		}
+	}
+
+	next := res.Next
+	if err := s.Games.EnsureRound(ctx, next.TableID, next.GameID, string(next.Phase), next.Hash, next.Seed); err != nil {
+		lg.Error("admin: ensure next round failed", "next_game_id", next.GameID, logging.KeyErr, err)
+	}
+	if err := s.Games.SetPhase(ctx, next.GameID, string(next.Phase)); err != nil {
+		lg.Error("admin: set next phase failed", "next_game_id", next.GameID, logging.KeyErr, err)
+	}
+
+	s.logAction(ctx, "coinflip_void_round", "game_rounds", strconv.Itoa(res.GameID), nil, map[string]any{
+		"refunded_users": res.Refunded,
+		"restored":       res.Restored,
+		"items_unlocked": len(itemIDs),
+		"next_game_id":   next.GameID,
+	})
+
+	s.Hub.BroadcastTable(next.TableID, ws.RoundVoided{Event: ws.EventRoundVoided, TableID: next.TableID, GameID: res.GameID, NextGameID: next.GameID})
+	if evt := ws.EventForPhase(next); evt != nil {
+		s.Hub.BroadcastTable(next.TableID, evt)
+	}
+
+	for _, uid := range res.Refunded {
+		s.Hub.SendToUser(uid, ws.SeriesStateMsg{Event: ws.EventSeriesState, TableID: e.TableID(), UserID: uid})
+	}
+	for _, ss := range res.Restored {
+		s.Hub.SendToUser(ss.UserID, ws.SeriesStateMsg{
+			Event:      ws.EventSeriesState,
+			TableID:    e.TableID(),
+			UserID:     ss.UserID,
+			Side:       ss.Side,
+			Stake:      ss.Stake,
+			Wins:       ss.Wins,
+			Multiplier: ss.Multiplier,
+			Claimable:  ss.Claimable,
+			Stage:      string(ss.Stage),
+			Active:     ss.Active,
+		})
+	}
+
+	return res, true, ""
+}
+
+func (s *Server) closeSeries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeErr(w, http.StatusBadRequest, "bad user_id")
+		return
+	}
+
+	e, ok := s.Tables.SeriesOwner(userID)
+	if !ok {
+		writeErr(w, http.StatusConflict, "no active series")
+		return
+	}
+
+	prevSS, _ := e.SeriesSnapshot(userID)
+
+	stake, mult, payout, ok, reason := e.ForceCloseSeries(userID)
+	if !ok {
+		writeErr(w, http.StatusConflict, reason)
+		return
+	}
+
+	snap := e.Snapshot()
+	sessionID, err := s.Series.ForceClose(ctx, userID, snap.GameID, payout)
+	if err != nil {
+		if prevSS != nil {
+			e.RestoreSeriesSnapshot(*prevSS)
+		}
+		logging.FromContext(ctx).Error("admin: force close series failed", logging.KeyUserID, userID, logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error: close series")
+		return
+	}
+
+	s.logAction(ctx, "coinflip_force_close_series", "series_sessions", strconv.FormatInt(sessionID, 10), prevSS, map[string]float64{
+		"stake":      stake,
+		"multiplier": mult,
+		"payout":     payout,
+	})
+
+	s.Hub.SendToUser(userID, ws.CashoutResult{
+		Event:      ws.EventCashout,
+		TableID:    e.TableID(),
+		GameID:     snap.GameID,
+		UserID:     userID,
+		Stake:      stake,
+		Multiplier: mult,
+		Payout:     payout,
+	})
+	s.Hub.SendToUser(userID, ws.SeriesStateMsg{Event: ws.EventSeriesState, TableID: e.TableID(), UserID: userID})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"user_id":    userID,
+		"series_id":  sessionID,
+		"stake":      stake,
+		"multiplier": mult,
+		"payout":     payout,
+	})
+}
@@ -0,0 +1,554 @@
+package api
+
+import (
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/rng"
+	"CoinFlip/internal/storage/postgres"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (int64, error)
+}
+
+type LiveStandings interface {
+	LiveStandings(runID int64) []postgres.TournamentResult
+}
+
+type Server struct {
+	Games       *postgres.GamesRepo
+	Bets        *postgres.BetsRepo
+	Series      *postgres.SeriesRepo
+	Jackpot     *postgres.JackpotRepo
+	Users       *postgres.UsersRepo
+	Tournaments *postgres.TournamentsRepo
+	Live        LiveStandings
+	Auth        TokenVerifier
+
+	mux *http.ServeMux
+}
+
+func NewServer(games *postgres.GamesRepo, bets *postgres.BetsRepo, series *postgres.SeriesRepo, jackpot *postgres.JackpotRepo, users *postgres.UsersRepo, tournaments *postgres.TournamentsRepo, live LiveStandings, auth TokenVerifier) *Server {
+	s := &Server{
+		Games:       games,
+		Bets:        bets,
+		Series:      series,
+		Jackpot:     jackpot,
+		Users:       users,
+		Tournaments: tournaments,
+		Live:        live,
+		Auth:        auth,
+		mux:         http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /api/v1/rounds", s.listRounds)
+	s.mux.HandleFunc("GET /api/v1/rounds/{id}", s.getRound)
+	s.mux.HandleFunc("GET /api/v1/rounds/{id}/verify", s.verifyRound)
+	s.mux.HandleFunc("GET /api/v1/me/bets", s.authed(s.listMyBets))
+	s.mux.HandleFunc("GET /api/v1/me/series", s.authed(s.listMySeries))
+	s.mux.HandleFunc("GET /api/v1/me/stats", s.authed(s.getMyStats))
+	s.mux.HandleFunc("GET /api/v1/jackpot", s.getJackpot)
+	s.mux.HandleFunc("GET /api/v1/tournaments", s.listTournaments)
+	s.mux.HandleFunc("GET /api/v1/tournaments/{id}/standings", s.getStandings)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logging.Middleware(s.mux).ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v Envelope) {
+	v.Version = Version
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("api: write response failed", logging.KeyErr, err)
+	}
+}
+
+func writeErr(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, Envelope{Error: msg})
+}
+
+type userIDKey struct{}
+
+func (s *Server) authed(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Auth == nil {
+			writeErr(w, http.StatusServiceUnavailable, "auth unavailable")
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			writeErr(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		uid, err := s.Auth.Verify(r.Context(), strings.TrimSpace(token))
+		if err != nil || uid <= 0 {
+			writeErr(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		ctx := logging.With(r.Context(), logging.KeyUserID, uid)
+		next(w, r.WithContext(context.WithValue(ctx, userIDKey{}, uid)))
+	}
+}
+
+func userIDFrom(ctx context.Context) int64 {
+	uid, _ := ctx.Value(userIDKey{}).(int64)
+	return uid
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(c string) (int64, error) {
+	if c == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, fmt.Errorf("bad cursor")
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("bad cursor")
+	}
+	return id, nil
+}
+
+func pageParams(r *http.Request) (before int64, limit int, err error) {
+	before, err = decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	limit = defaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("bad limit")
+		}
+		limit = min(n, maxPageSize)
+	}
+
+	return before, limit, nil
+}
+
+func (s *Server) listRounds(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := pageParams(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tableID := 0
+	if v := r.URL.Query().Get("table_id"); v != "" {
+		tableID, err = strconv.Atoi(v)
+		if err != nil || tableID <= 0 {
+			writeErr(w, http.StatusBadRequest, "bad table_id")
+			return
+		}
+	}
+
+	rounds, err := s.Games.ListFinished(r.Context(), tableID, before, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: list rounds failed", logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	out := make([]RoundV1, 0, len(rounds))
+	for _, rs := range rounds {
+		out = append(out, roundV1(rs))
+	}
+
+	var next string
+	if len(rounds) == limit {
+		next = encodeCursor(rounds[len(rounds)-1].GameID)
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{Data: out, NextCursor: next})
+}
+
+func (s *Server) getRound(w http.ResponseWriter, r *http.Request) {
+	gameID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || gameID <= 0 {
+		writeErr(w, http.StatusBadRequest, "bad game_id")
+		return
+	}
+
+	rs, err := s.Games.GetSummary(r.Context(), gameID)
+	if errors.Is(err, postgres.ErrNotFound) {
+		writeErr(w, http.StatusNotFound, "round not found")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: get round failed", logging.KeyGameID, gameID, logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	bets, err := s.Bets.ListByGame(r.Context(), gameID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: list round bets failed", logging.KeyGameID, gameID, logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	out := RoundDetailV1{
+		RoundV1: roundV1(*rs),
+		Bets:    make([]BetV1, 0, len(bets)),
+	}
+	for _, b := range bets {
+		out.Bets = append(out.Bets, betV1(b))
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{Data: out})
+}
+
+func (s *Server) verifyRound(w http.ResponseWriter, r *http.Request) {
+	gameID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || gameID <= 0 {
+		writeErr(w, http.StatusBadRequest, "bad game_id")
+		return
+	}
+
+	rs, err := s.Games.GetSummary(r.Context(), gameID)
+	if errors.Is(err, postgres.ErrNotFound) {
+		writeErr(w, http.StatusNotFound, "round not found")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: get round failed", logging.KeyGameID, gameID, logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+	if rs.Phase != "finished" {
+		writeErr(w, http.StatusConflict, "round not finished")
+		return
+	}
+
+	seed, err := hex.DecodeString(rs.Seed)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "round seed corrupted")
+		return
+	}
+
+	out := VerifyV1{
+		GameID:     rs.GameID,
+		Hash:       rs.Hash,
+		Seed:       rs.Seed,
+		HashOK:     rng.SHA256Hex(seed) == rs.Hash,
+		ResultSide: rs.ResultSide,
+		CoinFaces:  rs.CoinFaces,
+	}
+	if rs.EdgeOdds != nil {
+		out.EdgeOdds = *rs.EdgeOdds
+	}
+
+	if len(rs.CoinFaces) > 0 {
+		out.ExpectedFaces = rng.FacesFromSeed(seed, len(rs.CoinFaces))
+		out.Match = slices.Equal(out.ExpectedFaces, rs.CoinFaces)
+	} else {
+		out.Expected = rng.OutcomeFromSeed(seed, out.EdgeOdds)
+		out.Match = rs.ResultSide != nil && *rs.ResultSide == out.Expected
+	}
+	out.Match = out.Match && out.HashOK
+
+	writeJSON(w, http.StatusOK, Envelope{Data: out})
+}
+
+func (s *Server) listMyBets(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := pageParams(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := userIDFrom(r.Context())
+	bets, err := s.Bets.ListByUser(r.Context(), uid, before, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: list user bets failed", logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	out := make([]BetV1, 0, len(bets))
+	for _, b := range bets {
+		out = append(out, betV1(b))
+	}
+
+	var next string
+	if len(bets) == limit {
+		next = encodeCursor(bets[len(bets)-1].ID)
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{Data: out, NextCursor: next})
+}
+
+func (s *Server) listMySeries(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := pageParams(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := userIDFrom(r.Context())
+	sessions, err := s.Series.ListByUser(r.Context(), uid, before, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: list user series failed", logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	out := make([]SeriesV1, 0, len(sessions))
+	for _, ss := range sessions {
+		out = append(out, SeriesV1{
+			SeriesID:           ss.ID,
+			InitialGameID:      ss.InitialGameID,
+			Active:             ss.Active,
+			Stage:              ss.Stage,
+			CurrentSide:        ss.CurrentSide,
+			StakeTon:           ss.StakeTon,
+			Wins:               ss.Wins,
+			Multiplier:         ss.Multiplier,
+			ClaimableTon:       ss.ClaimableTon,
+			CashedOutPayoutTon: ss.CashedOutPayoutTon,
+			CreatedAt:          ss.CreatedAt,
+			ClosedAt:           ss.ClosedAt,
+		})
+	}
+
+	var next string
+	if len(sessions) == limit {
+		next = encodeCursor(sessions[len(sessions)-1].ID)
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{Data: out, NextCursor: next})
+}
+
+func (s *Server) getMyStats(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFrom(r.Context())
+
+	ps, err := s.Users.ProfileStats(r.Context(), userID)
+	if errors.Is(err, postgres.ErrNotFound) {
+		writeErr(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: profile stats failed", logging.KeyUserID, userID, logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{Data: ProfileStatsV1{
+		UserID:         ps.UserID,
+		GamesCount:     ps.GamesCount,
+		WinCount:       ps.WinCount,
+		BetsSum:        ps.BetsSum,
+		WinSum:         ps.WinSum,
+		LastActiveAt:   ps.LastActiveAt,
+		LongestSeries:  ps.LongestSeries,
+		BestMultiplier: ps.BestMultiplier,
+	}})
+}
+
+func (s *Server) getJackpot(w http.ResponseWriter, r *http.Request) {
+	_, limit, err := pageParams(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	balance, err := s.Jackpot.Balance(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: jackpot balance failed", logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	wins, err := s.Jackpot.RecentWins(r.Context(), limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: jackpot wins failed", logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	out := JackpotV1{
+		BalanceTon: balance,
+		Winners:    make([]JackpotWinV1, 0, len(wins)),
+	}
+	for _, jw := range wins {
+		out.Winners = append(out.Winners, JackpotWinV1{
+			GameID:    jw.GameID,
+			TableID:   jw.TableID,
+			UserID:    jw.UserID,
+			Trigger:   jw.Trigger,
+			StakeTon:  jw.StakeTon,
+			AmountTon: jw.AmountTon,
+			CreatedAt: jw.CreatedAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{Data: out})
+}
+
+func (s *Server) listTournaments(w http.ResponseWriter, r *http.Request) {
+	_, limit, err := pageParams(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "running" && status != "settled" {
+		writeErr(w, http.StatusBadRequest, "bad status")
+		return
+	}
+
+	runs, err := s.Tournaments.ListRuns(r.Context(), status, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: list tournaments failed", logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	out := make([]TournamentRunV1, 0, len(runs))
+	for _, run := range runs {
+		out = append(out, tournamentRunV1(run))
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{Data: out})
+}
+
+func (s *Server) getStandings(w http.ResponseWriter, r *http.Request) {
+	runID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || runID <= 0 {
+		writeErr(w, http.StatusBadRequest, "bad run_id")
+		return
+	}
+	_, limit, err := pageParams(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	run, err := s.Tournaments.GetRun(r.Context(), runID)
+	if errors.Is(err, postgres.ErrNotFound) {
+		writeErr(w, http.StatusNotFound, "tournament not found")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("api: get tournament failed", "run_id", runID, logging.KeyErr, err)
+		writeErr(w, http.StatusInternalServerError, "db error")
+		return
+	}
+
+	var results []postgres.TournamentResult
+	if run.Status == "settled" {
+		results, err = s.Tournaments.Results(r.Context(), runID, limit)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("api: tournament results failed", "run_id", runID, logging.KeyErr, err)
+			writeErr(w, http.StatusInternalServerError, "db error")
+			return
+		}
+	} else if s.Live != nil {
+		results = s.Live.LiveStandings(runID)
+		results = results[:min(limit, len(results))]
+	}
+
+	out := TournamentStandingsV1{
+		TournamentRunV1: tournamentRunV1(*run),
+		Standings:       make([]StandingV1, 0, len(results)),
+	}
+	for _, res := range results {
+		out.Standings = append(out.Standings, StandingV1{
+			Rank:           res.Rank,
+			UserID:         res.UserID,
+			Points:         res.Points,
+			VolumeTon:      res.VolumeTon,
+			LongestSeries:  res.LongestSeries,
+			BestMultiplier: res.BestMultiplier,
+			PrizeTon:       res.PrizeTon,
+			PrizeItemID:    res.PrizeItemID,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{Data: out})
+}
+
+func tournamentRunV1(run postgres.TournamentRun) TournamentRunV1 {
+	return TournamentRunV1{
+		RunID:        run.ID,
+		TournamentID: run.TournamentID,
+		Name:         run.Name,
+		Period:       run.Period,
+		Status:       run.Status,
+		StartsAt:     run.StartsAt,
+		EndsAt:       run.EndsAt,
+		PrizesTon:    run.PrizesTon,
+	}
+}
+
+func roundV1(rs postgres.RoundSummary) RoundV1 {
+	out := RoundV1{
+		GameID:         rs.GameID,
+		TableID:        rs.TableID,
+		Phase:          rs.Phase,
+		Hash:           rs.Hash,
+		ResultSide:     rs.ResultSide,
+		CoinFaces:      rs.CoinFaces,
+		EdgeOdds:       rs.EdgeOdds,
+		CreatedAt:      rs.CreatedAt,
+		FinishedAt:     rs.FinishedAt,
+		BetsCount:      rs.BetsCount,
+		TotalStakeTon:  rs.TotalStakeTon,
+		TotalPayoutTon: rs.TotalPayoutTon,
+	}
+	if rs.Phase == "finished" {
+		out.Seed = rs.Seed
+	}
+	return out
+}
+
+func betV1(b postgres.GameBet) BetV1 {
+	return BetV1{
+		BetID:           b.ID,
+		GameID:          b.GameID,
+		UserID:          b.UserID,
+		Side:            b.Side,
+		Mode:            b.Mode,
+		SeriesSessionID: b.SeriesSessionID,
+		Item: BetItemV1{
+			ItemID:   b.ItemID,
+			Type:     b.ItemType,
+			Name:     b.ItemName,
+			PhotoURL: b.ItemPhotoURL,
+		},
+		StakeTon:  b.StakeTon,
+		Status:    b.Status,
+		PayoutTon: b.PayoutTon,
+		CreatedAt: b.CreatedAt,
+		SettledAt: b.SettledAt,
+	}
+}
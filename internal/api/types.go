@@ -0,0 +1,136 @@
+package api
+
+import "time"
+
+const Version = "v1"
+
+type Envelope struct {
+	Version    string `json:"version"`
+	Data       any    `json:"data,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type RoundV1 struct {
+	GameID         int64      `json:"game_id"`
+	TableID        int        `json:"table_id"`
+	Phase          string     `json:"phase"`
+	Hash           string     `json:"hash"`
+	Seed           string     `json:"seed,omitempty"`
+	ResultSide     *string    `json:"result_side"`
+	CoinFaces      []string   `json:"coin_faces,omitempty"`
+	EdgeOdds       *int       `json:"edge_odds,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	FinishedAt     *time.Time `json:"finished_at"`
+	BetsCount      int64      `json:"bets_count"`
+	TotalStakeTon  float64    `json:"total_stake_ton"`
+	TotalPayoutTon float64    `json:"total_payout_ton"`
+}
+
+type RoundDetailV1 struct {
+	RoundV1
+	Bets []BetV1 `json:"bets"`
+}
+
+type VerifyV1 struct {
+	GameID        int64    `json:"game_id"`
+	Hash          string   `json:"hash"`
+	Seed          string   `json:"seed"`
+	HashOK        bool     `json:"hash_ok"`
+	EdgeOdds      int      `json:"edge_odds"`
+	ResultSide    *string  `json:"result_side"`
+	CoinFaces     []string `json:"coin_faces,omitempty"`
+	Expected      string   `json:"expected,omitempty"`
+	ExpectedFaces []string `json:"expected_faces,omitempty"`
+	Match         bool     `json:"match"`
+}
+
+type BetItemV1 struct {
+	ItemID   int64   `json:"item_id"`
+	Type     string  `json:"type"`
+	Name     string  `json:"name"`
+	PhotoURL *string `json:"photo_url"`
+}
+
+type BetV1 struct {
+	BetID           int64      `json:"bet_id"`
+	GameID          int64      `json:"game_id"`
+	UserID          int64      `json:"user_id"`
+	Side            string     `json:"side"`
+	Mode            string     `json:"mode"`
+	SeriesSessionID *int64     `json:"series_session_id"`
+	Item            BetItemV1  `json:"item"`
+	StakeTon        float64    `json:"stake_ton"`
+	Status          string     `json:"status"`
+	PayoutTon       float64    `json:"payout_ton"`
+	CreatedAt       time.Time  `json:"created_at"`
+	SettledAt       *time.Time `json:"settled_at"`
+}
+
+type SeriesV1 struct {
+	SeriesID           int64      `json:"series_id"`
+	InitialGameID      int64      `json:"initial_game_id"`
+	Active             bool       `json:"active"`
+	Stage              string     `json:"stage"`
+	CurrentSide        *string    `json:"current_side"`
+	StakeTon           float64    `json:"stake_ton"`
+	Wins               int        `json:"wins"`
+	Multiplier         float64    `json:"multiplier"`
+	ClaimableTon       float64    `json:"claimable_ton"`
+	CashedOutPayoutTon *float64   `json:"cashed_out_payout_ton"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ClosedAt           *time.Time `json:"closed_at"`
+}
+
+type JackpotWinV1 struct {
+	GameID    int64     `json:"game_id"`
+	TableID   int       `json:"table_id"`
+	UserID    int64     `json:"user_id"`
+	Trigger   string    `json:"trigger"`
+	StakeTon  float64   `json:"stake_ton"`
+	AmountTon float64   `json:"amount_ton"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type JackpotV1 struct {
+	BalanceTon float64        `json:"balance_ton"`
+	Winners    []JackpotWinV1 `json:"winners"`
+}
+
+type ProfileStatsV1 struct {
+	UserID         int64     `json:"user_id"`
+	GamesCount     int       `json:"games_count"`
+	WinCount       int       `json:"win_count"`
+	BetsSum        float64   `json:"bets_sum"`
+	WinSum         float64   `json:"win_sum"`
+	LastActiveAt   time.Time `json:"last_active_at"`
+	LongestSeries  int       `json:"longest_series"`
+	BestMultiplier float64   `json:"best_multiplier"`
+}
+
+type TournamentRunV1 struct {
+	RunID        int64     `json:"run_id"`
+	TournamentID int       `json:"tournament_id"`
+	Name         string    `json:"name"`
+	Period       string    `json:"period"`
+	Status       string    `json:"status"`
+	StartsAt     time.Time `json:"starts_at"`
+	EndsAt       time.Time `json:"ends_at"`
+	PrizesTon    []float64 `json:"prizes_ton"`
+}
+
+type StandingV1 struct {
+	Rank           int     `json:"rank"`
+	UserID         int64   `json:"user_id"`
+	Points         float64 `json:"points"`
+	VolumeTon      float64 `json:"volume_ton"`
+	LongestSeries  int     `json:"longest_series"`
+	BestMultiplier float64 `json:"best_multiplier"`
+	PrizeTon       float64 `json:"prize_ton"`
+	PrizeItemID    *int64  `json:"prize_item_id"`
+}
+
+type TournamentStandingsV1 struct {
+	TournamentRunV1
+	Standings []StandingV1 `json:"standings"`
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+func BetFrameBytes(items int) int {
+	return 256 + items*96
+}
+
+func AutoplayFrameBytes(items int) int {
+	return 512 + items*96
+}
+
+func (c *Config) MaxFrameBytes() int {
+	return max(BetFrameBytes(c.WSMaxBetItems), AutoplayFrameBytes(c.AutoplayMaxItems))
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.BettingTime > 0, "betting_time must be positive, got %d", c.BettingTime)
+	check(c.TimeTillResult > 0, "time_till_result must be positive, got %d", c.TimeTillResult)
+	check(c.NextGameDelay > 0, "next_game_delay must be positive, got %d", c.NextGameDelay)
+	check(c.OnlineInterval >= 0, "online_interval must not be negative, got %d", c.OnlineInterval)
+
+	check(c.SingleMultiplier > 1, "single_multiplier must be above 1, got %v", c.SingleMultiplier)
+	check(c.SeriesFirstMultiplier > 1, "series_first_multiplier must be above 1, got %v", c.SeriesFirstMultiplier)
+	check(c.SeriesStepMultiplier > 1, "series_step_multiplier must be above 1, got %v", c.SeriesStepMultiplier)
+	check(c.PayoutMode == "fixed" || c.PayoutMode == "pool", "payout_mode must be fixed or pool, got %q", c.PayoutMode)
+	check(c.PoolRakePercent >= 0 && c.PoolRakePercent < 100, "pool_rake_percent must be within [0, 100), got %v", c.PoolRakePercent)
+	check(c.CoinCount >= 1 && c.CoinCount <= 16, "coin_count must be within [1, 16], got %d", c.CoinCount)
+	check(c.CoinEdgePercent >= 0 && c.CoinEdgePercent < 50, "coin_edge_percent must be within [0, 50), got %v", c.CoinEdgePercent)
+	check(c.CoinCount == 1 || c.PayoutMode != "pool", "coin_count above 1 requires payout_mode fixed")
+	check(c.EdgeOdds >= 0, "edge_odds must not be negative, got %d", c.EdgeOdds)
+	check(c.EdgeOdds == 0 || (c.EdgeMultiplier > 1 && c.EdgeMultiplier < float64(c.EdgeOdds)),
+		"edge_multiplier must be within (1, edge_odds), got %v", c.EdgeMultiplier)
+	check(c.EdgePolicy == "push" || c.EdgePolicy == "lose", "edge_policy must be push or lose, got %q", c.EdgePolicy)
+	check(c.JackpotPercent >= 0 && c.JackpotPercent < 50, "jackpot_percent must be within [0, 50), got %v", c.JackpotPercent)
+	check(c.JackpotOdds >= 0, "jackpot_odds must not be negative, got %d", c.JackpotOdds)
+	check(c.JackpotSeriesWins >= 0, "jackpot_series_wins must not be negative, got %d", c.JackpotSeriesWins)
+
+	check(c.MinStakeTon > 0, "min_stake_ton must be positive, got %v", c.MinStakeTon)
+	check(c.MaxStakeTon == 0 || c.MaxStakeTon >= c.MinStakeTon, "max_stake_ton must be 0 (unlimited) or at least min_stake_ton, got %v", c.MaxStakeTon)
+	check(c.UserRoundCapTon == 0 || c.UserRoundCapTon >= c.MinStakeTon, "user_round_cap_ton must be 0 (unlimited) or at least min_stake_ton, got %v", c.UserRoundCapTon)
+	check(c.MaxRoundLiabilityTon >= 0, "max_round_liability_ton must not be negative, got %v", c.MaxRoundLiabilityTon)
+	check(c.CancelCutoffSeconds >= 0 && c.CancelCutoffSeconds < c.BettingTime,
+		"cancel_cutoff_seconds must be within [0, betting_time), got %d", c.CancelCutoffSeconds)
+
+	check(c.RiskAlertTon >= 0, "risk_alert_ton must not be negative, got %v", c.RiskAlertTon)
+	check(c.RiskThrottleTon >= 0, "risk_throttle_ton must not be negative, got %v", c.RiskThrottleTon)
+	check(c.RiskThrottleMaxPayoutTon >= 0, "risk_throttle_max_payout_ton must not be negative, got %v", c.RiskThrottleMaxPayoutTon)
+	check(c.RiskThrottleTon == 0 || c.RiskThrottleMaxPayoutTon > 0, "risk_throttle_max_payout_ton is required when risk_throttle_ton is set")
+	check(c.RiskRejectTon >= 0, "risk_reject_ton must not be negative, got %v", c.RiskRejectTon)
+	check(c.RiskRejectTon == 0 || c.RiskThrottleTon <= c.RiskRejectTon, "risk_throttle_ton must not exceed risk_reject_ton, got %v", c.RiskThrottleTon)
+	check(c.RiskUserMaxTon >= 0, "risk_user_max_ton must not be negative, got %v", c.RiskUserMaxTon)
+
+	check(c.DuelTTLSeconds > 0, "duel_ttl_seconds must be positive, got %d", c.DuelTTLSeconds)
+	check(c.DuelTolerancePercent >= 0 && c.DuelTolerancePercent <= 100, "duel_tolerance_percent must be within [0, 100], got %v", c.DuelTolerancePercent)
+	check(c.DuelFeePercent >= 0 && c.DuelFeePercent < 100, "duel_fee_percent must be within [0, 100), got %v", c.DuelFeePercent)
+	check(c.DuelFeeMode == "ton" || c.DuelFeeMode == "item", "duel_fee_mode must be ton or item, got %q", c.DuelFeeMode)
+	check(c.DuelMaxOpenPerUser >= 1, "duel_max_open_per_user must be at least 1, got %d", c.DuelMaxOpenPerUser)
+
+	check(c.AutoplayMaxRounds >= 0, "autoplay_max_rounds must not be negative, got %d", c.AutoplayMaxRounds)
+	check(c.AutoplayMaxItems > 0 && c.AutoplayMaxItems <= 1000, "autoplay_max_items must be within [1, 1000], got %d", c.AutoplayMaxItems)
+
+	check(c.TournamentRefreshSeconds > 0, "tournament_refresh_seconds must be positive, got %d", c.TournamentRefreshSeconds)
+	check(c.TournamentTopN >= 1 && c.TournamentTopN <= 100, "tournament_top_n must be within [1, 100], got %d", c.TournamentTopN)
+
+	check(strings.TrimSpace(c.PostgresDSN) != "", "postgres_dsn is required")
+
+	var lvl slog.Level
+	check(lvl.UnmarshalText([]byte(c.LogLevel)) == nil, "log_level %q is not one of debug, info, warn, error", c.LogLevel)
+	check(c.LogFormat == "json" || c.LogFormat == "text", "log_format must be json or text, got %q", c.LogFormat)
+	check(c.TraceSampleRatio >= 0 && c.TraceSampleRatio <= 1, "trace_sample_ratio must be within [0, 1], got %v", c.TraceSampleRatio)
+
+	check(c.RedisDB >= 0, "redis_db must not be negative, got %d", c.RedisDB)
+	check(c.RedisTokenStaleSeconds > 0, "redis_token_stale_seconds must be positive, got %d", c.RedisTokenStaleSeconds)
+	check(c.RedisTokenTouchSeconds > 0 && c.RedisTokenTouchSeconds < c.RedisTokenStaleSeconds,
+		"redis_token_touch_seconds must be positive and below redis_token_stale_seconds, got %d", c.RedisTokenTouchSeconds)
+
+	switch c.AuthMode {
+	case "redis":
+	case "signed":
+		check(c.AuthSigningKeys != "", "auth_signing_keys is required when auth_mode is signed")
+		check(c.AuthActiveKid != "", "auth_active_kid is required when auth_mode is signed")
+	default:
+		check(false, "auth_mode must be redis or signed, got %q", c.AuthMode)
+	}
+	check(c.AuthTokenTTLSeconds > 0, "auth_token_ttl_seconds must be positive, got %d", c.AuthTokenTTLSeconds)
+
+	check(c.SessionPolicy == "reject" || c.SessionPolicy == "replace" || c.SessionPolicy == "allow",
+		"session_policy must be reject, replace or allow, got %q", c.SessionPolicy)
+	check(c.SessionMaxConcurrent >= 1, "session_max_concurrent must be at least 1, got %d", c.SessionMaxConcurrent)
+
+	check(c.WSCompressionLevel >= -2 && c.WSCompressionLevel <= 9, "ws_compression_level must be within [-2, 9], got %d", c.WSCompressionLevel)
+	check(c.WSCompressionMinBytes >= 0, "ws_compression_min_bytes must not be negative, got %d", c.WSCompressionMinBytes)
+	check(c.WSReadLimitBytes >= 512, "ws_read_limit_bytes must be at least 512, got %d", c.WSReadLimitBytes)
+	check(c.WSMaxBetItems >= 1, "ws_max_bet_items must be at least 1, got %d", c.WSMaxBetItems)
+	check(c.WSReadLimitBytes >= c.MaxFrameBytes(), "ws_read_limit_bytes must fit ws_max_bet_items and autoplay_max_items (%d bytes), got %d", c.MaxFrameBytes(), c.WSReadLimitBytes)
+
+	check(c.TelegramAuthMaxAgeSeconds > 0, "telegram_auth_max_age_seconds must be positive, got %d", c.TelegramAuthMaxAgeSeconds)
+	check(c.TelegramSessionTTLSeconds > 0, "telegram_session_ttl_seconds must be positive, got %d", c.TelegramSessionTTLSeconds)
+
+	check(c.ShutdownRoundTimeoutSeconds > 0, "shutdown_round_timeout_seconds must be positive, got %d", c.ShutdownRoundTimeoutSeconds)
+	check(c.ShutdownDrainTimeoutSeconds > 0, "shutdown_drain_timeout_seconds must be positive, got %d", c.ShutdownDrainTimeoutSeconds)
+	check(c.ShutdownReconnectHintMs >= 0, "shutdown_reconnect_hint_ms must not be negative, got %d", c.ShutdownReconnectHintMs)
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateReadLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		readLimit int
+		betItems  int
+		autoItems int
+		ok        bool
+	}{
+		{"defaults", 32768, 50, 200, true},
+		{"exact fit", AutoplayFrameBytes(200), 50, 200, true},
+		{"autoplay pool too large", 8192, 50, 200, false},
+		{"bet items too large", 8192, 100, 10, false},
+		{"largest pool", AutoplayFrameBytes(1000), 50, 1000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Defaults()
+			c.WSReadLimitBytes = tt.readLimit
+			c.WSMaxBetItems = tt.betItems
+			c.AutoplayMaxItems = tt.autoItems
+
+			err := c.Validate()
+			if tt.ok && err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if !tt.ok && (err == nil || !strings.Contains(err.Error(), "ws_read_limit_bytes")) {
+				t.Fatalf("Validate = %v, want a ws_read_limit_bytes error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+func WatchFile(ctx context.Context, path string, every time.Duration, onChange func()) {
+	if path == "" {
+		return
+	}
+	if every <= 0 {
+		every = 2 * time.Second
+	}
+
+	modTime := func() time.Time {
+		st, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		return st.ModTime()
+	}
+
+	last := modTime()
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if mt := modTime(); !mt.Equal(last) {
+				last = mt
+				onChange()
+			}
+		}
+	}
+}
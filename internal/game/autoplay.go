@@ -0,0 +1,282 @@
+package game
+
+import (
+	"CoinFlip/internal/config"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	AutoplayWallet = "wallet"
+	AutoplayItems  = "items"
+)
+
+const (
+	AutoplayActive   = "active"
+	AutoplayStopped  = "stopped"
+	AutoplayFinished = "finished"
+)
+
+const (
+	AutoplayRoundsDone     = "rounds_done"
+	AutoplayStopLoss       = "stop_loss"
+	AutoplayTakeProfit     = "take_profit"
+	AutoplayUserStop       = "user_stop"
+	AutoplayFundsExhausted = "funds_exhausted"
+	AutoplayRejected       = "bet_rejected"
+)
+
+const (
+	ReasonAutoplayDisabled = "autoplay disabled"
+	ReasonAutoplayActive   = "autoplay already active"
+	ReasonAutoplayRounds   = "autoplay rounds out of range"
+	ReasonAutoplayItems    = "too many autoplay items"
+)
+
+type AutoplayRules struct {
+	MaxRounds int `json:"max_rounds"`
+	MaxItems  int `json:"max_items"`
+}
+
+func AutoplayRulesFromConfig(cfg *config.Config) AutoplayRules {
+	return AutoplayRules{
+		MaxRounds: cfg.AutoplayMaxRounds,
+		MaxItems:  cfg.AutoplayMaxItems,
+	}
+}
+
+func (r AutoplayRules) Validate() error {
+	if r.MaxRounds < 0 {
+		return fmt.Errorf("autoplay max rounds must not be negative")
+	}
+	if r.MaxItems <= 0 {
+		return fmt.Errorf("autoplay max items must be positive")
+	}
+	return nil
+}
+
+type AutoplayPlan struct {
+	TableID       int     `json:"table_id"`
+	Side          string  `json:"side"`
+	Mode          string  `json:"mode"`
+	Source        string  `json:"source"`
+	StakeTon      float64 `json:"stake_ton"`
+	ItemIDs       []int   `json:"item_ids,omitempty"`
+	Rounds        int     `json:"rounds"`
+	StopLossTon   float64 `json:"stop_loss_ton"`
+	TakeProfitTon float64 `json:"take_profit_ton"`
+}
+
+func (p AutoplayPlan) Validate(r AutoplayRules) string {
+	if r.MaxRounds == 0 {
+		return ReasonAutoplayDisabled
+	}
+	if p.Rounds <= 0 || p.Rounds > r.MaxRounds {
+		return ReasonAutoplayRounds
+	}
+	if p.Mode == ModeSeries {
+		return "bad mode"
+	}
+	if p.StakeTon < 0 || p.StopLossTon < 0 || p.TakeProfitTon < 0 {
+		return "bad autoplay limits"
+	}
+
+	switch p.Source {
+	case AutoplayWallet:
+		if p.StakeTon <= 0 {
+			return ReasonStakeBelowMin
+		}
+		if len(p.ItemIDs) > 0 {
+			return "wallet autoplay takes no items"
+		}
+	case AutoplayItems:
+		if len(p.ItemIDs) == 0 {
+			return "empty item_ids"
+		}
+		if len(p.ItemIDs) > r.MaxItems {
+			return ReasonAutoplayItems
+		}
+	default:
+		return "bad autoplay source"
+	}
+	return ""
+}
+
+func (p AutoplayPlan) StopReason(pr AutoplayProgress) string {
+	switch {
+	case pr.RoundsPlayed >= p.Rounds:
+		return AutoplayRoundsDone
+	case p.StopLossTon > 0 && pr.NetTon <= -p.StopLossTon:
+		return AutoplayStopLoss
+	case p.TakeProfitTon > 0 && pr.NetTon >= p.TakeProfitTon:
+		return AutoplayTakeProfit
+	default:
+		return ""
+	}
+}
+
+func (p AutoplayPlan) PickItems(available []ItemRef, maxItems int) []ItemRef {
+	out := make([]ItemRef, 0, 1)
+	stake := 0.0
+	for _, it := range available {
+		if len(out) >= maxItems || (len(out) > 0 && stake >= p.StakeTon) {
+			break
+		}
+		out = append(out, it)
+		stake += it.CostTon
+	}
+	return out
+}
+
+type AutoplayProgress struct {
+	RoundsPlayed int     `json:"rounds_played"`
+	WageredTon   float64 `json:"wagered_ton"`
+	NetTon       float64 `json:"net_ton"`
+	LastGameID   int     `json:"last_game_id,omitempty"`
+}
+
+type AutoplaySession struct {
+	ID         int64            `json:"session_id"`
+	UserID     int64            `json:"user_id"`
+	Plan       AutoplayPlan     `json:"plan"`
+	Progress   AutoplayProgress `json:"progress"`
+	Status     string           `json:"status"`
+	StopReason string           `json:"stop_reason,omitempty"`
+}
+
+func AutoplayStatusFor(reason string) string {
+	if reason == AutoplayRoundsDone {
+		return AutoplayFinished
+	}
+	return AutoplayStopped
+}
+
+func AutoplayFatal(reason string) bool {
+	switch reason {
+	case ReasonStakeBelowMin, ReasonStakeAboveMax, ReasonTooManyItems, ReasonItemType, "bad mode", "bad side":
+		return true
+	default:
+		return false
+	}
+}
+
+type Autoplays struct {
+	mu       sync.Mutex
+	rules    AutoplayRules
+	sessions map[int64]*AutoplaySession
+}
+
+func NewAutoplays(rules AutoplayRules, active []AutoplaySession) (*Autoplays, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	a := &Autoplays{
+		rules:    rules,
+		sessions: make(map[int64]*AutoplaySession, len(active)),
+	}
+	for _, s := range active {
+		a.sessions[s.UserID] = &s
+	}
+	return a, nil
+}
+
+func (a *Autoplays) Rules() AutoplayRules {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.rules
+}
+
+func (a *Autoplays) SetRules(r AutoplayRules) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.rules = r
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *Autoplays) Get(userID int64) (AutoplaySession, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, ok := a.sessions[userID]
+	if !ok {
+		return AutoplaySession{}, false
+	}
+	return *s, true
+}
+
+func (a *Autoplays) Add(s AutoplaySession) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.sessions[s.UserID]; ok {
+		return false
+	}
+	a.sessions[s.UserID] = &s
+	return true
+}
+
+func (a *Autoplays) Remove(userID int64) (AutoplaySession, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, ok := a.sessions[userID]
+	if !ok {
+		return AutoplaySession{}, false
+	}
+	delete(a.sessions, userID)
+	return *s, true
+}
+
+func (a *Autoplays) Pending(tableID int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, s := range a.sessions {
+		if s.Plan.TableID == tableID {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Autoplays) Due(tableID, gameID int) []AutoplaySession {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var out []AutoplaySession
+	for _, s := range a.sessions {
+		if s.Plan.TableID != tableID || s.Progress.LastGameID >= gameID {
+			continue
+		}
+		s.Progress.LastGameID = gameID
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (a *Autoplays) Record(sessionID int64, pr AutoplayProgress) (AutoplaySession, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for uid, s := range a.sessions {
+		if s.ID != sessionID {
+			continue
+		}
+		pr.LastGameID = max(pr.LastGameID, s.Progress.LastGameID)
+		s.Progress = pr
+		if reason := s.Plan.StopReason(pr); reason != "" {
+			s.Status = AutoplayStatusFor(reason)
+			s.StopReason = reason
+			delete(a.sessions, uid)
+		}
+		return *s, true
+	}
+	return AutoplaySession{}, false
+}
@@ -0,0 +1,89 @@
+package game
+
+import "testing"
+
+func TestAutoplayStopReason(t *testing.T) {
+	plan := AutoplayPlan{Rounds: 10, StopLossTon: 5, TakeProfitTon: 3}
+
+	tests := []struct {
+		name string
+		plan AutoplayPlan
+		pr   AutoplayProgress
+		want string
+	}{
+		{"keep playing", plan, AutoplayProgress{RoundsPlayed: 3, NetTon: -1}, ""},
+		{"rounds done", plan, AutoplayProgress{RoundsPlayed: 10, NetTon: 1}, AutoplayRoundsDone},
+		{"rounds done before limits", plan, AutoplayProgress{RoundsPlayed: 10, NetTon: -6}, AutoplayRoundsDone},
+		{"stop loss", plan, AutoplayProgress{RoundsPlayed: 4, NetTon: -5}, AutoplayStopLoss},
+		{"take profit", plan, AutoplayProgress{RoundsPlayed: 4, NetTon: 3}, AutoplayTakeProfit},
+		{"no limits", AutoplayPlan{Rounds: 10}, AutoplayProgress{RoundsPlayed: 4, NetTon: -100}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.plan.StopReason(tt.pr); got != tt.want {
+				t.Fatalf("StopReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoplayPickItems(t *testing.T) {
+	tests := []struct {
+		name      string
+		stake     float64
+		available []ItemRef
+		maxItems  int
+		want      int
+	}{
+		{"none available", 2, nil, 5, 0},
+		{"covers stake", 2, testItems(1, 1, 1), 5, 2},
+		{"single item above stake", 2, testItems(3, 1), 5, 1},
+		{"capped by max items", 5, testItems(1, 1, 1), 2, 2},
+		{"short of stake", 10, testItems(1, 1), 5, 2},
+		{"zero stake takes one", 0, testItems(1, 1), 5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AutoplayPlan{StakeTon: tt.stake}.PickItems(tt.available, tt.maxItems)
+			if len(got) != tt.want {
+				t.Fatalf("PickItems = %d items, want %d", len(got), tt.want)
+			}
+			for i := range got {
+				if got[i].ItemID != tt.available[i].ItemID {
+					t.Fatalf("PickItems[%d] = item %s, want %s", i, got[i].ItemID, tt.available[i].ItemID)
+				}
+			}
+		})
+	}
+}
+
+func TestAutoplayPlanValidate(t *testing.T) {
+	rules := AutoplayRules{MaxRounds: 100, MaxItems: 3}
+
+	tests := []struct {
+		name  string
+		rules AutoplayRules
+		plan  AutoplayPlan
+		want  string
+	}{
+		{"wallet", rules, AutoplayPlan{Source: AutoplayWallet, StakeTon: 1, Rounds: 50}, ""},
+		{"items", rules, AutoplayPlan{Source: AutoplayItems, ItemIDs: []int{1, 2, 3}, Rounds: 1}, ""},
+		{"disabled", AutoplayRules{MaxItems: 3}, AutoplayPlan{Source: AutoplayWallet, StakeTon: 1, Rounds: 1}, ReasonAutoplayDisabled},
+		{"too many rounds", rules, AutoplayPlan{Source: AutoplayWallet, StakeTon: 1, Rounds: 101}, ReasonAutoplayRounds},
+		{"no rounds", rules, AutoplayPlan{Source: AutoplayWallet, StakeTon: 1}, ReasonAutoplayRounds},
+		{"series", rules, AutoplayPlan{Source: AutoplayWallet, Mode: ModeSeries, StakeTon: 1, Rounds: 1}, "bad mode"},
+		{"negative limit", rules, AutoplayPlan{Source: AutoplayWallet, StakeTon: 1, Rounds: 1, StopLossTon: -1}, "bad autoplay limits"},
+		{"wallet without stake", rules, AutoplayPlan{Source: AutoplayWallet, Rounds: 1}, ReasonStakeBelowMin},
+		{"wallet with items", rules, AutoplayPlan{Source: AutoplayWallet, StakeTon: 1, ItemIDs: []int{1}, Rounds: 1}, "wallet autoplay takes no items"},
+		{"empty item pool", rules, AutoplayPlan{Source: AutoplayItems, Rounds: 1}, "empty item_ids"},
+		{"item pool over limit", rules, AutoplayPlan{Source: AutoplayItems, ItemIDs: []int{1, 2, 3, 4}, Rounds: 1}, ReasonAutoplayItems},
+		{"unknown source", rules, AutoplayPlan{Source: "credit", Rounds: 1}, "bad autoplay source"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.plan.Validate(tt.rules); got != tt.want {
+				t.Fatalf("Validate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
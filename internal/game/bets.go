@@ -164,3 +164,55 @@ func (s *BetStore) Reset(gameID int) {
 	delete(s.nextBetID, gameID)
 	s.mu.Unlock()
 }
+
+func (s *BetStore) UserStake(gameID int, userID int64) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ub := s.bets[gameID][userID]
+	if ub == nil {
+		return 0
+	}
+
+	total := 0.0
+	for _, b := range ub.Bets {
+		total += betStakeValue(b)
+	}
+	return total
+}
+
+func (s *BetStore) UserSide(gameID int, userID int64) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ub := s.bets[gameID][userID]
+	if ub == nil || len(ub.Bets) == 0 {
+		return ""
+	}
+	return ub.Bets[0].Side
+}
+
+func (s *BetStore) RemoveUser(gameID int, userID int64, mode string) []BetSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.bets[gameID]
+	if m == nil || m[userID] == nil {
+		return nil
+	}
+
+	var removed []BetSnapshot
+	kept := m[userID].Bets[:0]
+	for _, b := range m[userID].Bets {
+		if b.Mode == mode {
+			removed = append(removed, b)
+		} else {
+			kept = append(kept, b)
+		}
+	}
+	m[userID].Bets = kept
+	if len(kept) == 0 {
+		delete(m, userID)
+	}
+	return removed
+}
@@ -0,0 +1,92 @@
+package game
+
+import "CoinFlip/internal/logging"
+
+type CancelledBet struct {
+	GameID int            `json:"game_id"`
+	UserID int64          `json:"user_id"`
+	Side   string         `json:"side"`
+	Mode   string         `json:"mode"`
+	Stake  float64        `json:"stake"`
+	Items  []ItemRef      `json:"items"`
+	Series SeriesSnapshot `json:"-"`
+
+	bets []BetSnapshot
+}
+
+func (e *Engine) CancelBet(userID int64) (CancelledBet, bool, string) {
+	if userID == 0 {
+		return CancelledBet{}, false, "bad user_id"
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.paused {
+		return CancelledBet{}, false, "game paused"
+	}
+	if e.phase != PhaseBetting || e.timer <= 0 {
+		return CancelledBet{}, false, "betting closed"
+	}
+	if e.timer <= e.limits.CancelCutoffSeconds {
+		return CancelledBet{}, false, ReasonCancelCutoff
+	}
+
+	out, ok, reason := CancelledBet{}, false, "no bet in current round"
+	for _, m := range e.modes {
+		var r string
+		out, ok, r = m.CancelBet(e, userID)
+		if ok {
+			break
+		}
+		if r != "no bet in current round" {
+			reason = r
+		}
+	}
+	if !ok {
+		return CancelledBet{}, false, reason
+	}
+	e.publishExposureLocked()
+
+	e.log.Info("bet cancelled", logging.KeyGameID, out.GameID, logging.KeyUserID, userID, "mode", out.Mode, "items", len(out.Items), "stake", out.Stake)
+	return out, true, ""
+}
+
+func (e *Engine) RestoreCancelledBet(c CancelledBet) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if c.GameID != e.gameID || e.phase != PhaseBetting {
+		e.log.Warn("cancelled bet not restored, round moved on", logging.KeyGameID, c.GameID, logging.KeyUserID, c.UserID)
+		return false
+	}
+
+	for _, b := range c.bets {
+		e.bets.Add(c.GameID, c.UserID, b.Side, b.Mode, []ItemRef{itemRefFromBet(b)})
+	}
+	if c.Mode == ModeSeries {
+		ss := c.Series
+		e.series[c.UserID] = &SeriesState{
+			UserID:      ss.UserID,
+			Side:        ss.Side,
+			Stake:       ss.Stake,
+			Wins:        ss.Wins,
+			Multiplier:  ss.Multiplier,
+			Stage:       ss.Stage,
+			RoundGameID: ss.RoundGameID,
+			Active:      ss.Active,
+		}
+	}
+	e.publishExposureLocked()
+	return true
+}
+
+func itemRefFromBet(b BetSnapshot) ItemRef {
+	return ItemRef{
+		Type:     b.BetItem.Type,
+		ItemID:   b.BetItem.ItemID,
+		Name:     b.BetItem.Name,
+		PhotoURL: b.BetItem.PhotoURL,
+		CostTon:  b.BetItem.CostTon,
+	}
+}
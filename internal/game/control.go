@@ -0,0 +1,337 @@
+package game
+
+import (
+	"CoinFlip/internal/config"
+	"CoinFlip/internal/logging"
+	"fmt"
+)
+
+type Timings struct {
+	BettingTime    int `json:"betting_time"`
+	TimeTillResult int `json:"time_till_result"`
+	NextGameDelay  int `json:"next_game_delay"`
+}
+
+func (t Timings) Validate() error {
+	if t.BettingTime <= 0 {
+		return fmt.Errorf("betting_time must be positive")
+	}
+	if t.TimeTillResult <= 0 {
+		return fmt.Errorf("time_till_result must be positive")
+	}
+	if t.NextGameDelay <= 0 {
+		return fmt.Errorf("next_game_delay must be positive")
+	}
+	return nil
+}
+
+type Tunables struct {
+	Timings               Timings   `json:"timings"`
+	SingleMultiplier      float64   `json:"single_multiplier"`
+	SeriesFirstMultiplier float64   `json:"series_first_multiplier"`
+	SeriesStepMultiplier  float64   `json:"series_step_multiplier"`
+	PayoutMode            string    `json:"payout_mode"`
+	PoolRakePercent       float64   `json:"pool_rake_percent"`
+	CoinCount             int       `json:"coin_count"`
+	CoinEdgePercent       float64   `json:"coin_edge_percent"`
+	Edge                  EdgeRules `json:"edge"`
+	Limits                Limits    `json:"limits"`
+}
+
+func TunablesFromConfig(cfg *config.Config) Tunables {
+	return Tunables{
+		Timings: Timings{
+			BettingTime:    cfg.BettingTime,
+			TimeTillResult: cfg.TimeTillResult,
+			NextGameDelay:  cfg.NextGameDelay,
+		},
+		SingleMultiplier:      cfg.SingleMultiplier,
+		SeriesFirstMultiplier: cfg.SeriesFirstMultiplier,
+		SeriesStepMultiplier:  cfg.SeriesStepMultiplier,
+		PayoutMode:            cfg.PayoutMode,
+		PoolRakePercent:       cfg.PoolRakePercent,
+		CoinCount:             cfg.CoinCount,
+		CoinEdgePercent:       cfg.CoinEdgePercent,
+		Edge: EdgeRules{
+			Odds:       cfg.EdgeOdds,
+			Multiplier: cfg.EdgeMultiplier,
+			Policy:     cfg.EdgePolicy,
+		},
+		Limits: Limits{
+			MinStakeTon:          cfg.MinStakeTon,
+			MaxStakeTon:          cfg.MaxStakeTon,
+			MaxBetItems:          cfg.WSMaxBetItems,
+			UserRoundCapTon:      cfg.UserRoundCapTon,
+			MaxRoundLiabilityTon: cfg.MaxRoundLiabilityTon,
+			CancelCutoffSeconds:  cfg.CancelCutoffSeconds,
+		},
+	}
+}
+
+func (t Tunables) Validate() error {
+	if err := t.Timings.Validate(); err != nil {
+		return err
+	}
+	if t.SingleMultiplier <= 1 || t.SeriesFirstMultiplier <= 1 || t.SeriesStepMultiplier <= 1 {
+		return fmt.Errorf("multipliers must be above 1")
+	}
+	if t.PayoutMode != PayoutFixed && t.PayoutMode != PayoutPool {
+		return fmt.Errorf("payout_mode must be fixed or pool")
+	}
+	if t.PoolRakePercent < 0 || t.PoolRakePercent >= 100 {
+		return fmt.Errorf("pool_rake_percent must be within [0, 100)")
+	}
+	if t.CoinCount < 1 || t.CoinCount > MaxCoins {
+		return fmt.Errorf("coin_count must be within [1, %d]", MaxCoins)
+	}
+	if t.CoinEdgePercent < 0 || t.CoinEdgePercent >= 50 {
+		return fmt.Errorf("coin_edge_percent must be within [0, 50)")
+	}
+	if t.CoinCount > 1 && t.PayoutMode == PayoutPool {
+		return fmt.Errorf("coin_count above 1 requires payout_mode fixed")
+	}
+	if err := t.Edge.Validate(); err != nil {
+		return err
+	}
+	return t.Limits.Validate()
+}
+
+type VoidResult struct {
+	GameID   int              `json:"game_id"`
+	Refunded []int64          `json:"refunded"`
+	Restored []SeriesSnapshot `json:"restored"`
+	Next     Snapshot         `json:"-"`
+}
+
+func (e *Engine) Pause() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.paused {
+		return false
+	}
+	e.paused = true
+	e.log.Info("game paused", logging.KeyGameID, e.gameID, "phase", e.phase, "timer", e.timer)
+	return true
+}
+
+func (e *Engine) Resume() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.paused {
+		return false
+	}
+	e.paused = false
+	e.log.Info("game resumed", logging.KeyGameID, e.gameID, "phase", e.phase, "timer", e.timer)
+	return true
+}
+
+func (e *Engine) StopBets() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stopped {
+		return
+	}
+	e.stopped = true
+	e.log.Info("bets stopped", logging.KeyGameID, e.gameID, "phase", e.phase, "timer", e.timer)
+}
+
+func (e *Engine) BetsStopped() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.stopped
+}
+
+func (e *Engine) RoundHasStakes() bool {
+	e.mu.RLock()
+	gid := e.gameID
+	for _, s := range e.series {
+		if s != nil && s.Active && s.Stage == SeriesStageInRound && s.RoundGameID == gid {
+			e.mu.RUnlock()
+			return true
+		}
+	}
+	e.mu.RUnlock()
+
+	return len(e.bets.Snapshot(gid)) > 0
+}
+
+func (e *Engine) Paused() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.paused
+}
+
+func (e *Engine) Timings() Timings {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.timings
+}
+
+func (e *Engine) SetTimings(t Timings) (Timings, error) {
+	if err := t.Validate(); err != nil {
+		return Timings{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	old := e.timings
+	e.timings = t
+	e.log.Info("timings changed", "betting", t.BettingTime, "till_result", t.TimeTillResult, "next_delay", t.NextGameDelay)
+	return old, nil
+}
+
+func (e *Engine) ScheduleTunables(t Tunables) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pending = &t
+	e.log.Info("tunables scheduled for next round", logging.KeyGameID, e.gameID, "phase", e.phase)
+	return nil
+}
+
+func (e *Engine) applyTunablesLocked() {
+	if e.pending == nil {
+		return
+	}
+	t := *e.pending
+	e.pending = nil
+
+	e.timings = t.Timings
+	e.singleMultiplier = t.SingleMultiplier
+	e.seriesFirstMultiplier = t.SeriesFirstMultiplier
+	e.seriesStepMultiplier = t.SeriesStepMultiplier
+	e.payoutMode = t.PayoutMode
+	e.poolRakePercent = t.PoolRakePercent
+	e.coinCount = t.CoinCount
+	e.coinEdgePercent = t.CoinEdgePercent
+	e.edge = t.Edge
+	e.modes = modesFor(t)
+	e.limits = t.Limits
+
+	e.log.Info("tunables applied",
+		logging.KeyGameID, e.gameID,
+		"betting", t.Timings.BettingTime,
+		"till_result", t.Timings.TimeTillResult,
+		"next_delay", t.Timings.NextGameDelay,
+		"single_multiplier", t.SingleMultiplier,
+		"series_first_multiplier", t.SeriesFirstMultiplier,
+		"series_step_multiplier", t.SeriesStepMultiplier,
+		"payout_mode", t.PayoutMode,
+		"pool_rake_percent", t.PoolRakePercent,
+		"coin_count", t.CoinCount,
+		"coin_edge_percent", t.CoinEdgePercent,
+		"edge_odds", t.Edge.Odds,
+		"edge_multiplier", t.Edge.Multiplier,
+		"edge_policy", t.Edge.Policy,
+		"min_stake_ton", t.Limits.MinStakeTon,
+		"max_stake_ton", t.Limits.MaxStakeTon,
+		"max_bet_items", t.Limits.MaxBetItems,
+		"user_round_cap_ton", t.Limits.UserRoundCapTon,
+		"max_round_liability_ton", t.Limits.MaxRoundLiabilityTon,
+		"cancel_cutoff_seconds", t.Limits.CancelCutoffSeconds,
+	)
+}
+
+func (e *Engine) VoidRound(hasOnline bool) (VoidResult, bool, string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.phase != PhaseBetting && e.phase != PhaseGettingResult {
+		return VoidResult{}, false, "round cannot be voided in phase " + string(e.phase)
+	}
+
+	gid := e.gameID
+	out := VoidResult{GameID: gid}
+
+	for uid, s := range e.series {
+		if s == nil || !s.Active || s.Stage != SeriesStageInRound || s.RoundGameID != gid {
+			continue
+		}
+
+		if s.Wins == 0 {
+			delete(e.series, uid)
+			out.Refunded = append(out.Refunded, uid)
+			continue
+		}
+
+		s.Stage = SeriesStageAwaitingChoice
+		s.RoundGameID = 0
+		s.Side = ""
+		out.Restored = append(out.Restored, SeriesSnapshot{
+			UserID:     s.UserID,
+			Side:       s.Side,
+			Stake:      s.Stake,
+			Wins:       s.Wins,
+			Multiplier: s.Multiplier,
+			Claimable:  claimableForSeries(s),
+			Stage:      s.Stage,
+			Active:     s.Active,
+		})
+	}
+
+	e.bets.Reset(gid)
+	e.observePhaseLocked(e.phase)
+	e.applyTunablesLocked()
+
+	e.gameID = e.ids.Next()
+	e.resultSide = Side("")
+	e.seedHex, e.hash = newRoundSeed()
+
+	if hasOnline && !e.stopped {
+		e.phase = PhaseBetting
+		e.timer = e.timings.BettingTime
+	} else {
+		e.phase = PhaseWaiting
+		e.timer = -1
+	}
+
+	e.log.Info("round voided", logging.KeyGameID, gid, "refunded", len(out.Refunded), "restored", len(out.Restored), "next_game_id", e.gameID)
+
+	e.publishExposureLocked()
+	out.Next = e.snapshotLocked()
+	return out, true, ""
+}
+
+func (e *Engine) ForceCloseSeries(userID int64) (stake float64, multiplier float64, payout float64, ok bool, reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, exists := e.series[userID]
+	if !exists || s == nil || !s.Active {
+		return 0, 0, 0, false, "no active series"
+	}
+
+	if s.Stage == SeriesStageInRound && s.RoundGameID == e.gameID && e.phase != PhaseBetting {
+		return 0, 0, 0, false, "series is in a round being resolved"
+	}
+
+	if s.Wins == 0 || s.Multiplier <= 1.0 {
+		return 0, 0, 0, false, "series has no claimable win yet"
+	}
+
+	stake = s.Stake
+	multiplier = s.Multiplier
+	payout = stake * multiplier
+
+	delete(e.series, userID)
+	e.publishExposureLocked()
+	observeSeriesCashout(payout)
+	e.log.Info("series force closed", logging.KeyGameID, e.gameID, logging.KeyUserID, userID, "wins", s.Wins, "payout", payout)
+
+	return stake, multiplier, payout, true, ""
+}
+
+func (e *Engine) ScheduleConfig(cfg *config.Config) error {
+	return e.ScheduleTunables(e.table.Overrides.Apply(TunablesFromConfig(cfg)))
+}
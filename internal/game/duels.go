@@ -0,0 +1,398 @@
+package game
+
+import (
+	"CoinFlip/internal/config"
+	"CoinFlip/internal/metrics"
+	"CoinFlip/internal/rng"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DuelOpen     = "open"
+	DuelResolved = "resolved"
+	DuelExpired  = "expired"
+)
+
+const (
+	DuelFeeTon  = "ton"
+	DuelFeeItem = "item"
+)
+
+const DuelFeeItemMargin = 0.2
+
+const (
+	ReasonDuelNotFound  = "duel not found"
+	ReasonDuelOwn       = "cannot match own duel"
+	ReasonDuelTolerance = "stake outside duel tolerance"
+	ReasonDuelOpenLimit = "too many open duels"
+)
+
+type DuelRules struct {
+	TTLSeconds       int     `json:"ttl_seconds"`
+	TolerancePercent float64 `json:"tolerance_percent"`
+	FeePercent       float64 `json:"fee_percent"`
+	FeeMode          string  `json:"fee_mode"`
+	MaxOpenPerUser   int     `json:"max_open_per_user"`
+	MinStakeTon      float64 `json:"min_stake_ton"`
+	MaxItems         int     `json:"max_items"`
+}
+
+func DuelRulesFromConfig(cfg *config.Config) DuelRules {
+	return DuelRules{
+		TTLSeconds:       cfg.DuelTTLSeconds,
+		TolerancePercent: cfg.DuelTolerancePercent,
+		FeePercent:       cfg.DuelFeePercent,
+		FeeMode:          cfg.DuelFeeMode,
+		MaxOpenPerUser:   cfg.DuelMaxOpenPerUser,
+		MinStakeTon:      cfg.MinStakeTon,
+		MaxItems:         cfg.WSMaxBetItems,
+	}
+}
+
+func (r DuelRules) Validate() error {
+	if r.TTLSeconds <= 0 {
+		return fmt.Errorf("duel ttl must be positive")
+	}
+	if r.TolerancePercent < 0 || r.TolerancePercent > 100 {
+		return fmt.Errorf("duel tolerance must be within [0, 100]")
+	}
+	if r.FeePercent < 0 || r.FeePercent >= 100 {
+		return fmt.Errorf("duel fee must be within [0, 100)")
+	}
+	if r.FeeMode != DuelFeeTon && r.FeeMode != DuelFeeItem {
+		return fmt.Errorf("duel fee mode must be ton or item")
+	}
+	if r.MaxOpenPerUser <= 0 {
+		return fmt.Errorf("duel max open per user must be positive")
+	}
+	if r.MinStakeTon <= 0 || r.MaxItems <= 0 {
+		return fmt.Errorf("duel stake limits must be positive")
+	}
+	return nil
+}
+
+type Duel struct {
+	ID            int64     `json:"duel_id"`
+	Status        string    `json:"status"`
+	Hash          string    `json:"hash"`
+	Seed          string    `json:"seed,omitempty"`
+	CreatorID     int64     `json:"creator_id"`
+	CreatorSide   string    `json:"creator_side"`
+	CreatorItems  []ItemRef `json:"creator_items"`
+	CreatorStake  float64   `json:"creator_stake"`
+	OpponentID    int64     `json:"opponent_id,omitempty"`
+	OpponentItems []ItemRef `json:"opponent_items,omitempty"`
+	OpponentStake float64   `json:"opponent_stake,omitempty"`
+	ResultSide    string    `json:"result_side,omitempty"`
+	WinnerID      int64     `json:"winner_id,omitempty"`
+	FeeMode       string    `json:"fee_mode,omitempty"`
+	FeeTon        float64   `json:"fee_ton,omitempty"`
+	FeeItem       *ItemRef  `json:"fee_item,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+
+	pending bool
+}
+
+func (d Duel) Pot() float64 {
+	return d.CreatorStake + d.OpponentStake
+}
+
+func (d Duel) Items() []ItemRef {
+	out := make([]ItemRef, 0, len(d.CreatorItems)+len(d.OpponentItems))
+	out = append(out, d.CreatorItems...)
+	return append(out, d.OpponentItems...)
+}
+
+func (d Duel) SettleFee(fromWallet bool) Duel {
+	switch {
+	case fromWallet:
+		d.FeeMode = DuelFeeTon
+		d.FeeItem = nil
+	case d.FeeItem != nil:
+		d.FeeMode = DuelFeeItem
+		d.FeeTon = d.FeeItem.CostTon
+	default:
+		d.FeeMode = ""
+		d.FeeTon = 0
+	}
+	return d
+}
+
+func (d Duel) public() Duel {
+	if d.Status == DuelOpen {
+		d.Seed = ""
+	}
+	d.CreatorItems = append([]ItemRef(nil), d.CreatorItems...)
+	d.OpponentItems = append([]ItemRef(nil), d.OpponentItems...)
+	return d
+}
+
+type Duels struct {
+	mu    sync.Mutex
+	rules DuelRules
+	next  int64
+	open  map[int64]*Duel
+}
+
+func NewDuels(rules DuelRules, nextID int64) (*Duels, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	if nextID <= 0 {
+		nextID = 1
+	}
+	return &Duels{
+		rules: rules,
+		next:  nextID,
+		open:  make(map[int64]*Duel),
+	}, nil
+}
+
+func (l *Duels) Rules() DuelRules {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.rules
+}
+
+func (l *Duels) SetRules(r DuelRules) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.rules = r
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *Duels) Open(userID int64, side string, items []ItemRef, now time.Time) (Duel, bool, string) {
+	if userID == 0 {
+		return Duel{}, false, "bad user_id"
+	}
+	if side != string(SideHeads) && side != string(SideTails) {
+		return Duel{}, false, "bad side"
+	}
+	if len(items) == 0 {
+		return Duel{}, false, "empty bet_items"
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(items) > l.rules.MaxItems {
+		return Duel{}, false, ReasonTooManyItems
+	}
+	stake := itemsStake(items)
+	if stake < l.rules.MinStakeTon {
+		return Duel{}, false, ReasonStakeBelowMin
+	}
+
+	n := 0
+	for _, d := range l.open {
+		if d.CreatorID == userID {
+			n++
+		}
+	}
+	if n >= l.rules.MaxOpenPerUser {
+		return Duel{}, false, ReasonDuelOpenLimit
+	}
+
+	seed, hash := newRoundSeed()
+	d := &Duel{
+		ID:           l.next,
+		Status:       DuelOpen,
+		Hash:         hash,
+		CreatorID:    userID,
+		CreatorSide:  side,
+		CreatorItems: append([]ItemRef(nil), items...),
+		CreatorStake: stake,
+		CreatedAt:    now.UTC(),
+		ExpiresAt:    now.Add(time.Duration(l.rules.TTLSeconds) * time.Second).UTC(),
+		Seed:         seed,
+		pending:      true,
+	}
+	l.next++
+	l.open[d.ID] = d
+
+	return *d, true, ""
+}
+
+func (l *Duels) Publish(duelID int64) (Duel, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, ok := l.open[duelID]
+	if !ok {
+		return Duel{}, false
+	}
+	d.pending = false
+	return d.public(), true
+}
+
+func (l *Duels) Remove(duelID int64) {
+	l.mu.Lock()
+	delete(l.open, duelID)
+	l.mu.Unlock()
+}
+
+func (l *Duels) Get(duelID int64) (Duel, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, ok := l.open[duelID]
+	if !ok || d.pending {
+		return Duel{}, false
+	}
+	return d.public(), true
+}
+
+func (l *Duels) Match(duelID, userID int64, items []ItemRef, now time.Time) (Duel, bool, string) {
+	if userID == 0 {
+		return Duel{}, false, "bad user_id"
+	}
+	if len(items) == 0 {
+		return Duel{}, false, "empty bet_items"
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, ok := l.open[duelID]
+	if !ok || d.pending || !now.Before(d.ExpiresAt) {
+		return Duel{}, false, ReasonDuelNotFound
+	}
+	if d.CreatorID == userID {
+		return Duel{}, false, ReasonDuelOwn
+	}
+	if len(items) > l.rules.MaxItems {
+		return Duel{}, false, ReasonTooManyItems
+	}
+	stake := itemsStake(items)
+	if math.Abs(stake-d.CreatorStake) > d.CreatorStake*l.rules.TolerancePercent/100 {
+		return Duel{}, false, ReasonDuelTolerance
+	}
+
+	out := *d
+	out.Status = DuelResolved
+	out.OpponentID = userID
+	out.OpponentItems = append([]ItemRef(nil), items...)
+	out.OpponentStake = stake
+
+	seedBytes, err := hex.DecodeString(d.Seed)
+	if err != nil {
+		return Duel{}, false, "duel seed corrupted"
+	}
+	out.ResultSide = rng.SideFromSeed(seedBytes)
+	if out.ResultSide == d.CreatorSide {
+		out.WinnerID = d.CreatorID
+	} else {
+		out.WinnerID = userID
+	}
+
+	if l.rules.FeePercent > 0 {
+		out.FeeMode = l.rules.FeeMode
+		out.FeeTon = math.Round(out.Pot()*l.rules.FeePercent/100*1e8) / 1e8
+		out.FeeItem = feeItem(out.Items(), out.FeeTon)
+	}
+
+	delete(l.open, duelID)
+	return out, true, ""
+}
+
+func (l *Duels) Restore(d Duel) {
+	d.Status = DuelOpen
+	d.OpponentID = 0
+	d.OpponentItems = nil
+	d.OpponentStake = 0
+	d.ResultSide = ""
+	d.WinnerID = 0
+	d.FeeMode = ""
+	d.FeeTon = 0
+	d.FeeItem = nil
+
+	l.mu.Lock()
+	l.open[d.ID] = &d
+	l.mu.Unlock()
+}
+
+func (l *Duels) Expire(now time.Time) []Duel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var out []Duel
+	for id, d := range l.open {
+		if d.pending || now.Before(d.ExpiresAt) {
+			continue
+		}
+		x := *d
+		x.Status = DuelExpired
+		out = append(out, x)
+		delete(l.open, id)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (l *Duels) Lobby() []Duel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]Duel, 0, len(l.open))
+	for _, d := range l.open {
+		if !d.pending {
+			out = append(out, d.public())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func ObserveDuel(d Duel) {
+	metrics.DuelOutcomes.WithLabelValues(d.Status).Inc()
+	if d.Status == DuelResolved && d.FeeTon > 0 {
+		metrics.DuelFeeTon.WithLabelValues(d.FeeMode).Add(d.FeeTon)
+		metrics.HousePnLTon.Add(d.FeeTon)
+	}
+}
+
+func itemsStake(items []ItemRef) float64 {
+	total := 0.0
+	for _, it := range items {
+		total += it.CostTon
+	}
+	return total
+}
+
+func feeItem(items []ItemRef, fee float64) *ItemRef {
+	if fee <= 0 || len(items) == 0 {
+		return nil
+	}
+
+	sorted := append([]ItemRef(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].CostTon != sorted[j].CostTon {
+			return sorted[i].CostTon > sorted[j].CostTon
+		}
+		a, _ := strconv.Atoi(sorted[i].ItemID)
+		b, _ := strconv.Atoi(sorted[j].ItemID)
+		return a < b
+	})
+	for i := range sorted {
+		if sorted[i].CostTon > fee {
+			continue
+		}
+		if sorted[i].CostTon < fee*(1-DuelFeeItemMargin) {
+			return nil
+		}
+		return &sorted[i]
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package game
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testItems(costs ...float64) []ItemRef {
+	out := make([]ItemRef, 0, len(costs))
+	for i, c := range costs {
+		out = append(out, ItemRef{Type: "gift", ItemID: strconv.Itoa(i + 1), CostTon: c})
+	}
+	return out
+}
+
+func TestFeeItem(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []ItemRef
+		fee   float64
+		want  string
+	}{
+		{"no fee", testItems(1, 2), 0, ""},
+		{"all items far above fee", testItems(10, 10), 1, ""},
+		{"exact match", testItems(10, 1, 10), 1, "2"},
+		{"within margin below fee", testItems(10, 0.9), 1, "2"},
+		{"below margin", testItems(10, 0.5), 1, ""},
+		{"picks largest fitting", testItems(0.85, 0.95, 3), 1, "2"},
+		{"tie picks lowest id", testItems(5, 1, 1), 1, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := feeItem(tt.items, tt.fee)
+			switch {
+			case tt.want == "" && got != nil:
+				t.Fatalf("feeItem = %+v, want nil", *got)
+			case tt.want != "" && got == nil:
+				t.Fatalf("feeItem = nil, want item %s", tt.want)
+			case got != nil && got.ItemID != tt.want:
+				t.Fatalf("feeItem = item %s, want %s", got.ItemID, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuelMatchFee(t *testing.T) {
+	rules := DuelRules{
+		TTLSeconds:       60,
+		TolerancePercent: 10,
+		FeePercent:       5,
+		FeeMode:          DuelFeeItem,
+		MaxOpenPerUser:   1,
+		MinStakeTon:      0.1,
+		MaxItems:         10,
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		creator  []ItemRef
+		opponent []ItemRef
+		feeTon   float64
+		feeItem  bool
+	}{
+		{"large items keep pot whole", testItems(10), testItems(10), 1, false},
+		{"fitting item taken", testItems(9, 1), testItems(10), 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewDuels(rules, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, ok, reason := l.Open(1, string(SideHeads), tt.creator, now)
+			if !ok {
+				t.Fatalf("open: %s", reason)
+			}
+			l.Publish(d.ID)
+
+			out, ok, reason := l.Match(d.ID, 2, tt.opponent, now)
+			if !ok {
+				t.Fatalf("match: %s", reason)
+			}
+			if out.FeeTon != tt.feeTon {
+				t.Fatalf("FeeTon = %v, want %v", out.FeeTon, tt.feeTon)
+			}
+			if (out.FeeItem != nil) != tt.feeItem {
+				t.Fatalf("FeeItem = %+v, want present=%v", out.FeeItem, tt.feeItem)
+			}
+
+			settled := out.SettleFee(false)
+			if tt.feeItem {
+				if settled.FeeMode != DuelFeeItem || settled.FeeTon > tt.feeTon {
+					t.Fatalf("settled fee = %s %v, want item <= %v", settled.FeeMode, settled.FeeTon, tt.feeTon)
+				}
+			} else if settled.FeeMode != "" || settled.FeeTon != 0 {
+				t.Fatalf("settled fee = %s %v, want waived", settled.FeeMode, settled.FeeTon)
+			}
+
+			if paid := out.SettleFee(true); paid.FeeMode != DuelFeeTon || paid.FeeTon != tt.feeTon || paid.FeeItem != nil {
+				t.Fatalf("wallet fee = %s %v %+v, want ton %v", paid.FeeMode, paid.FeeTon, paid.FeeItem, tt.feeTon)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"CoinFlip/internal/rng"
+	"fmt"
+)
+
+const (
+	EdgePush = "push"
+	EdgeLose = "lose"
+)
+
+type EdgeRules struct {
+	Odds       int     `json:"odds"`
+	Multiplier float64 `json:"multiplier"`
+	Policy     string  `json:"policy"`
+}
+
+func (r EdgeRules) Validate() error {
+	if r.Odds < 0 {
+		return fmt.Errorf("edge_odds must not be negative")
+	}
+	if r.Odds > 0 && (r.Multiplier <= 1 || r.Multiplier >= float64(r.Odds)) {
+		return fmt.Errorf("edge_multiplier must be within (1, edge_odds)")
+	}
+	if r.Policy != EdgePush && r.Policy != EdgeLose {
+		return fmt.Errorf("edge_policy must be push or lose")
+	}
+	return nil
+}
+
+func (e *Engine) edgeRulesLocked() *EdgeRules {
+	if e.edge.Odds <= 0 || e.payoutMode != PayoutFixed || e.coinCount > 1 {
+		return nil
+	}
+	r := e.edge
+	return &r
+}
+
+func (e *Engine) edgeOddsLocked() int {
+	if r := e.edgeRulesLocked(); r != nil {
+		return r.Odds
+	}
+	return 0
+}
+
+func (e *Engine) EdgeOdds() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.edgeOddsLocked()
+}
+
+func (e *Engine) coinOutcomeLocked(seed []byte) Outcome {
+	if seed == nil {
+		return coinOutcome(nil)
+	}
+	return Outcome{Side: Side(rng.OutcomeFromSeed(seed, e.edgeOddsLocked()))}
+}
+
+func (e *Engine) edgePushLocked(out Outcome) bool {
+	return out.Side == SideEdge && e.edge.Policy == EdgePush
+}
@@ -0,0 +1,115 @@
+package game
+
+import (
+	"CoinFlip/internal/config"
+	"CoinFlip/internal/rng"
+	"slices"
+	"testing"
+)
+
+func edgeConfig(policy string) func(cfg *config.Config) {
+	return func(cfg *config.Config) {
+		cfg.EdgeOdds = 50
+		cfg.EdgeMultiplier = 40
+		cfg.EdgePolicy = policy
+	}
+}
+
+func TestEdgeSides(t *testing.T) {
+	tests := []struct {
+		name   string
+		tune   func(cfg *config.Config)
+		mode   string
+		ok     bool
+		reason string
+	}{
+		{"single with edge enabled", edgeConfig(EdgePush), ModeSingle, true, ""},
+		{"series never takes edge", edgeConfig(EdgePush), ModeSeries, false, "bad side"},
+		{"edge disabled", nil, ModeSingle, false, "bad side"},
+		{"pool has no edge", func(cfg *config.Config) {
+			edgeConfig(EdgePush)(cfg)
+			cfg.PayoutMode = PayoutPool
+		}, ModePool, false, "bad side"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEngine(t, tt.tune)
+			_, _, ok, reason := e.NormalizeBet(tt.mode, string(SideEdge))
+			if ok != tt.ok || reason != tt.reason {
+				t.Fatalf("NormalizeBet = %v, %q; want %v, %q", ok, reason, tt.ok, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEdgeSettlement(t *testing.T) {
+	edge := seedWhere(t, func(seed []byte) bool { return rng.OutcomeFromSeed(seed, 50) == string(SideEdge) })
+	heads := seedWhere(t, func(seed []byte) bool { return rng.OutcomeFromSeed(seed, 50) == string(SideHeads) })
+
+	tests := []struct {
+		name    string
+		policy  string
+		seed    string
+		result  Side
+		pushed  []string
+		winning map[string]float64
+		pushes  []int64
+		wins    []int64
+		series  string
+	}{
+		{"edge pushes coin sides", EdgePush, edge, SideEdge, []string{"heads", "tails"}, map[string]float64{"edge": 40}, []int64{1, 3}, []int64{2}, "push"},
+		{"edge loses coin sides", EdgeLose, edge, SideEdge, nil, map[string]float64{"edge": 40}, nil, []int64{2}, "lose"},
+		{"coin side wins as usual", EdgePush, heads, SideHeads, nil, map[string]float64{"heads": 1.96}, nil, []int64{1}, "win"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEngine(t, edgeConfig(tt.policy))
+			for _, b := range []struct {
+				user int64
+				side Side
+				mode string
+			}{{1, SideHeads, ModeSingle}, {2, SideEdge, ModeSingle}, {3, SideTails, ModeSingle}, {4, SideHeads, ModeSeries}} {
+				if _, _, ok, reason := e.AddBet(b.user, string(b.side), b.mode, testItems(1)); !ok {
+					t.Fatalf("AddBet(%d): %s", b.user, reason)
+				}
+			}
+
+			gameID := e.gameID
+			pr := playRound(e, tt.seed)
+			if pr.ResultSide != tt.result {
+				t.Fatalf("result = %s, want %s", pr.ResultSide, tt.result)
+			}
+			if len(pr.Settlements) != 1 {
+				t.Fatalf("settlements = %+v, want one single settlement", pr.Settlements)
+			}
+			st := pr.Settlements[0]
+			slices.Sort(st.Pushed)
+			if st.Mode != ModeSingle || !slices.Equal(st.Pushed, tt.pushed) || len(st.Winning) != len(tt.winning) {
+				t.Fatalf("settlement = %+v, want pushed %v winning %v", st, tt.pushed, tt.winning)
+			}
+			for side, m := range tt.winning {
+				if st.Winning[side] != m {
+					t.Fatalf("winning[%s] = %v, want %v", side, st.Winning[side], m)
+				}
+			}
+
+			for uid := int64(1); uid <= 3; uid++ {
+				r := pr.Results[uid]
+				if r.Push != slices.Contains(tt.pushes, uid) || r.Win != slices.Contains(tt.wins, uid) {
+					t.Fatalf("user %d result = %+v", uid, r)
+				}
+				if r.Push && r.Payout != 0 {
+					t.Fatalf("user %d pushed with payout %v", uid, r.Payout)
+				}
+			}
+
+			res, _ := e.SeriesResultsForGame(gameID)
+			if res[4].Outcome != tt.series {
+				t.Fatalf("series outcome = %q, want %q", res[4].Outcome, tt.series)
+			}
+			if _, ok := e.SeriesSnapshot(4); ok != (tt.series == "win") {
+				t.Fatalf("series kept = %v after %s", ok, tt.series)
+			}
+		})
+	}
+}
@@ -2,18 +2,28 @@ package game
 
 import (
 	"CoinFlip/internal/config"
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/metrics"
+	"CoinFlip/internal/risk"
 	"CoinFlip/internal/rng"
+	"context"
 	"encoding/hex"
-	"log"
+	"fmt"
+	"log/slog"
+	"slices"
 	"sync"
+	"time"
 )
 
 type Snapshot struct {
+	TableID    int
 	Phase      Phase
 	Timer      int
 	GameID     int
 	Hash       string
 	ResultSide Side
+	Faces      []Side
+	EdgeOdds   int
 	Seed       string
 }
 
@@ -61,7 +71,8 @@ type SeriesRoundResult struct {
 }
 
 type Engine struct {
-	mu sync.RWMutex
+	mu  sync.RWMutex
+	log *slog.Logger
 
 	phase      Phase
 	timer      int
@@ -70,8 +81,30 @@ type Engine struct {
 	resultSide Side
 	seedHex    string
 
-	bets *BetStore
-	cfg  *config.Config
+	table Table
+	ids   *GameIDs
+
+	phaseStartedAt time.Time
+
+	bets    *BetStore
+	timings Timings
+	paused  bool
+	stopped bool
+	pending *Tunables
+
+	singleMultiplier      float64
+	seriesFirstMultiplier float64
+	seriesStepMultiplier  float64
+	payoutMode            string
+	poolRakePercent       float64
+	coinCount             int
+	coinEdgePercent       float64
+	edge                  EdgeRules
+	faces                 []Side
+	modes                 []GameMode
+	jackpot               *Jackpot
+	limits                Limits
+	risk                  *risk.Monitor
 
 	payouts map[int]PayoutResult
 	history []PayoutResult
@@ -98,42 +131,56 @@ func claimableForSeries(s *SeriesState) float64 {
 	return s.Stake * s.Multiplier
 }
 
-func NewEngine(cfg *config.Config, startGameID int) *Engine {
-	if startGameID <= 0 {
-		startGameID = 1
+func NewEngine(ctx context.Context, cfg *config.Config, table Table, ids *GameIDs) (*Engine, error) {
+	t := table.Overrides.Apply(TunablesFromConfig(cfg))
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("table %d: %w", table.ID, err)
 	}
 
 	seedHex, hash := newRoundSeed()
 
 	return &Engine{
+		log: logging.FromContext(ctx).With("component", "game", "table_id", table.ID),
+
 		phase:   PhaseWaiting,
 		timer:   -1,
-		gameID:  startGameID,
+		gameID:  ids.Next(),
 		seedHex: seedHex,
 		hash:    hash,
 
+		table: table,
+		ids:   ids,
+
+		phaseStartedAt: time.Now(),
+
 		bets:    NewBetStore(),
-		cfg:     cfg,
+		timings: t.Timings,
+
+		singleMultiplier:      t.SingleMultiplier,
+		seriesFirstMultiplier: t.SeriesFirstMultiplier,
+		seriesStepMultiplier:  t.SeriesStepMultiplier,
+		payoutMode:            t.PayoutMode,
+		poolRakePercent:       t.PoolRakePercent,
+		coinCount:             t.CoinCount,
+		coinEdgePercent:       t.CoinEdgePercent,
+		edge:                  t.Edge,
+		modes:                 modesFor(t),
+		limits:                t.Limits,
+		risk:                  risk.NewMonitor(ctx, table.ID, risk.ThresholdsFromConfig(cfg)),
+
 		payouts: make(map[int]PayoutResult),
 		history: make([]PayoutResult, 0),
 
 		series:        make(map[int64]*SeriesState),
 		seriesResults: make(map[int]map[int64]SeriesRoundResult),
-	}
+	}, nil
 }
 
 func (e *Engine) Snapshot() Snapshot {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	return Snapshot{
-		Phase:      e.phase,
-		Timer:      e.timer,
-		GameID:     e.gameID,
-		Hash:       e.hash,
-		ResultSide: e.resultSide,
-		Seed:       e.seedHex,
-	}
+	return e.snapshotLocked()
 }
 
 func (e *Engine) SeriesSnapshot(userID int64) (*SeriesSnapshot, bool) {
@@ -162,6 +209,8 @@ func (e *Engine) RestoreSeriesSnapshot(ss SeriesSnapshot) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	defer e.publishExposureLocked()
+
 	if !ss.Active {
 		delete(e.series, ss.UserID)
 		return
@@ -185,11 +234,17 @@ func (e *Engine) Tick(hasOnline bool) (bool, Snapshot) {
 
 	old := e.phase
 
+	if e.paused {
+		return false, e.snapshotLocked()
+	}
+
 	if e.phase == PhaseWaiting {
-		if hasOnline {
+		if hasOnline && !e.stopped {
+			e.applyTunablesLocked()
 			e.phase = PhaseBetting
-			e.timer = e.cfg.BettingTime
-			log.Printf("game: phase from=waiting to=betting game_id=%d timer=%d", e.gameID, e.timer)
+			e.timer = e.timings.BettingTime
+			e.log.Info("phase changed", "from", PhaseWaiting, "to", PhaseBetting, logging.KeyGameID, e.gameID, "timer", e.timer)
+			e.observePhaseLocked(old)
 		}
 		return e.phase != old, e.snapshotLocked()
 	}
@@ -201,16 +256,29 @@ func (e *Engine) Tick(hasOnline bool) (bool, Snapshot) {
 		e.nextPhaseLocked(hasOnline)
 	}
 
+	if e.phase != old {
+		e.observePhaseLocked(old)
+		e.publishExposureLocked()
+	}
 	return e.phase != old, e.snapshotLocked()
 }
 
+func (e *Engine) observePhaseLocked(left Phase) {
+	now := time.Now()
+	metrics.PhaseDuration.WithLabelValues(string(left)).Observe(now.Sub(e.phaseStartedAt).Seconds())
+	e.phaseStartedAt = now
+}
+
 func (e *Engine) snapshotLocked() Snapshot {
 	return Snapshot{
+		TableID:    e.table.ID,
 		Phase:      e.phase,
 		Timer:      e.timer,
 		GameID:     e.gameID,
 		Hash:       e.hash,
 		ResultSide: e.resultSide,
+		Faces:      slices.Clone(e.faces),
+		EdgeOdds:   e.edgeOddsLocked(),
 		Seed:       e.seedHex,
 	}
 }
@@ -219,25 +287,40 @@ func (e *Engine) nextPhaseLocked(hasOnline bool) {
 	switch e.phase {
 	case PhaseBetting:
 		e.phase = PhaseGettingResult
-		e.timer = e.cfg.TimeTillResult
+		e.timer = e.timings.TimeTillResult
 
 		seedBytes, err := hex.DecodeString(e.seedHex)
 		if err != nil {
-			e.resultSide = SideHeads
+			seedBytes = nil
+		}
+		if m, ok := e.modeLocked(""); ok {
+			out := m.Resolve(e, seedBytes)
+			e.resultSide, e.faces = out.Side, out.Faces
 		} else {
-			e.resultSide = Side(rng.SideFromSeed(seedBytes))
+			e.resultSide, e.faces = coinOutcome(seedBytes).Side, nil
 		}
 
-		log.Printf("game: phase from=betting to=gettingResult game_id=%d timer=%d", e.gameID, e.timer)
+		e.log.Info("phase changed", "from", PhaseBetting, "to", PhaseGettingResult, logging.KeyGameID, e.gameID, "timer", e.timer)
 
 	case PhaseGettingResult:
-		seriesRes := e.updateSeriesLocked()
+		out := Outcome{Side: e.resultSide, Faces: e.faces}
+
+		seriesRes := make(map[int64]SeriesRoundResult)
+		for _, m := range e.modes {
+			for uid, res := range m.AfterRound(e, out) {
+				seriesRes[uid] = res
+			}
+		}
 		if len(seriesRes) > 0 {
 			e.seriesResults[e.gameID] = seriesRes
 		}
 
-		pr := e.calculatePayoutsLocked()
+		pr := e.calculatePayoutsLocked(out)
+		if seedBytes, err := hex.DecodeString(e.seedHex); err == nil {
+			pr.Jackpot = e.settleJackpotLocked(seedBytes, pr, seriesRes)
+		}
 		e.payouts[e.gameID] = pr
+		e.observeRoundLocked(pr, seriesRes)
 
 		e.history = append(e.history, pr)
 		if len(e.history) > 10 {
@@ -248,27 +331,29 @@ func (e *Engine) nextPhaseLocked(hasOnline bool) {
 		}
 
 		e.phase = PhaseFinished
-		e.timer = e.cfg.NextGameDelay
-		log.Printf("game: phase from=gettingResult to=finished game_id=%d timer=%d result=%s", e.gameID, e.timer, e.resultSide)
+		e.timer = e.timings.NextGameDelay
+		e.log.Info("phase changed", "from", PhaseGettingResult, "to", PhaseFinished, logging.KeyGameID, e.gameID, "timer", e.timer, "result", e.resultSide)
 
 	case PhaseFinished:
 		finishedGameID := e.gameID
 		e.bets.Reset(finishedGameID)
+		e.applyTunablesLocked()
 
-		e.gameID++
+		e.gameID = e.ids.Next()
 		e.resultSide = Side("")
+		e.faces = nil
 		e.seedHex, e.hash = newRoundSeed()
 
-		if !hasOnline {
+		if !hasOnline || e.stopped {
 			e.phase = PhaseWaiting
 			e.timer = -1
-			log.Printf("game: phase from=finished to=waiting game_id=%d", e.gameID)
+			e.log.Info("phase changed", "from", PhaseFinished, "to", PhaseWaiting, logging.KeyGameID, e.gameID)
 			return
 		}
 
 		e.phase = PhaseBetting
-		e.timer = e.cfg.BettingTime
-		log.Printf("game: phase from=finished to=betting game_id=%d timer=%d", e.gameID, e.timer)
+		e.timer = e.timings.BettingTime
+		e.log.Info("phase changed", "from", PhaseFinished, "to", PhaseBetting, logging.KeyGameID, e.gameID, "timer", e.timer)
 	}
 }
 
@@ -276,44 +361,47 @@ func (e *Engine) AddBet(userID int64, side string, mode string, items []ItemRef)
 	if userID == 0 {
 		return Snapshot{}, 0, false, "bad user_id"
 	}
-	if side != string(SideHeads) && side != string(SideTails) {
-		return Snapshot{}, 0, false, "bad side"
-	}
 	if len(items) == 0 {
 		return Snapshot{}, 0, false, "empty items"
 	}
 
-	mode = "series"
-
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if e.phase != PhaseBetting || e.timer <= 0 {
-		return e.snapshotLocked(), 0, false, "betting closed"
+	m, ok := e.modeLocked(mode)
+	if !ok {
+		return e.snapshotLocked(), 0, false, "bad mode"
 	}
-
-	if s, exists := e.series[userID]; exists && s != nil && s.Active {
-		return e.snapshotLocked(), 0, false, "active series already exists"
+	side, ok = m.NormalizeSide(e, side)
+	if !ok {
+		return e.snapshotLocked(), 0, false, "bad side"
 	}
 
-	accepted := e.bets.Add(e.gameID, userID, side, mode, items)
+	if e.paused {
+		return e.snapshotLocked(), 0, false, "game paused"
+	}
+	if e.stopped {
+		return e.snapshotLocked(), 0, false, "server shutting down"
+	}
+	if e.phase != PhaseBetting || e.timer <= 0 {
+		return e.snapshotLocked(), 0, false, "betting closed"
+	}
 
 	stake := 0.0
 	for _, it := range items {
 		stake += it.CostTon
 	}
 
-	if stake > 0 {
-		e.series[userID] = &SeriesState{
-			UserID:      userID,
-			Side:        side,
-			Stake:       stake,
-			Wins:        0,
-			Multiplier:  1.0,
-			Stage:       SeriesStageInRound,
-			RoundGameID: e.gameID,
-			Active:      true,
-		}
+	if reason := e.checkStakeLocked(stake, items); reason != "" {
+		return e.snapshotLocked(), 0, false, reason
+	}
+	if e.overUserCapLocked(userID, stake) {
+		return e.snapshotLocked(), 0, false, ReasonUserRoundCap
+	}
+
+	accepted, reason := m.PlaceBet(e, userID, side, stake, items)
+	if reason != "" {
+		return e.snapshotLocked(), 0, false, reason
 	}
 
 	return e.snapshotLocked(), accepted, true, ""
@@ -333,6 +421,7 @@ func (e *Engine) RollbackAcceptedBet(gameID int, userID int64, mode string, item
 	if ok && s != nil && s.Active && s.Wins == 0 && s.RoundGameID == gameID && s.Stage == SeriesStageInRound {
 		delete(e.series, userID)
 	}
+	e.publishExposureLocked()
 }
 
 func (e *Engine) SeriesContinue(userID int64, side string) (*SeriesSnapshot, bool, string) {
@@ -346,6 +435,12 @@ func (e *Engine) SeriesContinue(userID int64, side string) (*SeriesSnapshot, boo
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if e.paused {
+		return nil, false, "game paused"
+	}
+	if e.stopped {
+		return nil, false, "server shutting down"
+	}
 	if e.phase != PhaseBetting || e.timer <= 0 {
 		return nil, false, "betting closed"
 	}
@@ -354,15 +449,30 @@ func (e *Engine) SeriesContinue(userID int64, side string) (*SeriesSnapshot, boo
 	if !exists || s == nil || !s.Active {
 		return nil, false, "no active series"
 	}
+	if _, ok := e.modeLocked(ModeSeries); !ok {
+		return nil, false, "series not available on this table"
+	}
 
 	if s.Stage != SeriesStageAwaitingChoice {
 		return nil, false, "series is already participating in current round"
 	}
 
+	if e.overUserCapLocked(userID, claimableForSeries(s)) {
+		return nil, false, ReasonUserRoundCap
+	}
+
 	s.Side = side
 	s.Stage = SeriesStageInRound
 	s.RoundGameID = e.gameID
 
+	if reason := e.checkExposureLocked(userID, s.Stake*e.nextSeriesMultiplierLocked(s)); reason != "" {
+		s.Side = ""
+		s.Stage = SeriesStageAwaitingChoice
+		s.RoundGameID = 0
+		return nil, false, reason
+	}
+	e.publishExposureLocked()
+
 	out := &SeriesSnapshot{
 		UserID:      s.UserID,
 		Side:        s.Side,
@@ -404,7 +514,9 @@ func (e *Engine) Cashout(userID int64) (stake float64, multiplier float64, payou
 	payout = stake * multiplier
 
 	delete(e.series, userID)
+	e.publishExposureLocked()
 
+	observeSeriesCashout(payout)
 	return stake, multiplier, payout, true, ""
 }
 
@@ -456,68 +568,3 @@ func (e *Engine) History() []PayoutResult {
 	copy(out, e.history)
 	return out
 }
-
-func (e *Engine) updateSeriesLocked() map[int64]SeriesRoundResult {
-	results := make(map[int64]SeriesRoundResult)
-
-	for userID, s := range e.series {
-		if !s.Active {
-			continue
-		}
-		if s.Stage != SeriesStageInRound {
-			continue
-		}
-		if s.RoundGameID != e.gameID {
-			continue
-		}
-
-		playedSide := s.Side
-
-		if Side(playedSide) != e.resultSide {
-			results[userID] = SeriesRoundResult{
-				GameID:     e.gameID,
-				UserID:     userID,
-				Side:       playedSide,
-				Stake:      s.Stake,
-				Wins:       s.Wins,
-				Multiplier: s.Multiplier,
-				Claimable:  0,
-				Stage:      "",
-				Active:     false,
-				Outcome:    "lose",
-			}
-
-			delete(e.series, userID)
-			log.Printf("series lost user=%d", userID)
-			continue
-		}
-
-		s.Wins++
-		if s.Wins == 1 {
-			s.Multiplier = 1.96
-		} else {
-			s.Multiplier = s.Multiplier * 2
-		}
-
-		s.Stage = SeriesStageAwaitingChoice
-		s.RoundGameID = 0
-		s.Side = ""
-
-		results[userID] = SeriesRoundResult{
-			GameID:     e.gameID,
-			UserID:     userID,
-			Side:       playedSide,
-			Stake:      s.Stake,
-			Wins:       s.Wins,
-			Multiplier: s.Multiplier,
-			Claimable:  claimableForSeries(s),
-			Stage:      s.Stage,
-			Active:     true,
-			Outcome:    "win",
-		}
-
-		log.Printf("series win user=%d wins=%d multiplier=%.2f", userID, s.Wins, s.Multiplier)
-	}
-
-	return results
-}
@@ -0,0 +1,202 @@
+package game
+
+import (
+	"CoinFlip/internal/config"
+	"CoinFlip/internal/metrics"
+	"CoinFlip/internal/rng"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	JackpotTriggerSeed   = "seed"
+	JackpotTriggerSeries = "series"
+)
+
+type JackpotRules struct {
+	Percent    float64 `json:"percent"`
+	Odds       int     `json:"odds"`
+	SeriesWins int     `json:"series_wins"`
+}
+
+func JackpotRulesFromConfig(cfg *config.Config) JackpotRules {
+	return JackpotRules{
+		Percent:    cfg.JackpotPercent,
+		Odds:       cfg.JackpotOdds,
+		SeriesWins: cfg.JackpotSeriesWins,
+	}
+}
+
+func (r JackpotRules) Validate() error {
+	if r.Percent < 0 || r.Percent >= 50 {
+		return fmt.Errorf("jackpot percent must be within [0, 50)")
+	}
+	if r.Odds < 0 || r.SeriesWins < 0 {
+		return fmt.Errorf("jackpot triggers must not be negative")
+	}
+	return nil
+}
+
+type JackpotState struct {
+	Balance float64      `json:"balance"`
+	Rules   JackpotRules `json:"rules"`
+}
+
+type JackpotWinner struct {
+	UserID  int64   `json:"user_id"`
+	Stake   float64 `json:"stake"`
+	Amount  float64 `json:"amount"`
+	Trigger string  `json:"trigger"`
+}
+
+type JackpotResult struct {
+	Contribution float64         `json:"contribution"`
+	Balance      float64         `json:"balance"`
+	Hit          bool            `json:"hit"`
+	Amount       float64         `json:"amount,omitempty"`
+	Winners      []JackpotWinner `json:"winners,omitempty"`
+}
+
+type Jackpot struct {
+	mu      sync.Mutex
+	rules   JackpotRules
+	balance float64
+}
+
+func NewJackpot(rules JackpotRules, balance float64) (*Jackpot, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	metrics.JackpotBalanceTon.Set(balance)
+	return &Jackpot{rules: rules, balance: balance}, nil
+}
+
+func (j *Jackpot) Rules() JackpotRules {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.rules
+}
+
+func (j *Jackpot) SetRules(r JackpotRules) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	j.rules = r
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *Jackpot) State() JackpotState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return JackpotState{Balance: j.balance, Rules: j.rules}
+}
+
+func (j *Jackpot) Reset(balance float64) {
+	j.mu.Lock()
+	j.balance = balance
+	j.mu.Unlock()
+	metrics.JackpotBalanceTon.Set(balance)
+}
+
+func (j *Jackpot) settle(seed []byte, stake float64, roundWinners map[int64]float64, seriesWinners map[int64]float64) JackpotResult {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	res := JackpotResult{Contribution: floorTon(stake * j.rules.Percent / 100)}
+	j.balance += res.Contribution
+
+	winners := make(map[int64]JackpotWinner)
+	if rng.JackpotFromSeed(seed, j.rules.Odds) {
+		res.Hit = true
+		for uid, st := range roundWinners {
+			winners[uid] = JackpotWinner{UserID: uid, Stake: st, Trigger: JackpotTriggerSeed}
+		}
+	}
+	for uid, st := range seriesWinners {
+		res.Hit = true
+		if _, ok := winners[uid]; !ok {
+			winners[uid] = JackpotWinner{UserID: uid, Stake: st, Trigger: JackpotTriggerSeries}
+		}
+	}
+
+	total := 0.0
+	for _, w := range winners {
+		total += w.Stake
+	}
+	if total > 0 && j.balance > 0 {
+		pot := j.balance
+		for _, w := range winners {
+			w.Amount = floorTon(pot * w.Stake / total)
+			if w.Amount <= 0 {
+				continue
+			}
+			res.Amount += w.Amount
+			res.Winners = append(res.Winners, w)
+		}
+		sort.Slice(res.Winners, func(a, b int) bool { return res.Winners[a].UserID < res.Winners[b].UserID })
+		j.balance -= res.Amount
+	}
+
+	res.Balance = j.balance
+	metrics.JackpotContributionTon.Add(res.Contribution)
+	metrics.JackpotBalanceTon.Set(j.balance)
+	if res.Amount > 0 {
+		metrics.JackpotPaidTon.Add(res.Amount)
+	}
+	return res
+}
+
+func floorTon(v float64) float64 {
+	return math.Floor(v*1e8) / 1e8
+}
+
+func (e *Engine) SetJackpot(j *Jackpot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.jackpot = j
+}
+
+func (e *Engine) settleJackpotLocked(seed []byte, pr PayoutResult, seriesRes map[int64]SeriesRoundResult) *JackpotResult {
+	if e.jackpot == nil || seed == nil {
+		return nil
+	}
+
+	stake := 0.0
+	if !pr.Refunded {
+		for _, ub := range e.bets.Snapshot(e.gameID) {
+			for _, b := range ub.Bets {
+				stake += betStakeValue(b)
+			}
+		}
+	}
+
+	roundWinners := make(map[int64]float64)
+	for uid, r := range pr.Results {
+		if r.Win {
+			roundWinners[uid] += r.Stake
+		}
+	}
+
+	rules := e.jackpot.Rules()
+	seriesWinners := make(map[int64]float64)
+	for uid, res := range seriesRes {
+		if res.Outcome != "win" {
+			continue
+		}
+		roundWinners[uid] += res.Stake
+		if rules.SeriesWins > 0 && res.Wins == rules.SeriesWins {
+			seriesWinners[uid] = res.Stake
+		}
+	}
+
+	res := e.jackpot.settle(seed, stake, roundWinners, seriesWinners)
+	return &res
+}
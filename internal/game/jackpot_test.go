@@ -0,0 +1,176 @@
+package game
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestJackpotSettle(t *testing.T) {
+	seed := []byte("seed")
+
+	tests := []struct {
+		name    string
+		odds    int
+		round   map[int64]float64
+		series  map[int64]float64
+		hit     bool
+		winners []JackpotWinner
+		balance float64
+	}{
+		{"no trigger", 0, map[int64]float64{1: 10}, nil, false, nil, 100.5},
+		{"seed hit empty round", 1, nil, nil, true, nil, 100.5},
+		{
+			"seed hit split by stake", 1, map[int64]float64{1: 10, 2: 30}, nil, true,
+			[]JackpotWinner{
+				{UserID: 1, Stake: 10, Amount: 25.125, Trigger: JackpotTriggerSeed},
+				{UserID: 2, Stake: 30, Amount: 75.375, Trigger: JackpotTriggerSeed},
+			},
+			0,
+		},
+		{
+			"series only", 0, map[int64]float64{1: 10}, map[int64]float64{3: 5}, true,
+			[]JackpotWinner{{UserID: 3, Stake: 5, Amount: 100.5, Trigger: JackpotTriggerSeries}},
+			0,
+		},
+		{
+			"seed trigger wins over series", 1, map[int64]float64{1: 10}, map[int64]float64{1: 2, 2: 10}, true,
+			[]JackpotWinner{
+				{UserID: 1, Stake: 10, Amount: 50.25, Trigger: JackpotTriggerSeed},
+				{UserID: 2, Stake: 10, Amount: 50.25, Trigger: JackpotTriggerSeries},
+			},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := NewJackpot(JackpotRules{Percent: 1, Odds: tt.odds}, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := j.settle(seed, 50, tt.round, tt.series)
+			if res.Contribution != 0.5 {
+				t.Fatalf("Contribution = %v, want 0.5", res.Contribution)
+			}
+			if res.Hit != tt.hit {
+				t.Fatalf("Hit = %v, want %v", res.Hit, tt.hit)
+			}
+			if !slices.Equal(res.Winners, tt.winners) {
+				t.Fatalf("Winners = %+v, want %+v", res.Winners, tt.winners)
+			}
+			if res.Balance != tt.balance || j.State().Balance != tt.balance {
+				t.Fatalf("Balance = %v (state %v), want %v", res.Balance, j.State().Balance, tt.balance)
+			}
+		})
+	}
+}
+
+func TestSettleJackpotLocked(t *testing.T) {
+	type bet struct {
+		user  int64
+		side  Side
+		mode  string
+		stake float64
+	}
+	heads := seedWhere(t, func(seed []byte) bool { return coinOutcome(seed).Side == SideHeads })
+
+	tests := []struct {
+		name    string
+		rules   JackpotRules
+		bets    []bet
+		series  []SeriesState
+		contrib float64
+		hit     bool
+		winners map[int64]JackpotWinner
+	}{
+		{
+			"seed trigger pays round winner",
+			JackpotRules{Percent: 1, Odds: 1},
+			[]bet{{1, SideHeads, ModeSingle, 2}, {2, SideTails, ModeSingle, 1}},
+			nil,
+			0.03, true,
+			map[int64]JackpotWinner{1: {UserID: 1, Stake: 2, Amount: 100.03, Trigger: JackpotTriggerSeed}},
+		},
+		{
+			"seed trigger without winners keeps pot",
+			JackpotRules{Percent: 1, Odds: 1},
+			[]bet{{2, SideTails, ModeSingle, 1}},
+			nil,
+			0.01, true,
+			map[int64]JackpotWinner{},
+		},
+		{
+			"winners split pot by stake",
+			JackpotRules{Percent: 1, Odds: 1},
+			[]bet{{1, SideHeads, ModeSingle, 1}, {2, SideHeads, ModeSingle, 3}, {3, SideTails, ModeSingle, 1}},
+			nil,
+			0.05, true,
+			map[int64]JackpotWinner{
+				1: {UserID: 1, Stake: 1, Amount: 25.0125, Trigger: JackpotTriggerSeed},
+				2: {UserID: 2, Stake: 3, Amount: 75.0375, Trigger: JackpotTriggerSeed},
+			},
+		},
+		{
+			"series trigger at configured wins",
+			JackpotRules{Percent: 1, SeriesWins: 2},
+			[]bet{{6, SideHeads, ModeSeries, 1}},
+			[]SeriesState{
+				{UserID: 5, Side: string(SideHeads), Stake: 4, Wins: 1, Multiplier: 1.96},
+				{UserID: 7, Side: string(SideHeads), Stake: 4, Wins: 2, Multiplier: 3.92},
+			},
+			0.01, true,
+			map[int64]JackpotWinner{5: {UserID: 5, Stake: 4, Amount: 100.01, Trigger: JackpotTriggerSeries}},
+		},
+		{
+			"no trigger only contributes",
+			JackpotRules{Percent: 1, SeriesWins: 5},
+			[]bet{{1, SideHeads, ModeSingle, 2}},
+			[]SeriesState{{UserID: 5, Side: string(SideHeads), Stake: 4, Wins: 1, Multiplier: 1.96}},
+			0.02, false,
+			map[int64]JackpotWinner{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEngine(t, nil)
+			j, err := NewJackpot(tt.rules, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.SetJackpot(j)
+
+			for _, b := range tt.bets {
+				if _, _, ok, reason := e.AddBet(b.user, string(b.side), b.mode, testItems(b.stake)); !ok {
+					t.Fatalf("AddBet(%d): %s", b.user, reason)
+				}
+			}
+			for _, s := range tt.series {
+				s.Stage, s.RoundGameID, s.Active = SeriesStageInRound, e.gameID, true
+				e.series[s.UserID] = &s
+			}
+
+			res := playRound(e, heads).Jackpot
+			if res == nil {
+				t.Fatal("no jackpot result")
+			}
+			if math.Abs(res.Contribution-tt.contrib) > 1e-9 || res.Hit != tt.hit {
+				t.Fatalf("jackpot = %+v, want contribution %v hit %v", *res, tt.contrib, tt.hit)
+			}
+			if len(res.Winners) != len(tt.winners) {
+				t.Fatalf("winners = %+v, want %+v", res.Winners, tt.winners)
+			}
+			paid := 0.0
+			for _, w := range res.Winners {
+				want := tt.winners[w.UserID]
+				if w.UserID != want.UserID || w.Stake != want.Stake || w.Trigger != want.Trigger || math.Abs(w.Amount-want.Amount) > 1e-7 {
+					t.Fatalf("winner = %+v, want %+v", w, want)
+				}
+				paid += w.Amount
+			}
+			if balance := 100 + tt.contrib - paid; math.Abs(res.Balance-balance) > 1e-7 || j.State().Balance != res.Balance {
+				t.Fatalf("balance = %v (state %v), want %v", res.Balance, j.State().Balance, balance)
+			}
+		})
+	}
+}
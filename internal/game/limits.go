@@ -0,0 +1,144 @@
+package game
+
+import (
+	"CoinFlip/internal/risk"
+	"fmt"
+	"slices"
+)
+
+const (
+	ReasonStakeBelowMin  = "stake below minimum"
+	ReasonStakeAboveMax  = "stake above maximum"
+	ReasonTooManyItems   = "too many bet items"
+	ReasonUserRoundCap   = "round stake cap reached"
+	ReasonHouseLiability = "round liability limit reached"
+	ReasonCancelCutoff   = "too late to cancel"
+	ReasonItemType       = "item type not allowed at this table"
+)
+
+var reasonCodes = map[string]string{
+	ReasonStakeBelowMin:  "stake_below_min",
+	ReasonStakeAboveMax:  "stake_above_max",
+	ReasonTooManyItems:   "too_many_items",
+	ReasonUserRoundCap:   "user_round_cap",
+	ReasonHouseLiability: "house_liability",
+	ReasonCancelCutoff:   "cancel_cutoff",
+	ReasonItemType:       "item_type",
+
+	ReasonDuelNotFound:  "duel_not_found",
+	ReasonDuelOwn:       "duel_own",
+	ReasonDuelTolerance: "duel_tolerance",
+	ReasonDuelOpenLimit: "duel_open_limit",
+
+	risk.ReasonHouseExposure: "house_exposure",
+	risk.ReasonUserExposure:  "user_exposure",
+	risk.ReasonThrottled:     "risk_throttled",
+}
+
+func ReasonCode(reason string) string {
+	return reasonCodes[reason]
+}
+
+type Limits struct {
+	MinStakeTon          float64  `json:"min_stake_ton"`
+	MaxStakeTon          float64  `json:"max_stake_ton"`
+	MaxBetItems          int      `json:"max_bet_items"`
+	UserRoundCapTon      float64  `json:"user_round_cap_ton"`
+	MaxRoundLiabilityTon float64  `json:"max_round_liability_ton"`
+	CancelCutoffSeconds  int      `json:"cancel_cutoff_seconds"`
+	ItemTypes            []string `json:"item_types,omitempty"`
+}
+
+func (l Limits) Validate() error {
+	if l.MinStakeTon <= 0 {
+		return fmt.Errorf("min_stake_ton must be positive")
+	}
+	if l.MaxStakeTon < 0 || (l.MaxStakeTon > 0 && l.MaxStakeTon < l.MinStakeTon) {
+		return fmt.Errorf("max_stake_ton must be 0 or at least min_stake_ton")
+	}
+	if l.MaxBetItems <= 0 {
+		return fmt.Errorf("max_bet_items must be positive")
+	}
+	if l.UserRoundCapTon < 0 {
+		return fmt.Errorf("user_round_cap_ton must not be negative")
+	}
+	if l.MaxRoundLiabilityTon < 0 {
+		return fmt.Errorf("max_round_liability_ton must not be negative")
+	}
+	if l.CancelCutoffSeconds < 0 {
+		return fmt.Errorf("cancel_cutoff_seconds must not be negative")
+	}
+	return nil
+}
+
+func (e *Engine) Limits() Limits {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.limits
+}
+
+func (e *Engine) nextSeriesMultiplierLocked(s *SeriesState) float64 {
+	if s.Wins == 0 {
+		return e.seriesFirstMultiplier
+	}
+	return s.Multiplier * e.seriesStepMultiplier
+}
+
+func (e *Engine) userRoundStakeLocked(userID int64) float64 {
+	total := e.bets.UserStake(e.gameID, userID)
+
+	if s, ok := e.series[userID]; ok && s != nil && s.Active && s.Wins > 0 &&
+		s.Stage == SeriesStageInRound && s.RoundGameID == e.gameID {
+		total += claimableForSeries(s)
+	}
+	return total
+}
+
+func (e *Engine) exposureLocked() risk.Exposure {
+	x := risk.NewExposure(e.gameID)
+	for _, m := range e.modes {
+		m.Exposure(e, &x)
+	}
+	return x
+}
+
+func (e *Engine) checkStakeLocked(stake float64, items []ItemRef) string {
+	if len(items) > e.limits.MaxBetItems {
+		return ReasonTooManyItems
+	}
+	if len(e.limits.ItemTypes) > 0 {
+		for _, it := range items {
+			if !slices.Contains(e.limits.ItemTypes, it.Type) {
+				return ReasonItemType
+			}
+		}
+	}
+	if stake <= 0 || stake < e.limits.MinStakeTon {
+		return ReasonStakeBelowMin
+	}
+	if e.limits.MaxStakeTon > 0 && stake > e.limits.MaxStakeTon {
+		return ReasonStakeAboveMax
+	}
+	return ""
+}
+
+func (e *Engine) overUserCapLocked(userID int64, stake float64) bool {
+	return e.limits.UserRoundCapTon > 0 && e.userRoundStakeLocked(userID)+stake > e.limits.UserRoundCapTon
+}
+
+func (e *Engine) checkExposureLocked(userID int64, payout float64) string {
+	x := e.exposureLocked()
+	if e.limits.MaxRoundLiabilityTon > 0 && x.Worst() > e.limits.MaxRoundLiabilityTon {
+		return ReasonHouseLiability
+	}
+	return e.risk.Check(x, userID, payout)
+}
+
+func (e *Engine) publishExposureLocked() {
+	e.risk.Publish(e.exposureLocked())
+}
+
+func (e *Engine) Risk() *risk.Monitor {
+	return e.risk
+}
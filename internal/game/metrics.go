@@ -0,0 +1,40 @@
+package game
+
+import "CoinFlip/internal/metrics"
+
+func (e *Engine) observeRoundLocked(pr PayoutResult, seriesRes map[int64]SeriesRoundResult) {
+	items := 0
+	stake := 0.0
+	for _, ub := range e.bets.Snapshot(e.gameID) {
+		for _, b := range ub.Bets {
+			items++
+			stake += b.BetItem.CostTon
+		}
+	}
+
+	metrics.RoundBets.Observe(float64(items))
+	metrics.RoundStakeTon.Observe(stake)
+	metrics.StakeTon.Add(stake)
+	metrics.HousePnLTon.Add(stake)
+
+	for _, st := range pr.Settlements {
+		if st.Paid > 0 {
+			metrics.PayoutTon.WithLabelValues(st.Mode).Add(st.Paid)
+			metrics.HousePnLTon.Sub(st.Paid)
+		}
+	}
+
+	if pr.Jackpot != nil {
+		metrics.HousePnLTon.Sub(pr.Jackpot.Contribution)
+	}
+
+	for _, res := range seriesRes {
+		metrics.SeriesOutcomes.WithLabelValues(res.Outcome).Inc()
+	}
+}
+
+func observeSeriesCashout(payout float64) {
+	metrics.SeriesOutcomes.WithLabelValues("cashout").Inc()
+	metrics.PayoutTon.WithLabelValues("series_cashout").Add(payout)
+	metrics.HousePnLTon.Sub(payout)
+}
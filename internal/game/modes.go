@@ -0,0 +1,202 @@
+package game
+
+import (
+	"CoinFlip/internal/risk"
+	"CoinFlip/internal/rng"
+	"slices"
+	"sync"
+)
+
+type Outcome struct {
+	Side  Side
+	Faces []Side
+}
+
+type Settlement struct {
+	Mode    string
+	Winning map[string]float64
+	Refund  bool
+	Pushed  []string
+	Paid    float64
+}
+
+type GameMode interface {
+	Name() string
+	NormalizeSide(e *Engine, side string) (string, bool)
+	PlaceBet(e *Engine, userID int64, side string, stake float64, items []ItemRef) (int, string)
+	CancelBet(e *Engine, userID int64) (CancelledBet, bool, string)
+	Resolve(e *Engine, seed []byte) Outcome
+	Settle(e *Engine, out Outcome, pr *PayoutResult)
+	AfterRound(e *Engine, out Outcome) map[int64]SeriesRoundResult
+	Exposure(e *Engine, x *risk.Exposure)
+}
+
+var (
+	modesMu sync.RWMutex
+	modes   = make(map[string]GameMode)
+)
+
+func RegisterMode(m GameMode) {
+	modesMu.Lock()
+	defer modesMu.Unlock()
+
+	modes[m.Name()] = m
+}
+
+func LookupMode(name string) (GameMode, bool) {
+	modesMu.RLock()
+	defer modesMu.RUnlock()
+
+	m, ok := modes[name]
+	return m, ok
+}
+
+func init() {
+	RegisterMode(seriesMode{})
+	RegisterMode(singleMode{})
+	RegisterMode(poolMode{})
+	RegisterMode(multiMode{})
+}
+
+func modeNamesFor(t Tunables) []string {
+	switch {
+	case t.PayoutMode == PayoutPool:
+		return []string{ModePool}
+	case t.CoinCount > 1:
+		return []string{ModeMulti}
+	default:
+		return []string{ModeSeries, ModeSingle}
+	}
+}
+
+func modesFor(t Tunables) []GameMode {
+	out := make([]GameMode, 0, 2)
+	for _, name := range modeNamesFor(t) {
+		if m, ok := LookupMode(name); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func (e *Engine) modeLocked(name string) (GameMode, bool) {
+	if name == "" && len(e.modes) > 0 {
+		return e.modes[0], true
+	}
+	for _, m := range e.modes {
+		if m.Name() == name {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+func (e *Engine) betModeLocked() string {
+	if len(e.modes) == 0 {
+		return ""
+	}
+	return e.modes[0].Name()
+}
+
+func (e *Engine) BetMode() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.betModeLocked()
+}
+
+func (e *Engine) Modes() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.modeNamesLocked()
+}
+
+func (e *Engine) modeNamesLocked() []string {
+	out := make([]string, 0, len(e.modes))
+	for _, m := range e.modes {
+		out = append(out, m.Name())
+	}
+	return out
+}
+
+func (e *Engine) NormalizeBet(mode, side string) (string, string, bool, string) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	m, ok := e.modeLocked(mode)
+	if !ok {
+		return "", "", false, "bad mode"
+	}
+	side, ok = m.NormalizeSide(e, side)
+	if !ok {
+		return "", "", false, "bad side"
+	}
+	return m.Name(), side, true, ""
+}
+
+func coinOutcome(seed []byte) Outcome {
+	if seed == nil {
+		return Outcome{Side: SideHeads}
+	}
+	return Outcome{Side: Side(rng.SideFromSeed(seed))}
+}
+
+func isCoinSide(side string) bool {
+	return side == string(SideHeads) || side == string(SideTails)
+}
+
+func (e *Engine) removeModeBetsLocked(userID int64, mode string) (CancelledBet, bool, string) {
+	gid := e.gameID
+	removed := e.bets.RemoveUser(gid, userID, mode)
+	if len(removed) == 0 {
+		return CancelledBet{}, false, "no bet in current round"
+	}
+
+	out := CancelledBet{GameID: gid, UserID: userID, Mode: mode, bets: removed}
+	for _, b := range removed {
+		out.Side = b.Side
+		out.Stake += betStakeValue(b)
+		out.Items = append(out.Items, itemRefFromBet(b))
+	}
+	return out, true, ""
+}
+
+func (e *Engine) settleFixedLocked(pr *PayoutResult, mode string, multiplier func(side string) (float64, bool), push func(side string) bool) {
+	st := Settlement{Mode: mode, Winning: make(map[string]float64)}
+	seen := false
+
+	for _, ub := range e.bets.Snapshot(e.gameID) {
+		for _, b := range ub.Bets {
+			if b.Mode != mode {
+				continue
+			}
+			seen = true
+
+			stake := betStakeValue(b)
+
+			r := pr.Results[b.UserID]
+			r.UserID = b.UserID
+			r.Stake += stake
+
+			if push != nil && push(b.Side) {
+				if !slices.Contains(st.Pushed, b.Side) {
+					st.Pushed = append(st.Pushed, b.Side)
+				}
+				r.Push = true
+			} else if mult, win := multiplier(b.Side); win {
+				st.Winning[b.Side] = mult
+				st.Paid += stake * mult
+				r.Win = true
+				r.Payout += stake * mult
+				r.Multiplier = max(r.Multiplier, mult)
+			}
+
+			pr.Results[b.UserID] = r
+		}
+	}
+
+	if seen {
+		pr.Settlements = append(pr.Settlements, st)
+	}
+}
@@ -0,0 +1,222 @@
+package game
+
+import (
+	"CoinFlip/internal/config"
+	"CoinFlip/internal/risk"
+	"CoinFlip/internal/rng"
+	"context"
+	"encoding/hex"
+	"maps"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func testEngine(t *testing.T, tune func(cfg *config.Config)) *Engine {
+	t.Helper()
+
+	cfg := config.Defaults()
+	if tune != nil {
+		tune(cfg)
+	}
+	e, err := NewEngine(context.Background(), cfg, Table{ID: 1}, NewGameIDs(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.phase = PhaseBetting
+	e.timer = e.timings.BettingTime
+	return e
+}
+
+func seedWhere(t *testing.T, match func(seed []byte) bool) string {
+	t.Helper()
+
+	for i := range 10000 {
+		seed := []byte("seed-" + strconv.Itoa(i))
+		if match(seed) {
+			return hex.EncodeToString(seed)
+		}
+	}
+	t.Fatal("no matching seed")
+	return ""
+}
+
+func playRound(e *Engine, seedHex string) PayoutResult {
+	e.seedHex = seedHex
+	gameID := e.gameID
+	e.nextPhaseLocked(true)
+	e.nextPhaseLocked(true)
+	return e.payouts[gameID]
+}
+
+type fakeMode struct {
+	name   string
+	out    Outcome
+	after  map[int64]SeriesRoundResult
+	cancel string
+	calls  []string
+}
+
+func (f *fakeMode) Name() string { return f.name }
+
+func (f *fakeMode) NormalizeSide(_ *Engine, side string) (string, bool) { return side, true }
+
+func (f *fakeMode) PlaceBet(*Engine, int64, string, float64, []ItemRef) (int, string) {
+	return 0, "not supported"
+}
+
+func (f *fakeMode) CancelBet(_ *Engine, userID int64) (CancelledBet, bool, string) {
+	f.calls = append(f.calls, "cancel")
+	if f.cancel != "" {
+		return CancelledBet{}, false, f.cancel
+	}
+	return CancelledBet{UserID: userID, Mode: f.name}, true, ""
+}
+
+func (f *fakeMode) Resolve(*Engine, []byte) Outcome {
+	f.calls = append(f.calls, "resolve")
+	return f.out
+}
+
+func (f *fakeMode) Settle(*Engine, Outcome, *PayoutResult) {
+	f.calls = append(f.calls, "settle")
+}
+
+func (f *fakeMode) AfterRound(*Engine, Outcome) map[int64]SeriesRoundResult {
+	f.calls = append(f.calls, "after")
+	return f.after
+}
+
+func (f *fakeMode) Exposure(*Engine, *risk.Exposure) {}
+
+func TestRegisteredModes(t *testing.T) {
+	for _, name := range []string{ModeSeries, ModeSingle, ModePool, ModeMulti} {
+		m, ok := LookupMode(name)
+		if !ok || m.Name() != name {
+			t.Fatalf("LookupMode(%q) = %v, %v", name, m, ok)
+		}
+	}
+	if _, ok := LookupMode("roulette"); ok {
+		t.Fatal("LookupMode(roulette) found a mode")
+	}
+
+	tests := []struct {
+		name string
+		t    Tunables
+		want []string
+	}{
+		{"fixed single coin", Tunables{PayoutMode: PayoutFixed, CoinCount: 1}, []string{ModeSeries, ModeSingle}},
+		{"pool", Tunables{PayoutMode: PayoutPool, CoinCount: 1}, []string{ModePool}},
+		{"multi coin", Tunables{PayoutMode: PayoutFixed, CoinCount: 3}, []string{ModeMulti}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0, 2)
+			for _, m := range modesFor(tt.t) {
+				got = append(got, m.Name())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("modesFor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineModeDispatch(t *testing.T) {
+	e := testEngine(t, nil)
+	first := &fakeMode{
+		name:  "first",
+		out:   Outcome{Side: SideTails, Faces: []Side{SideTails, SideHeads}},
+		after: map[int64]SeriesRoundResult{1: {UserID: 1, Outcome: "win"}},
+	}
+	second := &fakeMode{
+		name:  "second",
+		out:   Outcome{Side: SideHeads},
+		after: map[int64]SeriesRoundResult{2: {UserID: 2, Outcome: "lose"}},
+	}
+	e.modes = []GameMode{first, second}
+
+	gameID := e.gameID
+	playRound(e, hex.EncodeToString([]byte("seed")))
+
+	if e.resultSide != SideTails || e.payouts[gameID].ResultSide != SideTails {
+		t.Fatalf("result = %s, want the first mode's outcome", e.resultSide)
+	}
+	if want := []string{"resolve", "after", "settle"}; !slices.Equal(first.calls, want) {
+		t.Fatalf("first mode calls = %v, want %v", first.calls, want)
+	}
+	if want := []string{"after", "settle"}; !slices.Equal(second.calls, want) {
+		t.Fatalf("second mode calls = %v, want %v", second.calls, want)
+	}
+	got, ok := e.SeriesResultsForGame(gameID)
+	if !ok || !slices.Equal(slices.Sorted(maps.Keys(got)), []int64{1, 2}) {
+		t.Fatalf("series results = %v, want users 1 and 2", got)
+	}
+}
+
+func TestEngineCancelDispatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   string
+		second  string
+		ok      bool
+		mode    string
+		reason  string
+		reached bool
+	}{
+		{"first mode cancels", "", "", true, "first", "", false},
+		{"falls through to second", "no bet in current round", "", true, "second", "", true},
+		{"keeps specific reason", "series continuation cannot be cancelled", "no bet in current round", false, "", "series continuation cannot be cancelled", true},
+		{"nothing to cancel", "no bet in current round", "no bet in current round", false, "", "no bet in current round", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEngine(t, nil)
+			first := &fakeMode{name: "first", cancel: tt.first}
+			second := &fakeMode{name: "second", cancel: tt.second}
+			e.modes = []GameMode{first, second}
+
+			out, ok, reason := e.CancelBet(7)
+			if ok != tt.ok || reason != tt.reason || out.Mode != tt.mode {
+				t.Fatalf("CancelBet = %q, %v, %q; want %q, %v, %q", out.Mode, ok, reason, tt.mode, tt.ok, tt.reason)
+			}
+			if reached := len(second.calls) > 0; reached != tt.reached {
+				t.Fatalf("second mode reached = %v, want %v", reached, tt.reached)
+			}
+		})
+	}
+}
+
+func TestEngineNoModeFallback(t *testing.T) {
+	heads := seedWhere(t, func(seed []byte) bool { return rng.SideFromSeed(seed) == string(SideHeads) })
+	tails := seedWhere(t, func(seed []byte) bool { return rng.SideFromSeed(seed) == string(SideTails) })
+
+	tests := []struct {
+		name string
+		seed string
+		want Side
+	}{
+		{"heads seed", heads, SideHeads},
+		{"tails seed", tails, SideTails},
+		{"undecodable seed", "not-hex", SideHeads},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEngine(t, nil)
+			e.modes = nil
+			e.seedHex = tt.seed
+
+			if _, ok, reason := e.CancelBet(7); ok || reason != "no bet in current round" {
+				t.Fatalf("CancelBet without modes = %v, %q", ok, reason)
+			}
+
+			e.nextPhaseLocked(true)
+			if e.phase != PhaseGettingResult {
+				t.Fatalf("phase = %s, want %s", e.phase, PhaseGettingResult)
+			}
+			if e.resultSide != tt.want || e.faces != nil {
+				t.Fatalf("outcome = %s %v, want %s without faces", e.resultSide, e.faces, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package game
+
+import (
+	"CoinFlip/internal/risk"
+	"CoinFlip/internal/rng"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	ModeMulti = "multi"
+	MaxCoins  = 16
+)
+
+const (
+	TargetExact   = "exact"
+	TargetRange   = "range"
+	TargetAllSame = "all_same"
+)
+
+type CoinTarget struct {
+	Kind string
+	Min  int
+	Max  int
+}
+
+func ParseCoinTarget(s string, coins int) (CoinTarget, bool) {
+	if s == TargetAllSame {
+		return CoinTarget{Kind: TargetAllSame}, true
+	}
+
+	kind, arg, ok := strings.Cut(s, ":")
+	if !ok {
+		return CoinTarget{}, false
+	}
+
+	var t CoinTarget
+	switch kind {
+	case TargetExact:
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return CoinTarget{}, false
+		}
+		t = CoinTarget{Kind: TargetExact, Min: n, Max: n}
+	case TargetRange:
+		lo, hi, ok := strings.Cut(arg, "-")
+		if !ok {
+			return CoinTarget{}, false
+		}
+		a, err1 := strconv.Atoi(lo)
+		b, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil {
+			return CoinTarget{}, false
+		}
+		t = CoinTarget{Kind: TargetRange, Min: a, Max: b}
+	default:
+		return CoinTarget{}, false
+	}
+
+	if t.Min < 0 || t.Max > coins || t.Min > t.Max {
+		return CoinTarget{}, false
+	}
+	return t, true
+}
+
+func (t CoinTarget) String() string {
+	switch t.Kind {
+	case TargetAllSame:
+		return TargetAllSame
+	case TargetExact:
+		return TargetExact + ":" + strconv.Itoa(t.Min)
+	default:
+		return TargetRange + ":" + strconv.Itoa(t.Min) + "-" + strconv.Itoa(t.Max)
+	}
+}
+
+func (t CoinTarget) Wins(heads, coins int) bool {
+	if t.Kind == TargetAllSame {
+		return heads == 0 || heads == coins
+	}
+	return heads >= t.Min && heads <= t.Max
+}
+
+func (t CoinTarget) Probability(coins int) float64 {
+	p := 0.0
+	for k := 0; k <= coins; k++ {
+		if t.Wins(k, coins) {
+			p += headsProbability(k, coins)
+		}
+	}
+	return p
+}
+
+func (t CoinTarget) Multiplier(coins int, edgePercent float64) float64 {
+	return coinMultiplier(t.Probability(coins), edgePercent)
+}
+
+func headsProbability(k, coins int) float64 {
+	c := 1.0
+	for i := 1; i <= k; i++ {
+		c = c * float64(coins-k+i) / float64(i)
+	}
+	return c / math.Pow(2, float64(coins))
+}
+
+func coinMultiplier(p, edgePercent float64) float64 {
+	if p <= 0 {
+		return 0
+	}
+	return math.Floor((1-edgePercent/100)/p*100) / 100
+}
+
+type CoinOdds struct {
+	Coins       int       `json:"coins"`
+	EdgePercent float64   `json:"edge_percent"`
+	Exact       []float64 `json:"exact"`
+	AllSame     float64   `json:"all_same"`
+}
+
+func NewCoinOdds(coins int, edgePercent float64) CoinOdds {
+	out := CoinOdds{
+		Coins:       coins,
+		EdgePercent: edgePercent,
+		Exact:       make([]float64, 0, coins+1),
+		AllSame:     CoinTarget{Kind: TargetAllSame}.Multiplier(coins, edgePercent),
+	}
+	for k := 0; k <= coins; k++ {
+		out.Exact = append(out.Exact, coinMultiplier(headsProbability(k, coins), edgePercent))
+	}
+	return out
+}
+
+func facesFromSeed(seed []byte, coins int) []Side {
+	faces := rng.FacesFromSeed(seed, coins)
+	out := make([]Side, 0, len(faces))
+	for _, f := range faces {
+		out = append(out, Side(f))
+	}
+	return out
+}
+
+func (s Snapshot) FaceNames() []string {
+	if len(s.Faces) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(s.Faces))
+	for _, f := range s.Faces {
+		out = append(out, string(f))
+	}
+	return out
+}
+
+func HeadsCount(faces []Side) int {
+	n := 0
+	for _, f := range faces {
+		if f == SideHeads {
+			n++
+		}
+	}
+	return n
+}
+
+func (e *Engine) coinOddsLocked() *CoinOdds {
+	if e.coinCount <= 1 {
+		return nil
+	}
+	odds := NewCoinOdds(e.coinCount, e.coinEdgePercent)
+	return &odds
+}
+
+type multiMode struct{}
+
+func (multiMode) Name() string { return ModeMulti }
+
+func (multiMode) NormalizeSide(e *Engine, side string) (string, bool) {
+	t, ok := ParseCoinTarget(side, e.coinCount)
+	if !ok || t.Multiplier(e.coinCount, e.coinEdgePercent) <= 1 {
+		return "", false
+	}
+	return t.String(), true
+}
+
+func (multiMode) PlaceBet(e *Engine, userID int64, side string, stake float64, items []ItemRef) (int, string) {
+	t, _ := ParseCoinTarget(side, e.coinCount)
+	accepted := e.bets.Add(e.gameID, userID, t.String(), ModeMulti, items)
+	if reason := e.checkExposureLocked(userID, stake*t.Multiplier(e.coinCount, e.coinEdgePercent)); reason != "" {
+		e.bets.RemoveLastN(e.gameID, userID, accepted)
+		return 0, reason
+	}
+	e.publishExposureLocked()
+	return accepted, ""
+}
+
+func (multiMode) CancelBet(e *Engine, userID int64) (CancelledBet, bool, string) {
+	return e.removeModeBetsLocked(userID, ModeMulti)
+}
+
+func (multiMode) Resolve(e *Engine, seed []byte) Outcome {
+	if seed == nil {
+		return coinOutcome(nil)
+	}
+	faces := facesFromSeed(seed, e.coinCount)
+	return Outcome{Side: faces[0], Faces: faces}
+}
+
+func (multiMode) Settle(e *Engine, out Outcome, pr *PayoutResult) {
+	heads := HeadsCount(out.Faces)
+	pr.Faces = slices.Clone(out.Faces)
+
+	e.settleFixedLocked(pr, ModeMulti, func(side string) (float64, bool) {
+		t, ok := ParseCoinTarget(side, e.coinCount)
+		if !ok || !t.Wins(heads, e.coinCount) {
+			return 0, false
+		}
+		return t.Multiplier(e.coinCount, e.coinEdgePercent), true
+	}, nil)
+}
+
+func (multiMode) AfterRound(*Engine, Outcome) map[int64]SeriesRoundResult { return nil }
+
+func (multiMode) Exposure(e *Engine, x *risk.Exposure) {
+	for _, ub := range e.bets.Snapshot(e.gameID) {
+		for _, b := range ub.Bets {
+			if b.Mode != ModeMulti {
+				continue
+			}
+			t, ok := ParseCoinTarget(b.Side, e.coinCount)
+			if !ok {
+				continue
+			}
+			stake := betStakeValue(b)
+			payout := stake * t.Multiplier(e.coinCount, e.coinEdgePercent)
+
+			outcomes := make([]int, 0, e.coinCount+1)
+			for k := 0; k <= e.coinCount; k++ {
+				if t.Wins(k, e.coinCount) {
+					outcomes = append(outcomes, k)
+				}
+			}
+			x.AddOutcomes(b.UserID, b.Side, stake, payout, outcomes)
+		}
+	}
+}
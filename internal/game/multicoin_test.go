@@ -0,0 +1,139 @@
+package game
+
+import (
+	"CoinFlip/internal/config"
+	"maps"
+	"math"
+	"testing"
+)
+
+func TestHeadsProbability(t *testing.T) {
+	tests := []struct {
+		k, coins int
+		want     float64
+	}{
+		{0, 1, 0.5},
+		{1, 2, 0.5},
+		{2, 4, 0.375},
+		{3, 10, 120.0 / 1024},
+		{16, 16, 1.0 / 65536},
+	}
+	for _, tt := range tests {
+		if got := headsProbability(tt.k, tt.coins); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("headsProbability(%d, %d) = %v, want %v", tt.k, tt.coins, got, tt.want)
+		}
+	}
+
+	for coins := 1; coins <= MaxCoins; coins++ {
+		sum := 0.0
+		for k := 0; k <= coins; k++ {
+			sum += headsProbability(k, coins)
+		}
+		if math.Abs(sum-1) > 1e-12 {
+			t.Errorf("coins %d: probabilities sum to %v", coins, sum)
+		}
+	}
+}
+
+func TestCoinMultiplier(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		coins  int
+		edge   float64
+		want   float64
+	}{
+		{"single coin fair", "exact:1", 1, 0, 2},
+		{"single coin with edge", "exact:0", 1, 2, 1.96},
+		{"exact middle", "exact:2", 4, 0, 2.66},
+		{"exact five of ten", "exact:5", 10, 0, 4.06},
+		{"all same", TargetAllSame, 2, 2, 1.96},
+		{"full range", "range:0-4", 4, 2, 0.98},
+		{"upper half", "range:3-4", 4, 0, 3.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, ok := ParseCoinTarget(tt.target, tt.coins)
+			if !ok {
+				t.Fatalf("ParseCoinTarget(%q, %d) failed", tt.target, tt.coins)
+			}
+			if got := target.Multiplier(tt.coins, tt.edge); got != tt.want {
+				t.Fatalf("Multiplier = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := coinMultiplier(0, 2); got != 0 {
+		t.Fatalf("coinMultiplier(0) = %v, want 0", got)
+	}
+}
+
+func TestMultiCoinExposure(t *testing.T) {
+	e := testEngine(t, func(cfg *config.Config) {
+		cfg.CoinCount = 4
+		cfg.CoinEdgePercent = 0
+	})
+	if _, _, ok, reason := e.AddBet(1, "range:3-4", "", testItems(1)); !ok {
+		t.Fatal(reason)
+	}
+	if _, _, ok, reason := e.AddBet(2, "exact:2", "", testItems(2)); !ok {
+		t.Fatal(reason)
+	}
+
+	want := map[int]float64{2: 5.32, 3: 3.2, 4: 3.2}
+	rep := e.Risk().Report(10)
+	if !maps.EqualFunc(rep.Coins, want, func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }) {
+		t.Fatalf("exposure coins = %v, want %v", rep.Coins, want)
+	}
+	if math.Abs(rep.Worst-5.32) > 1e-9 {
+		t.Fatalf("worst = %v, want 5.32", rep.Worst)
+	}
+	if u := rep.Users; len(u) != 2 || u[0].UserID != 2 {
+		t.Fatalf("users = %+v, want user 2 first", u)
+	}
+}
+
+func TestMultiCoinLiabilityLimit(t *testing.T) {
+	e := testEngine(t, func(cfg *config.Config) {
+		cfg.CoinCount = 4
+		cfg.CoinEdgePercent = 0
+		cfg.MaxRoundLiabilityTon = 5
+	})
+	if _, _, ok, reason := e.AddBet(1, "exact:2", "", testItems(1)); !ok {
+		t.Fatal(reason)
+	}
+	if _, _, ok, reason := e.AddBet(2, "exact:2", "", testItems(1)); ok || reason != ReasonHouseLiability {
+		t.Fatalf("AddBet over liability = %v, %q", ok, reason)
+	}
+	if _, _, ok, reason := e.AddBet(2, "exact:0", "", testItems(0.3)); !ok {
+		t.Fatalf("AddBet on another heads count: %s", reason)
+	}
+}
+
+func TestMultiCoinSettle(t *testing.T) {
+	e := testEngine(t, func(cfg *config.Config) {
+		cfg.CoinCount = 4
+		cfg.CoinEdgePercent = 0
+	})
+	seed := seedWhere(t, func(seed []byte) bool { return HeadsCount(facesFromSeed(seed, 4)) == 2 })
+	if _, _, ok, reason := e.AddBet(1, "range:3-4", "", testItems(1)); !ok {
+		t.Fatal(reason)
+	}
+	if _, _, ok, reason := e.AddBet(2, "exact:2", "", testItems(2)); !ok {
+		t.Fatal(reason)
+	}
+
+	pr := playRound(e, seed)
+	if len(pr.Faces) != 4 || HeadsCount(pr.Faces) != 2 {
+		t.Fatalf("faces = %v, want two heads of four", pr.Faces)
+	}
+	if r := pr.Results[1]; r.Win || r.Payout != 0 {
+		t.Fatalf("range bet = %+v, want a loss", r)
+	}
+	if r := pr.Results[2]; !r.Win || math.Abs(r.Payout-5.32) > 1e-9 || r.Multiplier != 2.66 {
+		t.Fatalf("exact bet = %+v, want a 2.66 win", r)
+	}
+	if st := pr.Settlements; len(st) != 1 || st[0].Mode != ModeMulti || st[0].Winning["exact:2"] != 2.66 {
+		t.Fatalf("settlements = %+v", st)
+	}
+}
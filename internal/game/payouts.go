@@ -1,63 +1,44 @@
 package game
 
-const singleMultiplier = 1.96
-
 type UserSingleResult struct {
 	UserID     int64   `json:"user_id"`
 	Stake      float64 `json:"stake"`
 	Payout     float64 `json:"payout"`
 	Multiplier float64 `json:"multiplier"`
 	Win        bool    `json:"win"`
+	Push       bool    `json:"push,omitempty"`
 }
 
 type PayoutResult struct {
-	GameID     int                        `json:"game_id"`
-	Hash       string                     `json:"hash"`
-	ResultSide Side                       `json:"result_side"`
-	Results    map[int64]UserSingleResult `json:"results"`
+	GameID      int                        `json:"game_id"`
+	Hash        string                     `json:"hash"`
+	ResultSide  Side                       `json:"result_side"`
+	Results     map[int64]UserSingleResult `json:"results"`
+	Pool        *PoolOdds                  `json:"pool,omitempty"`
+	Refunded    bool                       `json:"refunded,omitempty"`
+	Faces       []Side                     `json:"faces,omitempty"`
+	Jackpot     *JackpotResult             `json:"jackpot,omitempty"`
+	Settlements []Settlement               `json:"-"`
 }
 
 func betStakeValue(b BetSnapshot) float64 {
 	if b.BetItem.CostTon > 0 {
 		return b.BetItem.CostTon
 	}
-	return 1.0
+	return 0
 }
 
-func (e *Engine) calculatePayoutsLocked() PayoutResult {
-	gid := e.gameID
-	result := e.resultSide
-
-	out := PayoutResult{
-		GameID:     gid,
+func (e *Engine) calculatePayoutsLocked(out Outcome) PayoutResult {
+	pr := PayoutResult{
+		GameID:     e.gameID,
 		Hash:       e.hash,
-		ResultSide: result,
+		ResultSide: out.Side,
 		Results:    make(map[int64]UserSingleResult),
 	}
 
-	snap := e.bets.Snapshot(gid)
-
-	for _, ub := range snap {
-		for _, b := range ub.Bets {
-			if b.Mode != "single" {
-				continue
-			}
-
-			stake := betStakeValue(b)
-
-			r := out.Results[b.UserID]
-			r.UserID = b.UserID
-			r.Stake += stake
-
-			if Side(b.Side) == result {
-				r.Win = true
-				r.Payout += stake * singleMultiplier
-				r.Multiplier = singleMultiplier
-			}
-
-			out.Results[b.UserID] = r
-		}
+	for _, m := range e.modes {
+		m.Settle(e, out, &pr)
 	}
 
-	return out
+	return pr
 }
@@ -0,0 +1,150 @@
+package game
+
+import (
+	"CoinFlip/internal/risk"
+	"math"
+)
+
+const (
+	PayoutFixed = "fixed"
+	PayoutPool  = "pool"
+)
+
+const ModePool = "pool"
+
+type PoolOdds struct {
+	GameID          int     `json:"game_id"`
+	HeadsTon        float64 `json:"heads_ton"`
+	TailsTon        float64 `json:"tails_ton"`
+	RakePercent     float64 `json:"rake_percent"`
+	HeadsMultiplier float64 `json:"heads_multiplier"`
+	TailsMultiplier float64 `json:"tails_multiplier"`
+}
+
+func poolMultiplier(win, lose, rakePercent float64) float64 {
+	if win <= 0 {
+		return 0
+	}
+	if lose <= 0 {
+		return 1
+	}
+	m := 1 + lose*(1-rakePercent/100)/win
+	return math.Floor(m*1e8) / 1e8
+}
+
+func newPoolOdds(gameID int, heads, tails, rakePercent float64) PoolOdds {
+	return PoolOdds{
+		GameID:          gameID,
+		HeadsTon:        heads,
+		TailsTon:        tails,
+		RakePercent:     rakePercent,
+		HeadsMultiplier: poolMultiplier(heads, tails, rakePercent),
+		TailsMultiplier: poolMultiplier(tails, heads, rakePercent),
+	}
+}
+
+func (o PoolOdds) Refund() bool {
+	return o.HeadsTon <= 0 || o.TailsTon <= 0
+}
+
+func (o PoolOdds) Multiplier(side Side) float64 {
+	if side == SideHeads {
+		return o.HeadsMultiplier
+	}
+	return o.TailsMultiplier
+}
+
+func (e *Engine) PayoutMode() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.payoutMode
+}
+
+func (e *Engine) PoolOdds() (PoolOdds, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.betModeLocked() != ModePool {
+		return PoolOdds{}, false
+	}
+	return e.poolOddsLocked(), true
+}
+
+func (e *Engine) poolOddsLocked() PoolOdds {
+	heads, tails := 0.0, 0.0
+	for _, ub := range e.bets.Snapshot(e.gameID) {
+		for _, b := range ub.Bets {
+			if b.Mode != ModePool {
+				continue
+			}
+			if Side(b.Side) == SideHeads {
+				heads += betStakeValue(b)
+			} else {
+				tails += betStakeValue(b)
+			}
+		}
+	}
+	return newPoolOdds(e.gameID, heads, tails, e.poolRakePercent)
+}
+
+type poolMode struct{}
+
+func (poolMode) Name() string { return ModePool }
+
+func (poolMode) NormalizeSide(_ *Engine, side string) (string, bool) {
+	return side, isCoinSide(side)
+}
+
+func (poolMode) PlaceBet(e *Engine, userID int64, side string, _ float64, items []ItemRef) (int, string) {
+	if prev := e.bets.UserSide(e.gameID, userID); prev != "" && prev != side {
+		return 0, "pool bets must stay on one side"
+	}
+	return e.bets.Add(e.gameID, userID, side, ModePool, items), ""
+}
+
+func (poolMode) CancelBet(e *Engine, userID int64) (CancelledBet, bool, string) {
+	return e.removeModeBetsLocked(userID, ModePool)
+}
+
+func (poolMode) Resolve(_ *Engine, seed []byte) Outcome {
+	return coinOutcome(seed)
+}
+
+func (poolMode) Settle(e *Engine, out Outcome, pr *PayoutResult) {
+	odds := e.poolOddsLocked()
+	refund := odds.Refund()
+	pr.Pool = &odds
+	pr.Refunded = refund
+
+	if refund {
+		paid := 0.0
+		for _, ub := range e.bets.Snapshot(e.gameID) {
+			for _, b := range ub.Bets {
+				if b.Mode != ModePool {
+					continue
+				}
+				stake := betStakeValue(b)
+				paid += stake
+
+				r := pr.Results[b.UserID]
+				r.UserID = b.UserID
+				r.Stake += stake
+				r.Payout += stake
+				r.Multiplier = 1
+				pr.Results[b.UserID] = r
+			}
+		}
+		pr.Settlements = append(pr.Settlements, Settlement{Mode: ModePool, Refund: true, Paid: paid})
+		return
+	}
+
+	mult := odds.Multiplier(out.Side)
+	e.settleFixedLocked(pr, ModePool, func(side string) (float64, bool) {
+		return mult, Side(side) == out.Side
+	}, nil)
+}
+
+func (poolMode) AfterRound(*Engine, Outcome) map[int64]SeriesRoundResult { return nil }
+
+func (poolMode) Exposure(*Engine, *risk.Exposure) {}
@@ -0,0 +1,125 @@
+package game
+
+import (
+	"CoinFlip/internal/config"
+	"math"
+	"testing"
+)
+
+func TestPoolOdds(t *testing.T) {
+	tests := []struct {
+		name   string
+		heads  float64
+		tails  float64
+		rake   float64
+		refund bool
+		mHeads float64
+		mTails float64
+	}{
+		{"even pool", 10, 10, 5, false, 1.95, 1.95},
+		{"no rake", 30, 10, 0, false, 1.33333333, 4},
+		{"uneven with rake", 10, 40, 50, false, 3, 1.125},
+		{"empty tails", 10, 0, 5, true, 1, 0},
+		{"empty pool", 0, 0, 5, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newPoolOdds(1, tt.heads, tt.tails, tt.rake)
+			if o.Refund() != tt.refund {
+				t.Fatalf("Refund = %v, want %v", o.Refund(), tt.refund)
+			}
+			if got := o.Multiplier(SideHeads); got != tt.mHeads {
+				t.Fatalf("heads multiplier = %v, want %v", got, tt.mHeads)
+			}
+			if got := o.Multiplier(SideTails); got != tt.mTails {
+				t.Fatalf("tails multiplier = %v, want %v", got, tt.mTails)
+			}
+		})
+	}
+}
+
+func TestPoolSettle(t *testing.T) {
+	type bet struct {
+		user  int64
+		side  Side
+		stake float64
+	}
+	heads := seedWhere(t, func(seed []byte) bool { return coinOutcome(seed).Side == SideHeads })
+
+	tests := []struct {
+		name    string
+		bets    []bet
+		refund  bool
+		payouts map[int64]float64
+		paid    float64
+	}{
+		{
+			"winners split losing side",
+			[]bet{{1, SideHeads, 3}, {2, SideTails, 1}, {3, SideHeads, 1}},
+			false,
+			map[int64]float64{1: 3 * 1.2375, 2: 0, 3: 1.2375},
+			4 * 1.2375,
+		},
+		{
+			"empty tails refunds heads",
+			[]bet{{1, SideHeads, 2}, {2, SideHeads, 1}},
+			true,
+			map[int64]float64{1: 2, 2: 1},
+			3,
+		},
+		{
+			"empty heads refunds tails",
+			[]bet{{1, SideTails, 2}},
+			true,
+			map[int64]float64{1: 2},
+			2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEngine(t, func(cfg *config.Config) { cfg.PayoutMode = PayoutPool })
+			for _, b := range tt.bets {
+				if _, _, ok, reason := e.AddBet(b.user, string(b.side), "", testItems(b.stake)); !ok {
+					t.Fatalf("AddBet(%d): %s", b.user, reason)
+				}
+			}
+
+			pr := playRound(e, heads)
+			if pr.Refunded != tt.refund || pr.Pool == nil || pr.Pool.Refund() != tt.refund {
+				t.Fatalf("Refunded = %v (pool %+v), want %v", pr.Refunded, pr.Pool, tt.refund)
+			}
+			for uid, want := range tt.payouts {
+				if got := pr.Results[uid].Payout; math.Abs(got-want) > 1e-9 {
+					t.Fatalf("user %d payout = %v, want %v", uid, got, want)
+				}
+				if tt.refund && pr.Results[uid].Multiplier != 1 {
+					t.Fatalf("user %d refund multiplier = %v, want 1", uid, pr.Results[uid].Multiplier)
+				}
+			}
+
+			if len(pr.Settlements) != 1 {
+				t.Fatalf("settlements = %+v, want one pool settlement", pr.Settlements)
+			}
+			st := pr.Settlements[0]
+			if st.Mode != ModePool || st.Refund != tt.refund || math.Abs(st.Paid-tt.paid) > 1e-9 {
+				t.Fatalf("settlement = %+v, want refund %v paid %v", st, tt.refund, tt.paid)
+			}
+			if tt.refund && len(st.Winning) != 0 {
+				t.Fatalf("refund settlement has winners %v", st.Winning)
+			}
+		})
+	}
+}
+
+func TestPoolOneSidePerUser(t *testing.T) {
+	e := testEngine(t, func(cfg *config.Config) { cfg.PayoutMode = PayoutPool })
+	if _, _, ok, reason := e.AddBet(1, string(SideHeads), "", testItems(1)); !ok {
+		t.Fatal(reason)
+	}
+	if _, _, ok, reason := e.AddBet(1, string(SideTails), "", testItems(1)); ok || reason != "pool bets must stay on one side" {
+		t.Fatalf("AddBet on other side = %v, %q", ok, reason)
+	}
+	if _, _, ok, reason := e.AddBet(1, string(SideHeads), "", testItems(1)); !ok {
+		t.Fatalf("AddBet on same side: %s", reason)
+	}
+}
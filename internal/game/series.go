@@ -0,0 +1,181 @@
+package game
+
+import (
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/risk"
+)
+
+const ModeSeries = "series"
+
+type seriesMode struct{}
+
+func (seriesMode) Name() string { return ModeSeries }
+
+func (seriesMode) NormalizeSide(_ *Engine, side string) (string, bool) {
+	return side, isCoinSide(side)
+}
+
+func (seriesMode) PlaceBet(e *Engine, userID int64, side string, stake float64, items []ItemRef) (int, string) {
+	if s, exists := e.series[userID]; exists && s != nil && s.Active {
+		return 0, "active series already exists"
+	}
+
+	e.series[userID] = &SeriesState{
+		UserID:      userID,
+		Side:        side,
+		Stake:       stake,
+		Wins:        0,
+		Multiplier:  1.0,
+		Stage:       SeriesStageInRound,
+		RoundGameID: e.gameID,
+		Active:      true,
+	}
+	if reason := e.checkExposureLocked(userID, stake*e.seriesFirstMultiplier); reason != "" {
+		delete(e.series, userID)
+		return 0, reason
+	}
+
+	accepted := e.bets.Add(e.gameID, userID, side, ModeSeries, items)
+	e.publishExposureLocked()
+	return accepted, ""
+}
+
+func (seriesMode) CancelBet(e *Engine, userID int64) (CancelledBet, bool, string) {
+	s, exists := e.series[userID]
+	if !exists || s == nil || !s.Active || s.Stage != SeriesStageInRound || s.RoundGameID != e.gameID {
+		return CancelledBet{}, false, "no bet in current round"
+	}
+	if s.Wins > 0 {
+		return CancelledBet{}, false, "series continuation cannot be cancelled"
+	}
+
+	out, ok, reason := e.removeModeBetsLocked(userID, ModeSeries)
+	if !ok {
+		return out, false, reason
+	}
+	out.Side = s.Side
+	out.Stake = s.Stake
+	out.Series = SeriesSnapshot{
+		UserID:      s.UserID,
+		Side:        s.Side,
+		Stake:       s.Stake,
+		Wins:        s.Wins,
+		Multiplier:  s.Multiplier,
+		Stage:       s.Stage,
+		RoundGameID: s.RoundGameID,
+		Active:      s.Active,
+	}
+	delete(e.series, userID)
+	return out, true, ""
+}
+
+func (seriesMode) Resolve(e *Engine, seed []byte) Outcome {
+	return e.coinOutcomeLocked(seed)
+}
+
+func (seriesMode) Settle(*Engine, Outcome, *PayoutResult) {}
+
+func (seriesMode) AfterRound(e *Engine, out Outcome) map[int64]SeriesRoundResult {
+	results := make(map[int64]SeriesRoundResult)
+
+	for userID, s := range e.series {
+		if !s.Active {
+			continue
+		}
+		if s.Stage != SeriesStageInRound {
+			continue
+		}
+		if s.RoundGameID != e.gameID {
+			continue
+		}
+
+		playedSide := s.Side
+
+		if e.edgePushLocked(out) {
+			res := SeriesRoundResult{
+				GameID:     e.gameID,
+				UserID:     userID,
+				Side:       playedSide,
+				Stake:      s.Stake,
+				Wins:       s.Wins,
+				Multiplier: s.Multiplier,
+				Outcome:    "push",
+			}
+			if s.Wins == 0 {
+				delete(e.series, userID)
+			} else {
+				s.Stage = SeriesStageAwaitingChoice
+				s.RoundGameID = 0
+				s.Side = ""
+
+				res.Claimable = claimableForSeries(s)
+				res.Stage = s.Stage
+				res.Active = true
+			}
+			results[userID] = res
+
+			e.log.Info("series pushed", logging.KeyGameID, e.gameID, logging.KeyUserID, userID, "wins", s.Wins)
+			continue
+		}
+
+		if Side(playedSide) != out.Side {
+			results[userID] = SeriesRoundResult{
+				GameID:     e.gameID,
+				UserID:     userID,
+				Side:       playedSide,
+				Stake:      s.Stake,
+				Wins:       s.Wins,
+				Multiplier: s.Multiplier,
+				Claimable:  0,
+				Stage:      "",
+				Active:     false,
+				Outcome:    "lose",
+			}
+
+			delete(e.series, userID)
+			e.log.Info("series lost", logging.KeyGameID, e.gameID, logging.KeyUserID, userID, "wins", s.Wins)
+			continue
+		}
+
+		s.Wins++
+		if s.Wins == 1 {
+			s.Multiplier = e.seriesFirstMultiplier
+		} else {
+			s.Multiplier = s.Multiplier * e.seriesStepMultiplier
+		}
+
+		s.Stage = SeriesStageAwaitingChoice
+		s.RoundGameID = 0
+		s.Side = ""
+
+		results[userID] = SeriesRoundResult{
+			GameID:     e.gameID,
+			UserID:     userID,
+			Side:       playedSide,
+			Stake:      s.Stake,
+			Wins:       s.Wins,
+			Multiplier: s.Multiplier,
+			Claimable:  claimableForSeries(s),
+			Stage:      s.Stage,
+			Active:     true,
+			Outcome:    "win",
+		}
+
+		e.log.Info("series win", logging.KeyGameID, e.gameID, logging.KeyUserID, userID, "wins", s.Wins, "multiplier", s.Multiplier)
+	}
+
+	return results
+}
+
+func (seriesMode) Exposure(e *Engine, x *risk.Exposure) {
+	for uid, s := range e.series {
+		if s == nil || !s.Active {
+			continue
+		}
+		if s.Stage != SeriesStageInRound || s.RoundGameID != e.gameID {
+			x.AddOwed(uid, claimableForSeries(s))
+			continue
+		}
+		x.AddPosition(uid, s.Side, s.Stake, s.Stake*e.nextSeriesMultiplierLocked(s))
+	}
+}
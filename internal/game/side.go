@@ -5,4 +5,5 @@ type Side string
 const (
 	SideHeads Side = "heads"
 	SideTails Side = "tails"
+	SideEdge  Side = "edge"
 )
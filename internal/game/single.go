@@ -0,0 +1,63 @@
+package game
+
+import "CoinFlip/internal/risk"
+
+const ModeSingle = "single"
+
+type singleMode struct{}
+
+func (singleMode) Name() string { return ModeSingle }
+
+func (singleMode) NormalizeSide(e *Engine, side string) (string, bool) {
+	if Side(side) == SideEdge {
+		return side, e.edgeRulesLocked() != nil
+	}
+	return side, isCoinSide(side)
+}
+
+func (singleMode) PlaceBet(e *Engine, userID int64, side string, stake float64, items []ItemRef) (int, string) {
+	accepted := e.bets.Add(e.gameID, userID, side, ModeSingle, items)
+	if reason := e.checkExposureLocked(userID, stake*e.singleSideMultiplierLocked(side)); reason != "" {
+		e.bets.RemoveLastN(e.gameID, userID, accepted)
+		return 0, reason
+	}
+	e.publishExposureLocked()
+	return accepted, ""
+}
+
+func (singleMode) CancelBet(e *Engine, userID int64) (CancelledBet, bool, string) {
+	return e.removeModeBetsLocked(userID, ModeSingle)
+}
+
+func (singleMode) Resolve(e *Engine, seed []byte) Outcome {
+	return e.coinOutcomeLocked(seed)
+}
+
+func (singleMode) Settle(e *Engine, out Outcome, pr *PayoutResult) {
+	e.settleFixedLocked(pr, ModeSingle, func(side string) (float64, bool) {
+		return e.singleSideMultiplierLocked(side), Side(side) == out.Side
+	}, func(side string) bool {
+		return e.edgePushLocked(out) && Side(side) != SideEdge
+	})
+}
+
+func (singleMode) AfterRound(*Engine, Outcome) map[int64]SeriesRoundResult { return nil }
+
+func (singleMode) Exposure(e *Engine, x *risk.Exposure) {
+	for _, ub := range e.bets.Snapshot(e.gameID) {
+		for _, b := range ub.Bets {
+			if b.Mode != ModeSingle {
+				continue
+			}
+			stake := betStakeValue(b)
+			x.AddPosition(b.UserID, b.Side, stake, stake*e.singleSideMultiplierLocked(b.Side))
+		}
+	}
+}
+
+func (e *Engine) singleSideMultiplierLocked(side string) float64 {
+	if Side(side) == SideEdge {
+		return e.edge.Multiplier
+	}
+	return e.singleMultiplier
+}
@@ -0,0 +1,189 @@
+package game
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type GameIDs struct {
+	last atomic.Int64
+}
+
+func NewGameIDs(next int) *GameIDs {
+	if next <= 0 {
+		next = 1
+	}
+	g := &GameIDs{}
+	g.last.Store(int64(next - 1))
+	return g
+}
+
+func (g *GameIDs) Next() int {
+	return int(g.last.Add(1))
+}
+
+type TableOverrides struct {
+	BettingTime           *int     `json:"betting_time,omitempty"`
+	TimeTillResult        *int     `json:"time_till_result,omitempty"`
+	NextGameDelay         *int     `json:"next_game_delay,omitempty"`
+	SingleMultiplier      *float64 `json:"single_multiplier,omitempty"`
+	SeriesFirstMultiplier *float64 `json:"series_first_multiplier,omitempty"`
+	SeriesStepMultiplier  *float64 `json:"series_step_multiplier,omitempty"`
+	PayoutMode            *string  `json:"payout_mode,omitempty"`
+	PoolRakePercent       *float64 `json:"pool_rake_percent,omitempty"`
+	CoinCount             *int     `json:"coin_count,omitempty"`
+	CoinEdgePercent       *float64 `json:"coin_edge_percent,omitempty"`
+	MinStakeTon           *float64 `json:"min_stake_ton,omitempty"`
+	MaxStakeTon           *float64 `json:"max_stake_ton,omitempty"`
+	MaxBetItems           *int     `json:"max_bet_items,omitempty"`
+	UserRoundCapTon       *float64 `json:"user_round_cap_ton,omitempty"`
+	MaxRoundLiabilityTon  *float64 `json:"max_round_liability_ton,omitempty"`
+	ItemTypes             []string `json:"item_types,omitempty"`
+}
+
+func (o TableOverrides) Apply(t Tunables) Tunables {
+	setInt := func(dst *int, v *int) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setFloat := func(dst *float64, v *float64) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+
+	setInt(&t.Timings.BettingTime, o.BettingTime)
+	setInt(&t.Timings.TimeTillResult, o.TimeTillResult)
+	setInt(&t.Timings.NextGameDelay, o.NextGameDelay)
+	setFloat(&t.SingleMultiplier, o.SingleMultiplier)
+	setFloat(&t.SeriesFirstMultiplier, o.SeriesFirstMultiplier)
+	setFloat(&t.SeriesStepMultiplier, o.SeriesStepMultiplier)
+	if o.PayoutMode != nil {
+		t.PayoutMode = *o.PayoutMode
+	}
+	setFloat(&t.PoolRakePercent, o.PoolRakePercent)
+	setInt(&t.CoinCount, o.CoinCount)
+	setFloat(&t.CoinEdgePercent, o.CoinEdgePercent)
+	setFloat(&t.Limits.MinStakeTon, o.MinStakeTon)
+	setFloat(&t.Limits.MaxStakeTon, o.MaxStakeTon)
+	setInt(&t.Limits.MaxBetItems, o.MaxBetItems)
+	setFloat(&t.Limits.UserRoundCapTon, o.UserRoundCapTon)
+	setFloat(&t.Limits.MaxRoundLiabilityTon, o.MaxRoundLiabilityTon)
+	if len(o.ItemTypes) > 0 {
+		t.Limits.ItemTypes = slices.Clone(o.ItemTypes)
+	}
+	return t
+}
+
+type Table struct {
+	ID        int            `json:"table_id"`
+	Name      string         `json:"name"`
+	Overrides TableOverrides `json:"overrides"`
+}
+
+type TableInfo struct {
+	ID         int        `json:"table_id"`
+	Name       string     `json:"name"`
+	Phase      Phase      `json:"phase"`
+	GameID     int        `json:"game_id"`
+	Paused     bool       `json:"paused"`
+	PayoutMode string     `json:"payout_mode"`
+	Modes      []string   `json:"modes"`
+	Coins      *CoinOdds  `json:"coins,omitempty"`
+	Edge       *EdgeRules `json:"edge,omitempty"`
+	Timings    Timings    `json:"timings"`
+	Limits     Limits     `json:"limits"`
+}
+
+type Tables struct {
+	mu      sync.RWMutex
+	engines map[int]*Engine
+	order   []int
+}
+
+func NewTables(engines ...*Engine) *Tables {
+	t := &Tables{engines: make(map[int]*Engine, len(engines))}
+	for _, e := range engines {
+		t.engines[e.table.ID] = e
+		t.order = append(t.order, e.table.ID)
+	}
+	sort.Ints(t.order)
+	return t
+}
+
+func (t *Tables) Get(tableID int) (*Engine, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	e, ok := t.engines[tableID]
+	return e, ok
+}
+
+func (t *Tables) Default() *Engine {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if len(t.order) == 0 {
+		return nil
+	}
+	return t.engines[t.order[0]]
+}
+
+func (t *Tables) All() []*Engine {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	out := make([]*Engine, 0, len(t.order))
+	for _, id := range t.order {
+		out = append(out, t.engines[id])
+	}
+	return out
+}
+
+func (t *Tables) SeriesOwner(userID int64) (*Engine, bool) {
+	for _, e := range t.All() {
+		if _, ok := e.SeriesSnapshot(userID); ok {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (t *Tables) Infos() []TableInfo {
+	engines := t.All()
+	out := make([]TableInfo, 0, len(engines))
+	for _, e := range engines {
+		out = append(out, e.Info())
+	}
+	return out
+}
+
+func (e *Engine) Table() Table {
+	return e.table
+}
+
+func (e *Engine) TableID() int {
+	return e.table.ID
+}
+
+func (e *Engine) Info() TableInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return TableInfo{
+		ID:         e.table.ID,
+		Name:       e.table.Name,
+		Phase:      e.phase,
+		GameID:     e.gameID,
+		Paused:     e.paused,
+		PayoutMode: e.payoutMode,
+		Modes:      e.modeNamesLocked(),
+		Coins:      e.coinOddsLocked(),
+		Edge:       e.edgeRulesLocked(),
+		Timings:    e.timings,
+		Limits:     e.limits,
+	}
+}
@@ -0,0 +1,167 @@
+package game
+
+import (
+	"CoinFlip/internal/config"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	TournamentDaily  = "daily"
+	TournamentWeekly = "weekly"
+)
+
+const (
+	TournamentRunning = "running"
+	TournamentSettled = "settled"
+)
+
+const TournamentSettleGrace = time.Minute
+
+type TournamentRules struct {
+	RefreshSeconds int `json:"refresh_seconds"`
+	TopN           int `json:"top_n"`
+}
+
+func TournamentRulesFromConfig(cfg *config.Config) TournamentRules {
+	return TournamentRules{
+		RefreshSeconds: cfg.TournamentRefreshSeconds,
+		TopN:           cfg.TournamentTopN,
+	}
+}
+
+func (r TournamentRules) Validate() error {
+	if r.RefreshSeconds <= 0 {
+		return fmt.Errorf("tournament refresh must be positive")
+	}
+	if r.TopN <= 0 || r.TopN > 100 {
+		return fmt.Errorf("tournament top n must be within [1, 100]")
+	}
+	return nil
+}
+
+func TournamentWindow(period string, now time.Time) (time.Time, time.Time, bool) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch period {
+	case TournamentDaily:
+		return day, day.AddDate(0, 0, 1), true
+	case TournamentWeekly:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7), true
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+}
+
+type TournamentScoring struct {
+	VolumePoints     float64 `json:"volume_points"`
+	SeriesPoints     float64 `json:"series_points"`
+	MultiplierPoints float64 `json:"multiplier_points"`
+}
+
+type TournamentScore struct {
+	UserID         int64   `json:"user_id"`
+	VolumeTon      float64 `json:"volume_ton"`
+	LongestSeries  int     `json:"longest_series"`
+	BestMultiplier float64 `json:"best_multiplier"`
+}
+
+type Standing struct {
+	Rank int `json:"rank"`
+	TournamentScore
+	Points      float64 `json:"points"`
+	PrizeTon    float64 `json:"prize_ton,omitempty"`
+	PrizeItemID *int64  `json:"prize_item_id,omitempty"`
+}
+
+func (s TournamentScoring) Points(sc TournamentScore) float64 {
+	p := sc.VolumeTon*s.VolumePoints + float64(sc.LongestSeries)*s.SeriesPoints + sc.BestMultiplier*s.MultiplierPoints
+	return floorTon(p)
+}
+
+func RankStandings(scoring TournamentScoring, prizes []float64, scores []TournamentScore) []Standing {
+	out := make([]Standing, 0, len(scores))
+	for _, sc := range scores {
+		st := Standing{TournamentScore: sc, Points: scoring.Points(sc)}
+		if st.Points > 0 {
+			out = append(out, st)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Points != out[j].Points {
+			return out[i].Points > out[j].Points
+		}
+		if out[i].VolumeTon != out[j].VolumeTon {
+			return out[i].VolumeTon > out[j].VolumeTon
+		}
+		return out[i].UserID < out[j].UserID
+	})
+	for i := range out {
+		out[i].Rank = i + 1
+		if i < len(prizes) {
+			out[i].PrizeTon = prizes[i]
+		}
+	}
+	return out
+}
+
+type Tournaments struct {
+	mu    sync.Mutex
+	rules TournamentRules
+	last  map[int64][]Standing
+}
+
+func NewTournaments(rules TournamentRules) (*Tournaments, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return &Tournaments{rules: rules, last: make(map[int64][]Standing)}, nil
+}
+
+func (t *Tournaments) Rules() TournamentRules {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.rules
+}
+
+func (t *Tournaments) SetRules(r TournamentRules) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.rules = r
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *Tournaments) Changed(runID int64, top []Standing) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if slices.Equal(t.last[runID], top) {
+		return false
+	}
+	t.last[runID] = slices.Clone(top)
+	return true
+}
+
+func (t *Tournaments) Top(runID int64) ([]Standing, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	top, ok := t.last[runID]
+	return slices.Clone(top), ok
+}
+
+func (t *Tournaments) Forget(runID int64) {
+	t.mu.Lock()
+	delete(t.last, runID)
+	t.mu.Unlock()
+}
@@ -0,0 +1,105 @@
+package game
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRankStandings(t *testing.T) {
+	scoring := TournamentScoring{VolumePoints: 1, SeriesPoints: 10, MultiplierPoints: 2}
+
+	tests := []struct {
+		name   string
+		prizes []float64
+		scores []TournamentScore
+		want   []int64
+		prize  []float64
+	}{
+		{"empty", []float64{5}, nil, []int64{}, []float64{}},
+		{
+			"points order", []float64{5, 3},
+			[]TournamentScore{
+				{UserID: 1, VolumeTon: 5},
+				{UserID: 2, VolumeTon: 1, LongestSeries: 1},
+				{UserID: 3, BestMultiplier: 4},
+			},
+			[]int64{2, 3, 1}, []float64{5, 3, 0},
+		},
+		{
+			"tie broken by volume", nil,
+			[]TournamentScore{
+				{UserID: 1, LongestSeries: 1},
+				{UserID: 2, VolumeTon: 10},
+			},
+			[]int64{2, 1}, []float64{0, 0},
+		},
+		{
+			"tie broken by user id", []float64{1},
+			[]TournamentScore{
+				{UserID: 7, VolumeTon: 4},
+				{UserID: 3, VolumeTon: 4},
+			},
+			[]int64{3, 7}, []float64{1, 0},
+		},
+		{
+			"zero points dropped", nil,
+			[]TournamentScore{
+				{UserID: 1},
+				{UserID: 2, VolumeTon: 1},
+			},
+			[]int64{2}, []float64{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RankStandings(scoring, tt.prizes, tt.scores)
+			users := make([]int64, 0, len(got))
+			prizes := make([]float64, 0, len(got))
+			for i, st := range got {
+				if st.Rank != i+1 {
+					t.Fatalf("standing %d has rank %d", i, st.Rank)
+				}
+				users = append(users, st.UserID)
+				prizes = append(prizes, st.PrizeTon)
+			}
+			if !slices.Equal(users, tt.want) {
+				t.Fatalf("users = %v, want %v", users, tt.want)
+			}
+			if !slices.Equal(prizes, tt.prize) {
+				t.Fatalf("prizes = %v, want %v", prizes, tt.prize)
+			}
+		})
+	}
+}
+
+func TestTournamentsChanged(t *testing.T) {
+	tr, err := NewTournaments(TournamentRules{RefreshSeconds: 5, TopN: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scoring := TournamentScoring{VolumePoints: 1}
+	top := RankStandings(scoring, nil, []TournamentScore{{UserID: 1, VolumeTon: 2}, {UserID: 2, VolumeTon: 1}})
+
+	if _, ok := tr.Top(1); ok {
+		t.Fatal("Top before any standings")
+	}
+	if !tr.Changed(1, top) {
+		t.Fatal("first standings not reported as changed")
+	}
+	if tr.Changed(1, slices.Clone(top)) {
+		t.Fatal("same standings reported as changed")
+	}
+
+	next := RankStandings(scoring, nil, []TournamentScore{{UserID: 1, VolumeTon: 2}, {UserID: 2, VolumeTon: 3}})
+	if !tr.Changed(1, next) {
+		t.Fatal("reordered standings not reported as changed")
+	}
+	if got, ok := tr.Top(1); !ok || got[0].UserID != 2 {
+		t.Fatalf("Top = %+v, want user 2 first", got)
+	}
+
+	tr.Forget(1)
+	if _, ok := tr.Top(1); ok {
+		t.Fatal("Top after Forget")
+	}
+}
@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+type Checker struct {
+	maxTickAge time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]CheckFunc
+
+	lastTick atomic.Int64
+	draining atomic.Bool
+}
+
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func NewChecker(maxTickAge time.Duration) *Checker {
+	if maxTickAge <= 0 {
+		maxTickAge = 5 * time.Second
+	}
+	c := &Checker{
+		maxTickAge: maxTickAge,
+		checks:     make(map[string]CheckFunc),
+	}
+	c.lastTick.Store(time.Now().UnixNano())
+	return c
+}
+
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = fn
+}
+
+func (c *Checker) Beat() {
+	c.lastTick.Store(time.Now().UnixNano())
+}
+
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+func (c *Checker) loopErr() string {
+	age := time.Since(time.Unix(0, c.lastTick.Load()))
+	if age > c.maxTickAge {
+		return "game loop stalled for " + age.Truncate(time.Millisecond).String()
+	}
+	return ""
+}
+
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	out := report{Status: "ok", Checks: map[string]string{"game_loop": "ok"}}
+	status := http.StatusOK
+
+	if msg := c.loopErr(); msg != "" {
+		out.Status = "fail"
+		out.Checks["game_loop"] = msg
+		status = http.StatusServiceUnavailable
+	}
+
+	writeReport(w, status, out)
+}
+
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	out := report{Status: "ok", Checks: make(map[string]string)}
+	status := http.StatusOK
+
+	fail := func(name, msg string) {
+		out.Status = "fail"
+		out.Checks[name] = msg
+		status = http.StatusServiceUnavailable
+	}
+
+	if c.Draining() {
+		fail("shutdown", "draining")
+	}
+
+	if msg := c.loopErr(); msg != "" {
+		fail("game_loop", msg)
+	} else {
+		out.Checks["game_loop"] = "ok"
+	}
+
+	c.mu.RLock()
+	checks := make(map[string]CheckFunc, len(c.checks))
+	for name, fn := range c.checks {
+		checks[name] = fn
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	for name, fn := range checks {
+		if err := fn(ctx); err != nil {
+			fail(name, err.Error())
+			continue
+		}
+		out.Checks[name] = "ok"
+	}
+
+	writeReport(w, status, out)
+}
+
+func writeReport(w http.ResponseWriter, status int, v report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "coinflip"
+
+var (
+	PhaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "phase_duration_seconds",
+		Help:      "Wall time spent in each round phase.",
+		Buckets:   []float64{0.5, 1, 2, 5, 10, 15, 30, 60, 90, 120, 300},
+	}, []string{"phase"})
+
+	RoundBets = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "round_bets",
+		Help:      "Number of accepted bet items per settled round.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
+	RoundStakeTon = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "round_stake_ton",
+		Help:      "Total TON staked with new bets per settled round.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 16),
+	})
+
+	StakeTon = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "stake_ton_total",
+		Help:      "TON staked with bets on settled rounds.",
+	})
+
+	PayoutTon = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "payout_ton_total",
+		Help:      "TON paid out to players by payout kind.",
+	}, []string{"kind"})
+
+	SeriesOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "series_outcomes_total",
+		Help:      "Series results by outcome (win, lose, cashout).",
+	}, []string{"outcome"})
+
+	HousePnLTon = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "house_pnl_ton",
+		Help:      "House profit and loss in TON since process start.",
+	})
+
+	DuelOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "duel",
+		Name:      "outcomes_total",
+		Help:      "Closed duels by outcome (resolved, expired).",
+	}, []string{"outcome"})
+
+	DuelFeeTon = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "duel",
+		Name:      "fee_ton_total",
+		Help:      "House fee taken from duels in TON by fee mode.",
+	}, []string{"mode"})
+
+	JackpotContributionTon = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jackpot",
+		Name:      "contribution_ton_total",
+		Help:      "Stake slice moved into the progressive jackpot in TON.",
+	})
+
+	JackpotPaidTon = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jackpot",
+		Name:      "paid_ton_total",
+		Help:      "Jackpot paid out to winners in TON.",
+	})
+
+	JackpotBalanceTon = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "jackpot",
+		Name:      "balance_ton",
+		Help:      "Current progressive jackpot balance in TON.",
+	})
+
+	ExposureTon = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "risk",
+		Name:      "exposure_ton",
+		Help:      "Current house liability in TON per table (heads, tails, edge, coins_<heads>, owed, worst).",
+	}, []string{"table", "side"})
+
+	RiskDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "risk",
+		Name:      "decisions_total",
+		Help:      "Bets and series continues throttled or rejected by the risk monitor.",
+	}, []string{"decision", "reason"})
+
+	RiskAlerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "risk",
+		Name:      "alerts_total",
+		Help:      "Exposure alerts raised and cleared.",
+	}, []string{"state"})
+
+	OnlineUsers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "online_users",
+		Help:      "Authorized websocket connections.",
+	})
+
+	WSSendFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "send_failures_total",
+		Help:      "Websocket writes that failed.",
+	})
+
+	WSWriteQueue = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "write_queue_length",
+		Help:      "Writes waiting for a connection write lock.",
+	})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Repository method latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"repo", "method"})
+
+	DBQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_errors_total",
+		Help:      "Repository method errors.",
+	}, []string{"repo", "method"})
+
+	RedisScriptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "script_duration_seconds",
+		Help:      "Redis Lua script latency.",
+		Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2},
+	}, []string{"script"})
+
+	RedisScriptErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "script_errors_total",
+		Help:      "Redis Lua script errors.",
+	}, []string{"script"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func ObserveQuery(repo, method string, start time.Time, err error) {
+	DBQueryDuration.WithLabelValues(repo, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		DBQueryErrors.WithLabelValues(repo, method).Inc()
+	}
+}
+
+func ObserveScript(script string, start time.Time, err error) {
+	RedisScriptDuration.WithLabelValues(script).Observe(time.Since(start).Seconds())
+	if err != nil {
+		RedisScriptErrors.WithLabelValues(script).Inc()
+	}
+}
@@ -0,0 +1,101 @@
+package risk
+
+import "sort"
+
+type UserExposure struct {
+	UserID    int64   `json:"user_id"`
+	Side      string  `json:"side,omitempty"`
+	Stake     float64 `json:"stake"`
+	Liability float64 `json:"liability"`
+	Owed      float64 `json:"owed"`
+}
+
+func (u UserExposure) Total() float64 {
+	return u.Liability + u.Owed
+}
+
+type Exposure struct {
+	GameID int                    `json:"game_id"`
+	Heads  float64                `json:"heads"`
+	Tails  float64                `json:"tails"`
+	Edge   float64                `json:"edge,omitempty"`
+	Owed   float64                `json:"owed"`
+	Coins  map[int]float64        `json:"coins,omitempty"`
+	Users  map[int64]UserExposure `json:"-"`
+}
+
+func NewExposure(gameID int) Exposure {
+	return Exposure{GameID: gameID, Users: make(map[int64]UserExposure)}
+}
+
+func (x *Exposure) AddPosition(userID int64, side string, stake, payout float64) {
+	switch side {
+	case "heads":
+		x.Heads += payout
+	case "tails":
+		x.Tails += payout
+	case "edge":
+		x.Edge += payout
+	}
+
+	u := x.Users[userID]
+	u.UserID = userID
+	u.Side = side
+	u.Stake += stake
+	u.Liability += payout
+	x.Users[userID] = u
+}
+
+func (x *Exposure) AddOutcomes(userID int64, target string, stake, payout float64, heads []int) {
+	if x.Coins == nil {
+		x.Coins = make(map[int]float64)
+	}
+	for _, k := range heads {
+		x.Coins[k] += payout
+	}
+
+	u := x.Users[userID]
+	u.UserID = userID
+	u.Side = target
+	u.Stake += stake
+	u.Liability += payout
+	x.Users[userID] = u
+}
+
+func (x *Exposure) AddOwed(userID int64, claimable float64) {
+	x.Owed += claimable
+
+	u := x.Users[userID]
+	u.UserID = userID
+	u.Owed += claimable
+	x.Users[userID] = u
+}
+
+func (x Exposure) Worst() float64 {
+	worst := max(x.Heads, x.Tails, x.Edge)
+	for _, v := range x.Coins {
+		worst = max(worst, v)
+	}
+	return x.Owed + worst
+}
+
+func (x Exposure) User(userID int64) UserExposure {
+	return x.Users[userID]
+}
+
+func (x Exposure) TopUsers(n int) []UserExposure {
+	out := make([]UserExposure, 0, len(x.Users))
+	for _, u := range x.Users {
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total() != out[j].Total() {
+			return out[i].Total() > out[j].Total()
+		}
+		return out[i].UserID < out[j].UserID
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
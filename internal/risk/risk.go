@@ -0,0 +1,236 @@
+package risk
+
+import (
+	"CoinFlip/internal/config"
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/metrics"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ReasonHouseExposure = "house exposure limit reached"
+	ReasonUserExposure  = "user exposure limit reached"
+	ReasonThrottled     = "bet throttled: house exposure high"
+)
+
+const maxAlerts = 50
+
+type Thresholds struct {
+	AlertTon             float64 `json:"alert_ton"`
+	ThrottleTon          float64 `json:"throttle_ton"`
+	ThrottleMaxPayoutTon float64 `json:"throttle_max_payout_ton"`
+	RejectTon            float64 `json:"reject_ton"`
+	UserMaxTon           float64 `json:"user_max_ton"`
+}
+
+func ThresholdsFromConfig(cfg *config.Config) Thresholds {
+	return Thresholds{
+		AlertTon:             cfg.RiskAlertTon,
+		ThrottleTon:          cfg.RiskThrottleTon,
+		ThrottleMaxPayoutTon: cfg.RiskThrottleMaxPayoutTon,
+		RejectTon:            cfg.RiskRejectTon,
+		UserMaxTon:           cfg.RiskUserMaxTon,
+	}
+}
+
+func (t Thresholds) Validate() error {
+	if t.AlertTon < 0 || t.ThrottleTon < 0 || t.ThrottleMaxPayoutTon < 0 || t.RejectTon < 0 || t.UserMaxTon < 0 {
+		return fmt.Errorf("risk thresholds must not be negative")
+	}
+	if t.ThrottleTon > 0 && t.ThrottleMaxPayoutTon == 0 {
+		return fmt.Errorf("throttle_max_payout_ton is required when throttle_ton is set")
+	}
+	if t.RejectTon > 0 && t.ThrottleTon > t.RejectTon {
+		return fmt.Errorf("throttle_ton must not exceed reject_ton")
+	}
+	return nil
+}
+
+type Alert struct {
+	State     string    `json:"state"`
+	GameID    int       `json:"game_id"`
+	Worst     float64   `json:"worst"`
+	Threshold float64   `json:"threshold"`
+	At        time.Time `json:"at"`
+}
+
+type Report struct {
+	GameID     int             `json:"game_id"`
+	Heads      float64         `json:"heads"`
+	Tails      float64         `json:"tails"`
+	Edge       float64         `json:"edge"`
+	Coins      map[int]float64 `json:"coins,omitempty"`
+	Owed       float64         `json:"owed"`
+	Worst      float64         `json:"worst"`
+	Alerting   bool            `json:"alerting"`
+	Thresholds Thresholds      `json:"thresholds"`
+	Users      []UserExposure  `json:"users"`
+	Alerts     []Alert         `json:"alerts"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+type Monitor struct {
+	log   *slog.Logger
+	table string
+
+	mu        sync.RWMutex
+	th        Thresholds
+	current   Exposure
+	updatedAt time.Time
+	alerting  bool
+	alerts    []Alert
+	coins     map[int]struct{}
+}
+
+func NewMonitor(ctx context.Context, tableID int, th Thresholds) *Monitor {
+	return &Monitor{
+		log:     logging.FromContext(ctx).With("component", "risk", "table_id", tableID),
+		table:   strconv.Itoa(tableID),
+		th:      th,
+		current: NewExposure(0),
+		coins:   make(map[int]struct{}),
+	}
+}
+
+func coinsLabel(heads int) string {
+	return "coins_" + strconv.Itoa(heads)
+}
+
+func (m *Monitor) Thresholds() Thresholds {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.th
+}
+
+func (m *Monitor) SetThresholds(th Thresholds) error {
+	if err := th.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.th = th
+	m.log.Info("risk thresholds changed",
+		"alert_ton", th.AlertTon,
+		"throttle_ton", th.ThrottleTon,
+		"throttle_max_payout_ton", th.ThrottleMaxPayoutTon,
+		"reject_ton", th.RejectTon,
+		"user_max_ton", th.UserMaxTon,
+	)
+	return nil
+}
+
+func (m *Monitor) Check(next Exposure, userID int64, payout float64) string {
+	m.mu.RLock()
+	th := m.th
+	m.mu.RUnlock()
+
+	worst := next.Worst()
+	reason, decision := "", ""
+
+	switch {
+	case th.RejectTon > 0 && worst > th.RejectTon:
+		reason, decision = ReasonHouseExposure, "reject"
+	case th.UserMaxTon > 0 && next.User(userID).Total() > th.UserMaxTon:
+		reason, decision = ReasonUserExposure, "reject"
+	case th.ThrottleTon > 0 && worst > th.ThrottleTon && payout > th.ThrottleMaxPayoutTon:
+		reason, decision = ReasonThrottled, "throttle"
+	default:
+		return ""
+	}
+
+	metrics.RiskDecisions.WithLabelValues(decision, reason).Inc()
+	m.log.Warn("risk: bet refused",
+		logging.KeyGameID, next.GameID,
+		logging.KeyUserID, userID,
+		"decision", decision,
+		"reason", reason,
+		"payout", payout,
+		"worst", worst,
+	)
+	return reason
+}
+
+func (m *Monitor) Publish(x Exposure) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.current = x
+	m.updatedAt = time.Now()
+
+	worst := x.Worst()
+	metrics.ExposureTon.WithLabelValues(m.table, "heads").Set(x.Heads)
+	metrics.ExposureTon.WithLabelValues(m.table, "tails").Set(x.Tails)
+	metrics.ExposureTon.WithLabelValues(m.table, "edge").Set(x.Edge)
+	metrics.ExposureTon.WithLabelValues(m.table, "owed").Set(x.Owed)
+	for k := range m.coins {
+		if _, ok := x.Coins[k]; !ok {
+			metrics.ExposureTon.DeleteLabelValues(m.table, coinsLabel(k))
+			delete(m.coins, k)
+		}
+	}
+	for k, v := range x.Coins {
+		metrics.ExposureTon.WithLabelValues(m.table, coinsLabel(k)).Set(v)
+		m.coins[k] = struct{}{}
+	}
+	metrics.ExposureTon.WithLabelValues(m.table, "worst").Set(worst)
+
+	if m.th.AlertTon <= 0 {
+		m.alerting = false
+		return
+	}
+
+	switch {
+	case !m.alerting && worst >= m.th.AlertTon:
+		m.alerting = true
+		m.alertLocked("raised", x.GameID, worst)
+		m.log.Warn("risk: exposure alert raised", logging.KeyGameID, x.GameID, "worst", worst, "threshold", m.th.AlertTon)
+	case m.alerting && worst < m.th.AlertTon:
+		m.alerting = false
+		m.alertLocked("cleared", x.GameID, worst)
+		m.log.Info("risk: exposure alert cleared", logging.KeyGameID, x.GameID, "worst", worst, "threshold", m.th.AlertTon)
+	}
+}
+
+func (m *Monitor) alertLocked(state string, gameID int, worst float64) {
+	metrics.RiskAlerts.WithLabelValues(state).Inc()
+
+	m.alerts = append(m.alerts, Alert{
+		State:     state,
+		GameID:    gameID,
+		Worst:     worst,
+		Threshold: m.th.AlertTon,
+		At:        time.Now().UTC(),
+	})
+	if len(m.alerts) > maxAlerts {
+		m.alerts = m.alerts[len(m.alerts)-maxAlerts:]
+	}
+}
+
+func (m *Monitor) Report(topUsers int) Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return Report{
+		GameID:     m.current.GameID,
+		Heads:      m.current.Heads,
+		Tails:      m.current.Tails,
+		Edge:       m.current.Edge,
+		Coins:      maps.Clone(m.current.Coins),
+		Owed:       m.current.Owed,
+		Worst:      m.current.Worst(),
+		Alerting:   m.alerting,
+		Thresholds: m.th,
+		Users:      m.current.TopUsers(topUsers),
+		Alerts:     append([]Alert(nil), m.alerts...),
+		UpdatedAt:  m.updatedAt,
+	}
+}
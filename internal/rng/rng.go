@@ -3,6 +3,7 @@ package rng
 import (
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 )
 
@@ -27,3 +28,35 @@ func SideFromSeed(seed []byte) string {
 	}
 	return "tails"
 }
+
+func OutcomeFromSeed(seed []byte, edgeOdds int) string {
+	if edgeOdds > 0 && tagged(seed, "edge")%uint64(edgeOdds) == 0 {
+		return "edge"
+	}
+	return SideFromSeed(seed)
+}
+
+func FacesFromSeed(seed []byte, n int) []string {
+	sum := sha256.Sum256(seed)
+	out := make([]string, 0, n)
+	for i := 0; i < n && i < len(sum)*8; i++ {
+		if (sum[i/8]>>(i%8))&1 == 0 {
+			out = append(out, "heads")
+		} else {
+			out = append(out, "tails")
+		}
+	}
+	return out
+}
+
+func JackpotFromSeed(seed []byte, odds int) bool {
+	if odds <= 0 {
+		return false
+	}
+	return tagged(seed, "jackpot")%uint64(odds) == 0
+}
+
+func tagged(seed []byte, tag string) uint64 {
+	sum := sha256.Sum256(append(append([]byte(nil), seed...), tag...))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,40 @@
+package rng
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestOutcomeFromSeed(t *testing.T) {
+	const n = 20000
+
+	tests := []struct {
+		name     string
+		odds     int
+		min, max int
+	}{
+		{"disabled", 0, 0, 0},
+		{"negative disables", -5, 0, 0},
+		{"always edge", 1, n, n},
+		{"one in two", 2, n * 45 / 100, n * 55 / 100},
+		{"one in fifty", 50, n / 50 * 7 / 10, n / 50 * 13 / 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edges := 0
+			for i := range n {
+				seed := []byte("seed-" + strconv.Itoa(i))
+				switch out := OutcomeFromSeed(seed, tt.odds); out {
+				case "edge":
+					edges++
+				case SideFromSeed(seed):
+				default:
+					t.Fatalf("seed %d: outcome %q disagrees with side %q", i, out, SideFromSeed(seed))
+				}
+			}
+			if edges < tt.min || edges > tt.max {
+				t.Fatalf("edges = %d of %d, want within [%d, %d]", edges, n, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+type AdminUser struct {
+	ID       int64
+	Username string
+	Role     string
+}
+
+type AdminRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAdminRepo(db *pgxpool.Pool) *AdminRepo {
+	return &AdminRepo{db: db}
+}
+
+func (r *AdminRepo) Authenticate(ctx context.Context, username, password string) (_ *AdminUser, err error) {
+	defer observe(ctx, "admin", "Authenticate", time.Now(), &err)
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	const q = `
+		SELECT id, username, password, role
+		FROM twist_admin.users
+		WHERE username = $1
+		  AND deleted_at IS NULL
+	`
+
+	var u AdminUser
+	var hash sql.NullString
+	var role sql.NullString
+	err = r.db.QueryRow(ctx, q, username).Scan(&u.ID, &u.Username, &hash, &role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if !hash.Valid || bcrypt.CompareHashAndPassword([]byte(hash.String), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	u.Role = "viewer"
+	if role.Valid && role.String != "" {
+		u.Role = role.String
+	}
+	return &u, nil
+}
+
+func (r *AdminRepo) LogAction(ctx context.Context, adminID int64, action, targetTable, recordID string, oldData, newData any) (err error) {
+	defer observe(ctx, "admin", "LogAction", time.Now(), &err)
+	if adminID <= 0 {
+		return fmt.Errorf("invalid admin_id")
+	}
+	if action == "" {
+		return fmt.Errorf("empty action")
+	}
+
+	oldJSON, err := json.Marshal(oldData)
+	if err != nil {
+		return err
+	}
+	newJSON, err := json.Marshal(newData)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+		INSERT INTO twist_admin.actions_log (
+			admin_id,
+			action,
+			target_table,
+			record_id,
+			old_data,
+			new_data,
+			created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, now())
+	`
+	_, err = r.db.Exec(ctx, q, adminID, action, targetTable, recordID, oldJSON, newJSON)
+	return err
+}
@@ -0,0 +1,245 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrAutoplayActive = errors.New("autoplay already active")
+
+type CreateAutoplayParams struct {
+	UserID        int64
+	TableID       int
+	Side          string
+	Mode          string
+	Source        string
+	StakeTon      float64
+	ItemIDs       []int
+	Rounds        int
+	StopLossTon   float64
+	TakeProfitTon float64
+}
+
+type AutoplaySession struct {
+	ID            int64
+	UserID        int64
+	TableID       int
+	Side          string
+	Mode          string
+	Source        string
+	StakeTon      float64
+	ItemIDs       []int
+	Rounds        int
+	StopLossTon   float64
+	TakeProfitTon float64
+	RoundsPlayed  int
+	WageredTon    float64
+	NetTon        float64
+	SettledGameID int
+	Status        string
+}
+
+type AutoplayRound struct {
+	SessionID    int64
+	RoundsPlayed int
+	WageredTon   float64
+	NetTon       float64
+}
+
+type AutoplayRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAutoplayRepo(db *pgxpool.Pool) *AutoplayRepo {
+	return &AutoplayRepo{db: db}
+}
+
+func (r *AutoplayRepo) Create(ctx context.Context, p CreateAutoplayParams) (_ int64, err error) {
+	defer observe(ctx, "autoplay", "Create", time.Now(), &err)
+	if p.UserID <= 0 {
+		return 0, fmt.Errorf("invalid user_id")
+	}
+	if p.Rounds <= 0 {
+		return 0, fmt.Errorf("invalid rounds")
+	}
+
+	itemIDs := make([]int64, 0, len(p.ItemIDs))
+	for _, id := range p.ItemIDs {
+		itemIDs = append(itemIDs, int64(id))
+	}
+
+	const q = `
+		INSERT INTO twist_business.coinflip_autoplay_sessions (
+			user_id,
+			table_id,
+			side,
+			mode,
+			source,
+			stake_ton,
+			item_ids,
+			rounds,
+			stop_loss_ton,
+			take_profit_ton
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id) WHERE status = 'active' DO NOTHING
+		RETURNING id
+	`
+
+	var id int64
+	err = r.db.QueryRow(ctx, q,
+		p.UserID,
+		p.TableID,
+		p.Side,
+		p.Mode,
+		p.Source,
+		p.StakeTon,
+		itemIDs,
+		p.Rounds,
+		p.StopLossTon,
+		p.TakeProfitTon,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrAutoplayActive
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *AutoplayRepo) ListActive(ctx context.Context) (_ []AutoplaySession, err error) {
+	defer observe(ctx, "autoplay", "ListActive", time.Now(), &err)
+	const q = `
+		SELECT
+			id,
+			user_id,
+			table_id,
+			side,
+			mode,
+			source,
+			stake_ton::float8,
+			item_ids,
+			rounds,
+			stop_loss_ton::float8,
+			take_profit_ton::float8,
+			rounds_played,
+			wagered_ton::float8,
+			net_ton::float8,
+			COALESCE(settled_game_id, 0),
+			status
+		FROM twist_business.coinflip_autoplay_sessions
+		WHERE status = 'active'
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]AutoplaySession, 0)
+	for rows.Next() {
+		var s AutoplaySession
+		var itemIDs []int64
+		if err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.TableID,
+			&s.Side,
+			&s.Mode,
+			&s.Source,
+			&s.StakeTon,
+			&itemIDs,
+			&s.Rounds,
+			&s.StopLossTon,
+			&s.TakeProfitTon,
+			&s.RoundsPlayed,
+			&s.WageredTon,
+			&s.NetTon,
+			&s.SettledGameID,
+			&s.Status,
+		); err != nil {
+			return nil, err
+		}
+		for _, id := range itemIDs {
+			s.ItemIDs = append(s.ItemIDs, int(id))
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *AutoplayRepo) RecordRound(ctx context.Context, gameID int) (_ []AutoplayRound, err error) {
+	defer observe(ctx, "autoplay", "RecordRound", time.Now(), &err)
+	if gameID <= 0 {
+		return nil, fmt.Errorf("invalid game_id")
+	}
+
+	const q = `
+		UPDATE twist_business.coinflip_autoplay_sessions AS s
+		SET
+			rounds_played = s.rounds_played + 1,
+			wagered_ton = s.wagered_ton + b.stake_ton,
+			net_ton = s.net_ton + b.payout_ton - b.stake_ton,
+			settled_game_id = $1,
+			updated_at = now()
+		FROM (
+			SELECT autoplay_session_id, SUM(stake_ton) AS stake_ton, SUM(payout_ton) AS payout_ton
+			FROM twist_business.game_bets
+			WHERE game_id = $1
+			  AND autoplay_session_id IS NOT NULL
+			  AND status NOT IN ('accepted', 'cancelled')
+			  AND status NOT LIKE '%\_refund'
+			GROUP BY autoplay_session_id
+		) AS b
+		WHERE s.id = b.autoplay_session_id
+		  AND COALESCE(s.settled_game_id, 0) < $1
+		RETURNING s.id, s.rounds_played, s.wagered_ton::float8, s.net_ton::float8
+	`
+
+	rows, err := r.db.Query(ctx, q, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]AutoplayRound, 0)
+	for rows.Next() {
+		var a AutoplayRound
+		if err := rows.Scan(&a.SessionID, &a.RoundsPlayed, &a.WageredTon, &a.NetTon); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (r *AutoplayRepo) Stop(ctx context.Context, sessionID int64, status, reason string) (err error) {
+	defer observe(ctx, "autoplay", "Stop", time.Now(), &err)
+	if sessionID <= 0 {
+		return fmt.Errorf("invalid session_id")
+	}
+	if status != "stopped" && status != "finished" {
+		return fmt.Errorf("bad status")
+	}
+
+	const q = `
+		UPDATE twist_business.coinflip_autoplay_sessions
+		SET
+			status = $2,
+			stop_reason = $3,
+			updated_at = now(),
+			stopped_at = now()
+		WHERE id = $1
+		  AND status = 'active'
+	`
+	_, err = r.db.Exec(ctx, q, sessionID, status, reason)
+	return err
+}
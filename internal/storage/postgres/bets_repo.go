@@ -2,23 +2,29 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var ErrInsufficientFunds = errors.New("insufficient wallet balance")
+
 type CreateBetRow struct {
-	GameID          int
-	UserID          int64
-	Side            string
-	Mode            string
-	SeriesSessionID *int64
-	ItemID          int
-	ItemType        string
-	ItemName        string
-	ItemPhotoURL    *string
-	StakeTon        float64
+	GameID            int
+	UserID            int64
+	Side              string
+	Mode              string
+	SeriesSessionID   *int64
+	AutoplaySessionID *int64
+	ItemID            int
+	ItemType          string
+	ItemName          string
+	ItemPhotoURL      *string
+	StakeTon          float64
 }
 
 type UserPayout struct {
@@ -34,7 +40,8 @@ func NewBetsRepo(db *pgxpool.Pool) *BetsRepo {
 	return &BetsRepo{db: db}
 }
 
-func (r *BetsRepo) InsertAcceptedBets(ctx context.Context, rows []CreateBetRow) error {
+func (r *BetsRepo) InsertAcceptedBets(ctx context.Context, rows []CreateBetRow) (err error) {
+	defer observe(ctx, "bets", "InsertAcceptedBets", time.Now(), &err)
 	if len(rows) == 0 {
 		return nil
 	}
@@ -46,6 +53,7 @@ func (r *BetsRepo) InsertAcceptedBets(ctx context.Context, rows []CreateBetRow)
 			side,
 			mode,
 			series_session_id,
+			autoplay_session_id,
 			item_id,
 			item_type,
 			item_name,
@@ -57,36 +65,18 @@ func (r *BetsRepo) InsertAcceptedBets(ctx context.Context, rows []CreateBetRow)
 		VALUES (
 			$1, $2, $3, $4, $5,
 			$6, $7, $8, $9, $10,
-			'accepted', 0
+			$11, 'accepted', 0
 		)
 	`
 
 	batch := &pgx.Batch{}
 	for _, row := range rows {
-		if row.GameID <= 0 {
-			return fmt.Errorf("invalid game_id")
-		}
-		if row.UserID <= 0 {
-			return fmt.Errorf("invalid user_id")
-		}
-		if row.Side != "heads" && row.Side != "tails" {
-			return fmt.Errorf("bad side")
-		}
-		if row.Mode != "series" {
-			return fmt.Errorf("bad mode")
+		if err := checkBetRow(row); err != nil {
+			return err
 		}
 		if row.ItemID <= 0 {
 			return fmt.Errorf("invalid item_id")
 		}
-		if row.ItemType == "" {
-			return fmt.Errorf("empty item_type")
-		}
-		if row.ItemName == "" {
-			return fmt.Errorf("empty item_name")
-		}
-		if row.StakeTon <= 0 {
-			return fmt.Errorf("invalid stake_ton")
-		}
 
 		batch.Queue(
 			q,
@@ -95,6 +85,7 @@ func (r *BetsRepo) InsertAcceptedBets(ctx context.Context, rows []CreateBetRow)
 			row.Side,
 			row.Mode,
 			row.SeriesSessionID,
+			row.AutoplaySessionID,
 			row.ItemID,
 			row.ItemType,
 			row.ItemName,
@@ -115,7 +106,149 @@ func (r *BetsRepo) InsertAcceptedBets(ctx context.Context, rows []CreateBetRow)
 	return nil
 }
 
-func (r *BetsRepo) ItemIDsForGame(ctx context.Context, gameID int) ([]int, error) {
+func checkBetRow(row CreateBetRow) error {
+	if row.GameID <= 0 {
+		return fmt.Errorf("invalid game_id")
+	}
+	if row.UserID <= 0 {
+		return fmt.Errorf("invalid user_id")
+	}
+	if row.Mode != "series" && row.Mode != "single" && row.Mode != "pool" && row.Mode != "multi" {
+		return fmt.Errorf("bad mode")
+	}
+	if row.Mode != "multi" && row.Side != "heads" && row.Side != "tails" && (row.Mode != "single" || row.Side != "edge") {
+		return fmt.Errorf("bad side")
+	}
+	if row.Side == "" {
+		return fmt.Errorf("bad side")
+	}
+	if row.ItemType == "" {
+		return fmt.Errorf("empty item_type")
+	}
+	if row.ItemName == "" {
+		return fmt.Errorf("empty item_name")
+	}
+	if row.StakeTon <= 0 {
+		return fmt.Errorf("invalid stake_ton")
+	}
+	return nil
+}
+
+func (r *BetsRepo) InsertWalletBet(ctx context.Context, row CreateBetRow) (err error) {
+	defer observe(ctx, "bets", "InsertWalletBet", time.Now(), &err)
+	row.ItemType = "wallet"
+	if err := checkBetRow(row); err != nil {
+		return err
+	}
+	if row.Mode == "series" {
+		return fmt.Errorf("bad mode")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	const debitQ = `
+		UPDATE twist_business.user_wallets
+		SET
+			balance_ton = balance_ton - ROUND($2::numeric, 8),
+			updated_at = now()
+		WHERE user_id = $1
+		  AND balance_ton >= ROUND($2::numeric, 8)
+	`
+	tag, err := tx.Exec(ctx, debitQ, row.UserID, row.StakeTon)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInsufficientFunds
+	}
+
+	const betQ = `
+		INSERT INTO twist_business.game_bets (
+			game_id,
+			user_id,
+			side,
+			mode,
+			autoplay_session_id,
+			item_id,
+			item_type,
+			item_name,
+			stake_ton,
+			status,
+			payout_ton
+		)
+		VALUES ($1, $2, $3, $4, $5, NULL, $6, $7, ROUND($8::numeric, 8), 'accepted', 0)
+		RETURNING id
+	`
+	var betID int64
+	if err := tx.QueryRow(ctx, betQ,
+		row.GameID,
+		row.UserID,
+		row.Side,
+		row.Mode,
+		row.AutoplaySessionID,
+		row.ItemType,
+		row.ItemName,
+		row.StakeTon,
+	).Scan(&betID); err != nil {
+		return err
+	}
+
+	const stakeQ = `
+		INSERT INTO twist_business.wallet_transactions (user_id, game_id, bet_id, kind, amount_ton)
+		VALUES ($1, $2, $3, 'bet_stake', ROUND($4::numeric, 8))
+	`
+	if _, err := tx.Exec(ctx, stakeQ, row.UserID, row.GameID, betID, row.StakeTon); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *BetsRepo) RefundWalletBets(ctx context.Context, gameID int) (_ int64, err error) {
+	defer observe(ctx, "bets", "RefundWalletBets", time.Now(), &err)
+	if gameID <= 0 {
+		return 0, fmt.Errorf("invalid game_id")
+	}
+
+	const q = `
+		WITH ins AS (
+			INSERT INTO twist_business.wallet_transactions (user_id, game_id, bet_id, kind, amount_ton)
+			SELECT b.user_id, b.game_id, b.id, 'bet_refund', b.stake_ton
+			FROM twist_business.game_bets b
+			JOIN twist_business.wallet_transactions st
+			  ON st.bet_id = b.id
+			 AND st.kind = 'bet_stake'
+			WHERE b.game_id = $1
+			  AND b.item_type = 'wallet'
+			  AND (b.status = 'cancelled' OR b.status LIKE '%\_refund')
+			ON CONFLICT DO NOTHING
+			RETURNING user_id, amount_ton
+		),
+		agg AS (
+			SELECT user_id, SUM(amount_ton) AS amount_ton
+			FROM ins
+			GROUP BY user_id
+		)
+		UPDATE twist_business.user_wallets uw
+		SET
+			balance_ton = uw.balance_ton + agg.amount_ton,
+			updated_at = now()
+		FROM agg
+		WHERE uw.user_id = agg.user_id
+	`
+	tag, err := r.db.Exec(ctx, q, gameID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *BetsRepo) ItemIDsForGame(ctx context.Context, gameID int) (_ []int, err error) {
+	defer observe(ctx, "bets", "ItemIDsForGame", time.Now(), &err)
 	if gameID <= 0 {
 		return nil, fmt.Errorf("invalid game_id")
 	}
@@ -124,6 +257,8 @@ func (r *BetsRepo) ItemIDsForGame(ctx context.Context, gameID int) ([]int, error
 		SELECT DISTINCT item_id
 		FROM twist_business.game_bets
 		WHERE game_id = $1
+		  AND item_id IS NOT NULL
+		  AND status NOT IN ('cancelled', 'pool_refund', 'single_refund')
 		ORDER BY item_id
 	`
 
@@ -148,3 +283,291 @@ func (r *BetsRepo) ItemIDsForGame(ctx context.Context, gameID int) ([]int, error
 
 	return out, nil
 }
+
+func (r *BetsRepo) CancelRoundBets(ctx context.Context, userID int64, gameID int, mode string) (_ []int, err error) {
+	defer observe(ctx, "bets", "CancelRoundBets", time.Now(), &err)
+	if userID <= 0 {
+		return nil, fmt.Errorf("invalid user_id")
+	}
+	if gameID <= 0 {
+		return nil, fmt.Errorf("invalid game_id")
+	}
+
+	const q = `
+		UPDATE twist_business.game_bets
+		SET
+			status = 'cancelled',
+			payout_ton = 0,
+			settled_at = now()
+		WHERE game_id = $1
+		  AND user_id = $2
+		  AND mode = $3
+		  AND status = 'accepted'
+		RETURNING item_id
+	`
+	rows, err := r.db.Query(ctx, q, gameID, userID, mode)
+	if err != nil {
+		return nil, err
+	}
+	return collectItemIDs(rows)
+}
+
+func (r *BetsRepo) Settle(ctx context.Context, gameID int, mode string, winning map[string]float64, pushed []string, refund bool) (_ []int, err error) {
+	defer observe(ctx, "bets", "Settle", time.Now(), &err)
+	if gameID <= 0 {
+		return nil, fmt.Errorf("invalid game_id")
+	}
+	if mode == "" || mode == "series" {
+		return nil, fmt.Errorf("bad mode")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var itemIDs []int
+	if refund || len(pushed) > 0 {
+		if refund {
+			pushed = nil
+		}
+		const rq = `
+			UPDATE twist_business.game_bets
+			SET
+				status = $2::text || '_refund',
+				payout_ton = stake_ton,
+				settled_at = now()
+			WHERE game_id = $1
+			  AND mode = $2
+			  AND status = 'accepted'
+			  AND ($3::text[] IS NULL OR side = ANY($3::text[]))
+			RETURNING item_id
+		`
+		rows, err := tx.Query(ctx, rq, gameID, mode, pushed)
+		if err != nil {
+			return nil, err
+		}
+		if itemIDs, err = collectItemIDs(rows); err != nil {
+			return nil, err
+		}
+	}
+	if !refund {
+		if err := settleWinners(ctx, tx, gameID, mode, winning); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return itemIDs, nil
+}
+
+func settleWinners(ctx context.Context, tx pgx.Tx, gameID int, mode string, winning map[string]float64) error {
+	sides := make([]string, 0, len(winning))
+	mults := make([]float64, 0, len(winning))
+	for side, m := range winning {
+		sides = append(sides, side)
+		mults = append(mults, m)
+	}
+
+	const bq = `
+		UPDATE twist_business.game_bets AS b
+		SET
+			status = CASE WHEN w.mult IS NULL THEN $2::text || '_lose' ELSE $2::text || '_win' END,
+			payout_ton = COALESCE(ROUND(b.stake_ton * w.mult::numeric, 8), 0),
+			settled_at = now()
+		FROM twist_business.game_bets AS g
+		LEFT JOIN unnest($3::text[], $4::float8[]) AS w(side, mult) ON w.side = g.side
+		WHERE b.id = g.id
+		  AND b.game_id = $1
+		  AND b.mode = $2
+		  AND b.status = 'accepted'
+	`
+	if _, err := tx.Exec(ctx, bq, gameID, mode, sides, mults); err != nil {
+		return err
+	}
+
+	const ensureWalletsQ = `
+		INSERT INTO twist_business.user_wallets (user_id, balance_ton)
+		SELECT DISTINCT user_id, 0
+		FROM twist_business.game_bets
+		WHERE game_id = $1
+		  AND status = $2::text || '_win'
+		ON CONFLICT (user_id) DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, ensureWalletsQ, gameID, mode); err != nil {
+		return err
+	}
+
+	const creditQ = `
+		WITH ins AS (
+			INSERT INTO twist_business.wallet_transactions (
+				user_id,
+				game_id,
+				kind,
+				amount_ton
+			)
+			SELECT user_id, game_id, $2::text || '_win', SUM(payout_ton)
+			FROM twist_business.game_bets
+			WHERE game_id = $1
+			  AND status = $2::text || '_win'
+			GROUP BY user_id, game_id
+			HAVING SUM(payout_ton) > 0
+			ON CONFLICT DO NOTHING
+			RETURNING user_id, amount_ton
+		)
+		UPDATE twist_business.user_wallets uw
+		SET
+			balance_ton = uw.balance_ton + ins.amount_ton,
+			updated_at = now()
+		FROM ins
+		WHERE uw.user_id = ins.user_id
+	`
+	_, err := tx.Exec(ctx, creditQ, gameID, mode)
+	return err
+}
+
+func collectItemIDs(rows pgx.Rows) ([]int, error) {
+	defer rows.Close()
+
+	out := make([]int, 0)
+	for rows.Next() {
+		var itemID *int64
+		if err := rows.Scan(&itemID); err != nil {
+			return nil, err
+		}
+		if itemID != nil {
+			out = append(out, int(*itemID))
+		}
+	}
+	return out, rows.Err()
+}
+
+type GameBet struct {
+	ID              int64
+	GameID          int64
+	UserID          int64
+	Side            string
+	Mode            string
+	SeriesSessionID *int64
+	ItemID          int64
+	ItemType        string
+	ItemName        string
+	ItemPhotoURL    *string
+	StakeTon        float64
+	Status          string
+	PayoutTon       float64
+	CreatedAt       time.Time
+	SettledAt       *time.Time
+}
+
+const gameBetSelect = `
+		SELECT
+			id,
+			game_id,
+			user_id,
+			side,
+			mode,
+			series_session_id,
+			COALESCE(item_id, 0),
+			item_type,
+			item_name,
+			item_photo_url,
+			stake_ton,
+			status,
+			payout_ton,
+			created_at,
+			settled_at
+		FROM twist_business.game_bets
+`
+
+func (r *BetsRepo) ListByGame(ctx context.Context, gameID int) (_ []GameBet, err error) {
+	defer observe(ctx, "bets", "ListByGame", time.Now(), &err)
+	if gameID <= 0 {
+		return nil, fmt.Errorf("invalid game_id")
+	}
+
+	q := gameBetSelect + `
+		WHERE game_id = $1
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, q, gameID)
+	if err != nil {
+		return nil, err
+	}
+	return collectGameBets(rows)
+}
+
+func (r *BetsRepo) ListByUser(ctx context.Context, userID int64, beforeID int64, limit int) (_ []GameBet, err error) {
+	defer observe(ctx, "bets", "ListByUser", time.Now(), &err)
+	if userID <= 0 {
+		return nil, fmt.Errorf("invalid user_id")
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit")
+	}
+
+	q := gameBetSelect + `
+		WHERE user_id = $1
+		  AND ($2::bigint = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, q, userID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectGameBets(rows)
+}
+
+func collectGameBets(rows pgx.Rows) ([]GameBet, error) {
+	defer rows.Close()
+
+	out := make([]GameBet, 0)
+	for rows.Next() {
+		var b GameBet
+		var seriesSessionID sql.NullInt64
+		var settledAt sql.NullTime
+
+		if err := rows.Scan(
+			&b.ID,
+			&b.GameID,
+			&b.UserID,
+			&b.Side,
+			&b.Mode,
+			&seriesSessionID,
+			&b.ItemID,
+			&b.ItemType,
+			&b.ItemName,
+			&b.ItemPhotoURL,
+			&b.StakeTon,
+			&b.Status,
+			&b.PayoutTon,
+			&b.CreatedAt,
+			&settledAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if seriesSessionID.Valid {
+			v := seriesSessionID.Int64
+			b.SeriesSessionID = &v
+		}
+		if settledAt.Valid {
+			v := settledAt.Time
+			b.SettledAt = &v
+		}
+
+		out = append(out, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package postgres
+
+import "testing"
+
+func TestCheckBetRowSides(t *testing.T) {
+	tests := []struct {
+		mode string
+		side string
+		ok   bool
+	}{
+		{"single", "heads", true},
+		{"single", "tails", true},
+		{"single", "edge", true},
+		{"series", "heads", true},
+		{"series", "edge", false},
+		{"pool", "edge", false},
+		{"pool", "tails", true},
+		{"multi", "exact:2", true},
+		{"multi", "", false},
+		{"single", "side", false},
+		{"duel", "heads", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+tt.side, func(t *testing.T) {
+			row := CreateBetRow{
+				GameID:   1,
+				UserID:   1,
+				Side:     tt.side,
+				Mode:     tt.mode,
+				ItemType: "gift",
+				ItemName: "gift",
+				StakeTon: 1,
+			}
+			if err := checkBetRow(row); (err == nil) != tt.ok {
+				t.Fatalf("checkBetRow(%s, %s) = %v, want ok %v", tt.mode, tt.side, err, tt.ok)
+			}
+		})
+	}
+}
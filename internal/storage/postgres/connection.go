@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"CoinFlip/internal/tracing"
 	"context"
 	"time"
 
@@ -16,6 +17,7 @@ func NewPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
 	cfg.MaxConns = 10
 	cfg.MinConns = 1
 	cfg.MaxConnLifetime = time.Hour
+	cfg.ConnConfig.Tracer = tracing.PgxTracer{}
 
 	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
@@ -0,0 +1,371 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DuelItemRow struct {
+	UserID       int64
+	Side         string
+	ItemID       int
+	ItemType     string
+	ItemName     string
+	ItemPhotoURL *string
+	StakeTon     float64
+}
+
+type CreateDuelParams struct {
+	DuelID       int64
+	Hash         string
+	Seed         string
+	CreatorID    int64
+	CreatorSide  string
+	CreatorStake float64
+	ExpiresAt    time.Time
+	Items        []DuelItemRow
+}
+
+type ResolveDuelParams struct {
+	DuelID        int64
+	OpponentID    int64
+	OpponentStake float64
+	ResultSide    string
+	WinnerID      int64
+	FeeMode       string
+	FeeTon        float64
+	FeeItemID     int
+	Items         []DuelItemRow
+}
+
+type DuelsRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewDuelsRepo(db *pgxpool.Pool) *DuelsRepo {
+	return &DuelsRepo{db: db}
+}
+
+func (r *DuelsRepo) NextDuelID(ctx context.Context) (_ int64, err error) {
+	defer observe(ctx, "duels", "NextDuelID", time.Now(), &err)
+	const q = `
+		SELECT COALESCE(MAX(duel_id), 0) + 1
+		FROM twist_business.coinflip_duels
+	`
+
+	var next int64
+	if err := r.db.QueryRow(ctx, q).Scan(&next); err != nil {
+		return 0, err
+	}
+	if next <= 0 {
+		next = 1
+	}
+	return next, nil
+}
+
+func (r *DuelsRepo) Create(ctx context.Context, p CreateDuelParams) (err error) {
+	defer observe(ctx, "duels", "Create", time.Now(), &err)
+	if p.DuelID <= 0 {
+		return fmt.Errorf("invalid duel_id")
+	}
+	if p.CreatorID <= 0 {
+		return fmt.Errorf("invalid user_id")
+	}
+	if p.CreatorSide != "heads" && p.CreatorSide != "tails" {
+		return fmt.Errorf("bad side")
+	}
+	if len(p.Items) == 0 {
+		return fmt.Errorf("empty items")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	const q = `
+		INSERT INTO twist_business.coinflip_duels (
+			duel_id,
+			status,
+			hash,
+			seed,
+			creator_id,
+			creator_side,
+			creator_stake,
+			expires_at
+		)
+		VALUES ($1, 'open', $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := tx.Exec(ctx, q, p.DuelID, p.Hash, p.Seed, p.CreatorID, p.CreatorSide, p.CreatorStake, p.ExpiresAt); err != nil {
+		return err
+	}
+
+	if err := insertDuelItems(ctx, tx, p.DuelID, p.Items); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *DuelsRepo) Delete(ctx context.Context, duelID int64) (err error) {
+	defer observe(ctx, "duels", "Delete", time.Now(), &err)
+	const q = `
+		DELETE FROM twist_business.coinflip_duels
+		WHERE duel_id = $1
+		  AND status = 'open'
+	`
+	_, err = r.db.Exec(ctx, q, duelID)
+	return err
+}
+
+func (r *DuelsRepo) Resolve(ctx context.Context, p ResolveDuelParams) (fromWallet bool, err error) {
+	defer observe(ctx, "duels", "Resolve", time.Now(), &err)
+	if p.DuelID <= 0 {
+		return false, fmt.Errorf("invalid duel_id")
+	}
+	if p.OpponentID <= 0 || p.WinnerID <= 0 {
+		return false, fmt.Errorf("invalid user_id")
+	}
+	if len(p.Items) == 0 {
+		return false, fmt.Errorf("empty items")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	const lockQ = `
+		SELECT duel_id
+		FROM twist_business.coinflip_duels
+		WHERE duel_id = $1
+		  AND status = 'open'
+		FOR UPDATE
+	`
+	var id int64
+	if err := tx.QueryRow(ctx, lockQ, p.DuelID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrNotFound
+		}
+		return false, err
+	}
+
+	if err := insertDuelItems(ctx, tx, p.DuelID, p.Items); err != nil {
+		return false, err
+	}
+
+	if p.FeeTon > 0 && (p.FeeMode == "ton" || p.FeeItemID == 0) {
+		const debitQ = `
+			UPDATE twist_business.user_wallets
+			SET
+				balance_ton = balance_ton - $2,
+				updated_at = now()
+			WHERE user_id = $1
+			  AND balance_ton >= $2
+		`
+		tag, err := tx.Exec(ctx, debitQ, p.WinnerID, p.FeeTon)
+		if err != nil {
+			return false, err
+		}
+		fromWallet = tag.RowsAffected() == 1
+	}
+
+	if fromWallet {
+		const feeQ = `
+			INSERT INTO twist_business.wallet_transactions (user_id, duel_id, kind, amount_ton)
+			VALUES ($1, $2, 'duel_fee', $3)
+		`
+		if _, err := tx.Exec(ctx, feeQ, p.WinnerID, p.DuelID, p.FeeTon); err != nil {
+			return false, err
+		}
+	}
+
+	feeMode, feeTon, feeItemID := p.FeeMode, p.FeeTon, p.FeeItemID
+	if fromWallet {
+		feeMode, feeItemID = "ton", 0
+	} else if feeItemID > 0 {
+		feeMode = "item"
+	} else {
+		feeMode, feeTon = "", 0
+	}
+
+	const itemsQ = `
+		UPDATE twist_business.coinflip_duel_items
+		SET
+			status = CASE
+				WHEN item_id = $3 THEN 'duel_fee'
+				WHEN user_id = $2 THEN 'duel_win'
+				ELSE 'duel_lose'
+			END,
+			payout_ton = CASE WHEN item_id = $3 THEN 0 ELSE stake_ton END,
+			settled_at = now()
+		WHERE duel_id = $1
+		RETURNING item_id, stake_ton
+	`
+	rows, err := tx.Query(ctx, itemsQ, p.DuelID, p.WinnerID, feeItemID)
+	if err != nil {
+		return false, err
+	}
+	itemIDs := make([]int, 0)
+	for rows.Next() {
+		var itemID int
+		var stake float64
+		if err := rows.Scan(&itemID, &stake); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if itemID == feeItemID {
+			feeTon = stake
+			continue
+		}
+		itemIDs = append(itemIDs, itemID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	const transferQ = `
+		UPDATE twist_business.items
+		SET
+			user_id = $2,
+			locked = false
+		WHERE item_id = ANY($1)
+		  AND locked = true
+	`
+	if _, err := tx.Exec(ctx, transferQ, itemIDs, p.WinnerID); err != nil {
+		return false, err
+	}
+
+	if feeItemID > 0 {
+		const takeQ = `
+			DELETE FROM twist_business.items
+			WHERE item_id = $1
+			  AND locked = true
+		`
+		if _, err := tx.Exec(ctx, takeQ, feeItemID); err != nil {
+			return false, err
+		}
+	}
+
+	const duelQ = `
+		UPDATE twist_business.coinflip_duels
+		SET
+			status = 'resolved',
+			opponent_id = $2,
+			opponent_stake = $3,
+			result_side = $4,
+			winner_id = $5,
+			fee_mode = NULLIF($6, ''),
+			fee_ton = $7,
+			fee_item_id = NULLIF($8, 0),
+			settled_at = now()
+		WHERE duel_id = $1
+	`
+	if _, err := tx.Exec(ctx, duelQ,
+		p.DuelID,
+		p.OpponentID,
+		p.OpponentStake,
+		p.ResultSide,
+		p.WinnerID,
+		feeMode,
+		feeTon,
+		int64(feeItemID),
+	); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return fromWallet, nil
+}
+
+func (r *DuelsRepo) Expire(ctx context.Context, duelID int64) (_ []int, err error) {
+	defer observe(ctx, "duels", "Expire", time.Now(), &err)
+	return r.expire(ctx, duelID)
+}
+
+func (r *DuelsRepo) ExpireOpen(ctx context.Context) (_ []int, err error) {
+	defer observe(ctx, "duels", "ExpireOpen", time.Now(), &err)
+	return r.expire(ctx, 0)
+}
+
+func (r *DuelsRepo) expire(ctx context.Context, duelID int64) ([]int, error) {
+	const q = `
+		WITH expired AS (
+			UPDATE twist_business.coinflip_duels
+			SET
+				status = 'expired',
+				settled_at = now()
+			WHERE status = 'open'
+			  AND ($1::bigint = 0 OR duel_id = $1)
+			RETURNING duel_id
+		)
+		UPDATE twist_business.coinflip_duel_items AS i
+		SET
+			status = 'expired',
+			settled_at = now()
+		FROM expired
+		WHERE i.duel_id = expired.duel_id
+		RETURNING i.item_id
+	`
+	rows, err := r.db.Query(ctx, q, duelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	itemIDs := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		itemIDs = append(itemIDs, id)
+	}
+	return itemIDs, rows.Err()
+}
+
+func insertDuelItems(ctx context.Context, tx pgx.Tx, duelID int64, items []DuelItemRow) error {
+	const q = `
+		INSERT INTO twist_business.coinflip_duel_items (
+			duel_id,
+			user_id,
+			side,
+			item_id,
+			item_type,
+			item_name,
+			item_photo_url,
+			stake_ton
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	batch := &pgx.Batch{}
+	for _, it := range items {
+		if it.UserID <= 0 {
+			return fmt.Errorf("invalid user_id")
+		}
+		if it.ItemID <= 0 {
+			return fmt.Errorf("invalid item_id")
+		}
+		batch.Queue(q, duelID, it.UserID, it.Side, it.ItemID, it.ItemType, it.ItemName, it.ItemPhotoURL, it.StakeTon)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	for range items {
+		if _, err := br.Exec(); err != nil {
+			_ = br.Close()
+			return err
+		}
+	}
+	return br.Close()
+}
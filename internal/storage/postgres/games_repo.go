@@ -13,10 +13,13 @@ import (
 
 type GameRound struct {
 	GameID           int64
+	TableID          int
 	Phase            string
 	Hash             string
 	Seed             string
 	ResultSide       *string
+	CoinFaces        []string
+	EdgeOdds         *int
 	CreatedAt        time.Time
 	BettingStartedAt *time.Time
 	ResultStartedAt  *time.Time
@@ -31,7 +34,8 @@ func NewGamesRepo(db *pgxpool.Pool) *GamesRepo {
 	return &GamesRepo{db: db}
 }
 
-func (r *GamesRepo) NextGameID(ctx context.Context) (int, error) {
+func (r *GamesRepo) NextGameID(ctx context.Context) (_ int, err error) {
+	defer observe(ctx, "games", "NextGameID", time.Now(), &err)
 	const q = `
 		SELECT COALESCE(MAX(game_id), 0) + 1
 		FROM twist_business.game_rounds
@@ -47,7 +51,11 @@ func (r *GamesRepo) NextGameID(ctx context.Context) (int, error) {
 	return int(next), nil
 }
 
-func (r *GamesRepo) EnsureRound(ctx context.Context, gameID int, phase, hash, seed string) error {
+func (r *GamesRepo) EnsureRound(ctx context.Context, tableID, gameID int, phase, hash, seed string) (err error) {
+	defer observe(ctx, "games", "EnsureRound", time.Now(), &err)
+	if tableID <= 0 {
+		return fmt.Errorf("invalid table_id")
+	}
 	if gameID <= 0 {
 		return fmt.Errorf("invalid game_id")
 	}
@@ -63,19 +71,20 @@ func (r *GamesRepo) EnsureRound(ctx context.Context, gameID int, phase, hash, se
 
 	const q = `
 		INSERT INTO twist_business.game_rounds (
-			game_id, phase, hash, seed
+			game_id, table_id, phase, hash, seed
 		)
-		VALUES ($1, $2, $3, $4)
+		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (game_id) DO UPDATE SET
 			phase = EXCLUDED.phase,
 			hash  = EXCLUDED.hash,
 			seed  = EXCLUDED.seed
 	`
-	_, err := r.db.Exec(ctx, q, gameID, phase, hash, seed)
+	_, err = r.db.Exec(ctx, q, gameID, tableID, phase, hash, seed)
 	return err
 }
 
-func (r *GamesRepo) SetPhase(ctx context.Context, gameID int, phase string) error {
+func (r *GamesRepo) SetPhase(ctx context.Context, gameID int, phase string) (err error) {
+	defer observe(ctx, "games", "SetPhase", time.Now(), &err)
 	if gameID <= 0 {
 		return fmt.Errorf("invalid game_id")
 	}
@@ -101,15 +110,16 @@ func (r *GamesRepo) SetPhase(ctx context.Context, gameID int, phase string) erro
 			END
 		WHERE game_id = $1
 	`
-	_, err := r.db.Exec(ctx, q, gameID, phase)
+	_, err = r.db.Exec(ctx, q, gameID, phase)
 	return err
 }
 
-func (r *GamesRepo) FinishRound(ctx context.Context, gameID int, resultSide, seed string) error {
+func (r *GamesRepo) FinishRound(ctx context.Context, gameID int, resultSide, seed string, faces []string, edgeOdds int) (err error) {
+	defer observe(ctx, "games", "FinishRound", time.Now(), &err)
 	if gameID <= 0 {
 		return fmt.Errorf("invalid game_id")
 	}
-	if resultSide != "heads" && resultSide != "tails" {
+	if resultSide != "heads" && resultSide != "tails" && resultSide != "edge" {
 		return fmt.Errorf("bad result_side")
 	}
 	if seed == "" {
@@ -122,14 +132,22 @@ func (r *GamesRepo) FinishRound(ctx context.Context, gameID int, resultSide, see
 			phase = 'finished',
 			result_side = $2,
 			seed = $3,
+			coin_count = GREATEST(COALESCE(cardinality($4::text[]), 1), 1),
+			coin_faces = $4,
+			heads_count = CASE
+				WHEN $4::text[] IS NULL THEN NULL
+				ELSE (SELECT COUNT(*) FROM unnest($4::text[]) AS f WHERE f = 'heads')
+			END,
+			edge_odds = NULLIF($5::int, 0),
 			finished_at = now()
 		WHERE game_id = $1
 	`
-	_, err := r.db.Exec(ctx, q, gameID, resultSide, seed)
+	_, err = r.db.Exec(ctx, q, gameID, resultSide, seed, faces, edgeOdds)
 	return err
 }
 
-func (r *GamesRepo) Get(ctx context.Context, gameID int) (*GameRound, error) {
+func (r *GamesRepo) Get(ctx context.Context, gameID int) (_ *GameRound, err error) {
+	defer observe(ctx, "games", "Get", time.Now(), &err)
 	if gameID <= 0 {
 		return nil, fmt.Errorf("invalid game_id")
 	}
@@ -137,10 +155,13 @@ func (r *GamesRepo) Get(ctx context.Context, gameID int) (*GameRound, error) {
 	const q = `
 		SELECT
 			game_id,
+			table_id,
 			phase,
 			hash,
 			seed,
 			result_side,
+			coin_faces,
+			edge_odds,
 			created_at,
 			betting_started_at,
 			result_started_at,
@@ -157,12 +178,15 @@ func (r *GamesRepo) Get(ctx context.Context, gameID int) (*GameRound, error) {
 	var resultStartedAt sql.NullTime
 	var finishedAt sql.NullTime
 
-	err := row.Scan(
+	err = row.Scan(
 		&out.GameID,
+		&out.TableID,
 		&out.Phase,
 		&out.Hash,
 		&out.Seed,
 		&resultSide,
+		&out.CoinFaces,
+		&out.EdgeOdds,
 		&out.CreatedAt,
 		&bettingStartedAt,
 		&resultStartedAt,
@@ -194,3 +218,161 @@ func (r *GamesRepo) Get(ctx context.Context, gameID int) (*GameRound, error) {
 
 	return &out, nil
 }
+
+type RoundSummary struct {
+	GameRound
+	BetsCount      int64
+	TotalStakeTon  float64
+	TotalPayoutTon float64
+}
+
+const roundSummarySelect = `
+		SELECT
+			r.game_id,
+			r.table_id,
+			r.phase,
+			r.hash,
+			r.seed,
+			r.result_side,
+			r.coin_faces,
+			r.edge_odds,
+			r.created_at,
+			r.betting_started_at,
+			r.result_started_at,
+			r.finished_at,
+			COALESCE(t.bets_count, 0),
+			COALESCE(t.total_stake, 0),
+			COALESCE(t.total_payout, 0)
+		FROM twist_business.game_rounds r
+		LEFT JOIN LATERAL (
+			SELECT
+				COUNT(*)        AS bets_count,
+				SUM(stake_ton)  AS total_stake,
+				SUM(payout_ton) AS total_payout
+			FROM twist_business.game_bets b
+			WHERE b.game_id = r.game_id
+			  AND b.status <> 'cancelled'
+		) t ON TRUE
+`
+
+func (r *GamesRepo) ListFinished(ctx context.Context, tableID int, beforeGameID int64, limit int) (_ []RoundSummary, err error) {
+	defer observe(ctx, "games", "ListFinished", time.Now(), &err)
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit")
+	}
+
+	q := roundSummarySelect + `
+		WHERE r.phase = 'finished'
+		  AND ($1::bigint = 0 OR r.game_id < $1)
+		  AND ($3::int = 0 OR r.table_id = $3)
+		ORDER BY r.game_id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, q, beforeGameID, limit, tableID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]RoundSummary, 0, limit)
+	for rows.Next() {
+		s, err := scanRoundSummary(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (r *GamesRepo) GetSummary(ctx context.Context, gameID int) (_ *RoundSummary, err error) {
+	defer observe(ctx, "games", "GetSummary", time.Now(), &err)
+	if gameID <= 0 {
+		return nil, fmt.Errorf("invalid game_id")
+	}
+
+	q := roundSummarySelect + `
+		WHERE r.game_id = $1
+	`
+
+	s, err := scanRoundSummary(r.db.QueryRow(ctx, q, gameID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+func scanRoundSummary(row pgx.Row) (*RoundSummary, error) {
+	var out RoundSummary
+	var resultSide sql.NullString
+	var bettingStartedAt sql.NullTime
+	var resultStartedAt sql.NullTime
+	var finishedAt sql.NullTime
+
+	err := row.Scan(
+		&out.GameID,
+		&out.TableID,
+		&out.Phase,
+		&out.Hash,
+		&out.Seed,
+		&resultSide,
+		&out.CoinFaces,
+		&out.EdgeOdds,
+		&out.CreatedAt,
+		&bettingStartedAt,
+		&resultStartedAt,
+		&finishedAt,
+		&out.BetsCount,
+		&out.TotalStakeTon,
+		&out.TotalPayoutTon,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if resultSide.Valid {
+		s := resultSide.String
+		out.ResultSide = &s
+	}
+	if bettingStartedAt.Valid {
+		t := bettingStartedAt.Time
+		out.BettingStartedAt = &t
+	}
+	if resultStartedAt.Valid {
+		t := resultStartedAt.Time
+		out.ResultStartedAt = &t
+	}
+	if finishedAt.Valid {
+		t := finishedAt.Time
+		out.FinishedAt = &t
+	}
+
+	return &out, nil
+}
+
+func (r *GamesRepo) VoidRound(ctx context.Context, gameID int) (err error) {
+	defer observe(ctx, "games", "VoidRound", time.Now(), &err)
+	if gameID <= 0 {
+		return fmt.Errorf("invalid game_id")
+	}
+
+	const q = `
+		UPDATE twist_business.game_rounds
+		SET
+			phase = 'finished',
+			result_side = NULL,
+			voided_at = now(),
+			finished_at = COALESCE(finished_at, now())
+		WHERE game_id = $1
+	`
+	_, err = r.db.Exec(ctx, q, gameID)
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -31,7 +32,8 @@ func NewItemsRepo(db *pgxpool.Pool) *ItemsRepo {
 	return &ItemsRepo{db: db}
 }
 
-func (r *ItemsRepo) LockItem(ctx context.Context, itemID int, userID int64) (*Item, error) {
+func (r *ItemsRepo) LockItem(ctx context.Context, itemID int, userID int64) (_ *Item, err error) {
+	defer observe(ctx, "items", "LockItem", time.Now(), &err)
 	const q = `
 		UPDATE twist_business.items
 		SET locked = true
@@ -50,7 +52,8 @@ func (r *ItemsRepo) LockItem(ctx context.Context, itemID int, userID int64) (*It
 	return &it, nil
 }
 
-func (r *ItemsRepo) LockItems(ctx context.Context, itemIDs []int, userID int64) ([]Item, error) {
+func (r *ItemsRepo) LockItems(ctx context.Context, itemIDs []int, userID int64) (_ []Item, err error) {
+	defer observe(ctx, "items", "LockItems", time.Now(), &err)
 	if len(itemIDs) == 0 {
 		return nil, fmt.Errorf("empty itemIDs")
 	}
@@ -102,17 +105,19 @@ func (r *ItemsRepo) LockItems(ctx context.Context, itemIDs []int, userID int64)
 	return out, nil
 }
 
-func (r *ItemsRepo) UnlockItem(ctx context.Context, itemID int) error {
+func (r *ItemsRepo) UnlockItem(ctx context.Context, itemID int) (err error) {
+	defer observe(ctx, "items", "UnlockItem", time.Now(), &err)
 	const q = `
 		UPDATE twist_business.items
 		SET locked = false
 		WHERE item_id = $1
 	`
-	_, err := r.db.Exec(ctx, q, itemID)
+	_, err = r.db.Exec(ctx, q, itemID)
 	return err
 }
 
-func (r *ItemsRepo) UnlockItems(ctx context.Context, itemIDs []int) error {
+func (r *ItemsRepo) UnlockItems(ctx context.Context, itemIDs []int) (err error) {
+	defer observe(ctx, "items", "UnlockItems", time.Now(), &err)
 	if len(itemIDs) == 0 {
 		return nil
 	}
@@ -121,11 +126,12 @@ func (r *ItemsRepo) UnlockItems(ctx context.Context, itemIDs []int) error {
 		SET locked = false
 		WHERE item_id = ANY($1)
 	`
-	_, err := r.db.Exec(ctx, q, itemIDs)
+	_, err = r.db.Exec(ctx, q, itemIDs)
 	return err
 }
 
-func (r *ItemsRepo) ConsumeLockedItems(ctx context.Context, itemIDs []int) (int64, error) {
+func (r *ItemsRepo) ConsumeLockedItems(ctx context.Context, itemIDs []int) (_ int64, err error) {
+	defer observe(ctx, "items", "ConsumeLockedItems", time.Now(), &err)
 	if len(itemIDs) == 0 {
 		return 0, nil
 	}
@@ -143,3 +149,38 @@ func (r *ItemsRepo) ConsumeLockedItems(ctx context.Context, itemIDs []int) (int6
 
 	return tag.RowsAffected(), nil
 }
+
+func (r *ItemsRepo) AvailableItems(ctx context.Context, itemIDs []int, userID int64) (_ []Item, err error) {
+	defer observe(ctx, "items", "AvailableItems", time.Now(), &err)
+	if len(itemIDs) == 0 {
+		return nil, nil
+	}
+	if userID <= 0 {
+		return nil, fmt.Errorf("invalid user_id")
+	}
+
+	const q = `
+		SELECT item_id, user_id, name, photo_url, cost_ton, type, locked
+		FROM twist_business.items
+		WHERE item_id = ANY($1)
+		  AND user_id = $2
+		  AND locked = false
+		ORDER BY array_position($1, item_id)
+	`
+
+	rows, err := r.db.Query(ctx, q, itemIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Item, 0, len(itemIDs))
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.ItemID, &it.UserID, &it.Name, &it.PhotoURL, &it.CostTon, &it.Type, &it.Locked); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
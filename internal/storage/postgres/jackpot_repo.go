@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type JackpotWinRow struct {
+	UserID    int64
+	Trigger   string
+	StakeTon  float64
+	AmountTon float64
+}
+
+type JackpotWin struct {
+	ID        int64
+	GameID    int64
+	TableID   int
+	UserID    int64
+	Trigger   string
+	StakeTon  float64
+	AmountTon float64
+	CreatedAt time.Time
+}
+
+type JackpotRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewJackpotRepo(db *pgxpool.Pool) *JackpotRepo {
+	return &JackpotRepo{db: db}
+}
+
+func (r *JackpotRepo) Balance(ctx context.Context) (_ float64, err error) {
+	defer observe(ctx, "jackpot", "Balance", time.Now(), &err)
+	const q = `
+		SELECT balance_ton::float8
+		FROM twist_business.coinflip_jackpot
+		WHERE id = 1
+	`
+
+	var balance float64
+	if err := r.db.QueryRow(ctx, q).Scan(&balance); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return balance, nil
+}
+
+func (r *JackpotRepo) Settle(ctx context.Context, gameID, tableID int, contribution float64, winners []JackpotWinRow) (_ float64, err error) {
+	defer observe(ctx, "jackpot", "Settle", time.Now(), &err)
+	if gameID <= 0 {
+		return 0, fmt.Errorf("invalid game_id")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	const lockQ = `
+		SELECT 1
+		FROM twist_business.coinflip_jackpot
+		WHERE id = 1
+		FOR UPDATE
+	`
+	if _, err := tx.Exec(ctx, lockQ); err != nil {
+		return 0, err
+	}
+
+	if contribution > 0 {
+		if _, err := bookJackpot(ctx, tx, gameID, tableID, "contribution", contribution); err != nil {
+			return 0, err
+		}
+	}
+
+	paid := 0.0
+	for _, w := range winners {
+		paid += w.AmountTon
+	}
+
+	if paid > 0 {
+		booked, err := bookJackpot(ctx, tx, gameID, tableID, "payout", -paid)
+		if err != nil {
+			return 0, err
+		}
+		if booked {
+			if err := creditJackpotWinners(ctx, tx, gameID, tableID, winners); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var balance float64
+	const balanceQ = `
+		SELECT balance_ton::float8
+		FROM twist_business.coinflip_jackpot
+		WHERE id = 1
+	`
+	if err := tx.QueryRow(ctx, balanceQ).Scan(&balance); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+func bookJackpot(ctx context.Context, tx pgx.Tx, gameID, tableID int, kind string, delta float64) (bool, error) {
+	const q = `
+		INSERT INTO twist_business.coinflip_jackpot_ledger (game_id, table_id, kind, amount_ton, balance_ton)
+		SELECT $1, $2, $3, ROUND(ABS($4::numeric), 8), GREATEST(balance_ton + ROUND($4::numeric, 8), 0)
+		FROM twist_business.coinflip_jackpot
+		WHERE id = 1
+		ON CONFLICT (game_id, kind) DO NOTHING
+	`
+	tag, err := tx.Exec(ctx, q, gameID, tableID, kind, delta)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	const applyQ = `
+		UPDATE twist_business.coinflip_jackpot
+		SET
+			balance_ton = GREATEST(balance_ton + ROUND($1::numeric, 8), 0),
+			updated_at = now()
+		WHERE id = 1
+	`
+	if _, err := tx.Exec(ctx, applyQ, delta); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func creditJackpotWinners(ctx context.Context, tx pgx.Tx, gameID, tableID int, winners []JackpotWinRow) error {
+	b := &pgx.Batch{}
+	for _, w := range winners {
+		if w.UserID <= 0 || w.AmountTon <= 0 {
+			continue
+		}
+		b.Queue(`
+			INSERT INTO twist_business.coinflip_jackpot_wins (game_id, table_id, user_id, trigger, stake_ton, amount_ton)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (game_id, user_id) DO NOTHING
+		`, gameID, tableID, w.UserID, w.Trigger, w.StakeTon, w.AmountTon)
+		b.Queue(`
+			INSERT INTO twist_business.user_wallets (user_id, balance_ton)
+			VALUES ($1, 0)
+			ON CONFLICT (user_id) DO NOTHING
+		`, w.UserID)
+		b.Queue(`
+			WITH ins AS (
+				INSERT INTO twist_business.wallet_transactions (user_id, game_id, kind, amount_ton)
+				VALUES ($1, $2, 'jackpot_win', $3)
+				ON CONFLICT DO NOTHING
+				RETURNING user_id, amount_ton
+			)
+			UPDATE twist_business.user_wallets uw
+			SET
+				balance_ton = uw.balance_ton + ins.amount_ton,
+				updated_at = now()
+			FROM ins
+			WHERE uw.user_id = ins.user_id
+		`, w.UserID, gameID, w.AmountTon)
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	return tx.SendBatch(ctx, b).Close()
+}
+
+func (r *JackpotRepo) RecentWins(ctx context.Context, limit int) (_ []JackpotWin, err error) {
+	defer observe(ctx, "jackpot", "RecentWins", time.Now(), &err)
+	if limit <= 0 {
+		limit = 20
+	}
+
+	const q = `
+		SELECT id, game_id, table_id, user_id, trigger, stake_ton::float8, amount_ton::float8, created_at
+		FROM twist_business.coinflip_jackpot_wins
+		ORDER BY id DESC
+		LIMIT $1
+	`
+
+	rows, err := r.db.Query(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]JackpotWin, 0)
+	for rows.Next() {
+		var w JackpotWin
+		if err := rows.Scan(&w.ID, &w.GameID, &w.TableID, &w.UserID, &w.Trigger, &w.StakeTon, &w.AmountTon, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
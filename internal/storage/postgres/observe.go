@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/metrics"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func observe(ctx context.Context, repo, method string, start time.Time, errp *error) {
+	var err error
+	if errp != nil {
+		err = *errp
+	}
+	metrics.ObserveQuery(repo, method, start, err)
+
+	if err == nil {
+		return
+	}
+
+	lvl := slog.LevelWarn
+	if isExpectedErr(err) {
+		lvl = slog.LevelDebug
+	}
+	logging.FromContext(ctx).Log(ctx, lvl, "db query failed",
+		"repo", repo,
+		"method", method,
+		"elapsed_ms", time.Since(start).Milliseconds(),
+		logging.KeyErr, err,
+	)
+}
+
+func isExpectedErr(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows) ||
+		errors.Is(err, ErrNotFound) ||
+		errors.Is(err, ErrAlreadyLocked) ||
+		errors.Is(err, ErrPartialLock) ||
+		errors.Is(err, ErrActiveSeriesNotFound) ||
+		errors.Is(err, ErrInvalidCredentials)
+}
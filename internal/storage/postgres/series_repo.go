@@ -46,7 +46,8 @@ func NewSeriesRepo(db *pgxpool.Pool) *SeriesRepo {
 	return &SeriesRepo{db: db}
 }
 
-func (r *SeriesRepo) CreateSession(ctx context.Context, p CreateSeriesSessionParams) (int64, error) {
+func (r *SeriesRepo) CreateSession(ctx context.Context, p CreateSeriesSessionParams) (_ int64, err error) {
+	defer observe(ctx, "series", "CreateSession", time.Now(), &err)
 	if p.UserID <= 0 {
 		return 0, fmt.Errorf("invalid user_id")
 	}
@@ -80,11 +81,12 @@ func (r *SeriesRepo) CreateSession(ctx context.Context, p CreateSeriesSessionPar
 	`
 
 	var sessionID int64
-	err := r.db.QueryRow(ctx, q, p.UserID, p.InitialGameID, p.CurrentSide, p.StakeTon).Scan(&sessionID)
+	err = r.db.QueryRow(ctx, q, p.UserID, p.InitialGameID, p.CurrentSide, p.StakeTon).Scan(&sessionID)
 	return sessionID, err
 }
 
-func (r *SeriesRepo) DeleteSession(ctx context.Context, sessionID int64) error {
+func (r *SeriesRepo) DeleteSession(ctx context.Context, sessionID int64) (err error) {
+	defer observe(ctx, "series", "DeleteSession", time.Now(), &err)
 	if sessionID <= 0 {
 		return fmt.Errorf("invalid session_id")
 	}
@@ -93,11 +95,80 @@ func (r *SeriesRepo) DeleteSession(ctx context.Context, sessionID int64) error {
 		DELETE FROM twist_business.series_sessions
 		WHERE id = $1
 	`
-	_, err := r.db.Exec(ctx, q, sessionID)
+	_, err = r.db.Exec(ctx, q, sessionID)
 	return err
 }
 
-func (r *SeriesRepo) GetActiveByUser(ctx context.Context, userID int64) (*SeriesSession, error) {
+func (r *SeriesRepo) CancelBet(ctx context.Context, userID int64, gameID int) (_ []int, err error) {
+	defer observe(ctx, "series", "CancelBet", time.Now(), &err)
+	if userID <= 0 {
+		return nil, fmt.Errorf("invalid user_id")
+	}
+	if gameID <= 0 {
+		return nil, fmt.Errorf("invalid game_id")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	s, err := r.getActiveForUpdate(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if s.Stage != "in_round" || s.RoundGameID == nil || *s.RoundGameID != int64(gameID) || s.Wins != 0 {
+		return nil, fmt.Errorf("series is not a fresh bet in game %d", gameID)
+	}
+
+	const bq = `
+		UPDATE twist_business.game_bets
+		SET
+			status = 'cancelled',
+			series_session_id = NULL,
+			payout_ton = 0,
+			settled_at = now()
+		WHERE game_id = $1
+		  AND user_id = $2
+		  AND series_session_id = $3
+		  AND status = 'accepted'
+		RETURNING item_id
+	`
+	rows, err := tx.Query(ctx, bq, gameID, userID, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	itemIDs := make([]int, 0)
+	for rows.Next() {
+		var itemID int64
+		if err := rows.Scan(&itemID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		itemIDs = append(itemIDs, int(itemID))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	const dq = `
+		DELETE FROM twist_business.series_sessions
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(ctx, dq, s.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return itemIDs, nil
+}
+
+func (r *SeriesRepo) GetActiveByUser(ctx context.Context, userID int64) (_ *SeriesSession, err error) {
+	defer observe(ctx, "series", "GetActiveByUser", time.Now(), &err)
 	if userID <= 0 {
 		return nil, fmt.Errorf("invalid user_id")
 	}
@@ -128,7 +199,8 @@ func (r *SeriesRepo) GetActiveByUser(ctx context.Context, userID int64) (*Series
 	return scanSeriesSession(row)
 }
 
-func (r *SeriesRepo) Continue(ctx context.Context, userID int64, gameID int, side string) error {
+func (r *SeriesRepo) Continue(ctx context.Context, userID int64, gameID int, side string) (err error) {
+	defer observe(ctx, "series", "Continue", time.Now(), &err)
 	if userID <= 0 {
 		return fmt.Errorf("invalid user_id")
 	}
@@ -177,7 +249,8 @@ func (r *SeriesRepo) MoveToAwaitingChoiceAfterWin(
 	wins int,
 	multiplier float64,
 	claimable float64,
-) (int64, error) {
+) (_ int64, err error) {
+	defer observe(ctx, "series", "MoveToAwaitingChoiceAfterWin", time.Now(), &err)
 	if userID <= 0 {
 		return 0, fmt.Errorf("invalid user_id")
 	}
@@ -264,7 +337,8 @@ func (r *SeriesRepo) MarkLost(
 	playedSide string,
 	wins int,
 	multiplier float64,
-) (int64, error) {
+) (_ int64, err error) {
+	defer observe(ctx, "series", "MarkLost", time.Now(), &err)
 	if userID <= 0 {
 		return 0, fmt.Errorf("invalid user_id")
 	}
@@ -338,7 +412,119 @@ func (r *SeriesRepo) MarkLost(
 	return s.ID, nil
 }
 
-func (r *SeriesRepo) Cashout(ctx context.Context, userID int64, gameID int, payout float64) (int64, error) {
+func (r *SeriesRepo) MarkPushed(
+	ctx context.Context,
+	userID int64,
+	gameID int,
+	playedSide string,
+	wins int,
+	multiplier float64,
+	claimable float64,
+) (_ []int, err error) {
+	defer observe(ctx, "series", "MarkPushed", time.Now(), &err)
+	if userID <= 0 {
+		return nil, fmt.Errorf("invalid user_id")
+	}
+	if gameID <= 0 {
+		return nil, fmt.Errorf("invalid game_id")
+	}
+	if playedSide != "heads" && playedSide != "tails" {
+		return nil, fmt.Errorf("bad played_side")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	s, err := r.getActiveForUpdate(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	const iq = `
+		INSERT INTO twist_business.series_steps (
+			session_id,
+			game_id,
+			event,
+			chosen_side,
+			wins_after,
+			multiplier_after,
+			claimable_after
+		)
+		VALUES ($1, $2, 'push', $3, $4, $5, $6)
+	`
+	if _, err := tx.Exec(ctx, iq, s.ID, gameID, playedSide, wins, multiplier, claimable); err != nil {
+		return nil, err
+	}
+
+	var itemIDs []int
+	if wins == 0 {
+		const uq = `
+			UPDATE twist_business.series_sessions
+			SET
+				active = FALSE,
+				stage = 'voided',
+				round_game_id = NULL,
+				current_side = NULL,
+				updated_at = now(),
+				closed_at = now()
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(ctx, uq, s.ID); err != nil {
+			return nil, err
+		}
+
+		const bq = `
+			UPDATE twist_business.game_bets
+			SET
+				status = 'cancelled',
+				payout_ton = 0,
+				settled_at = now()
+			WHERE series_session_id = $1
+			  AND status = 'accepted'
+			RETURNING item_id
+		`
+		rows, err := tx.Query(ctx, bq, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		if itemIDs, err = collectItemIDs(rows); err != nil {
+			return nil, err
+		}
+	} else {
+		const uq = `
+			UPDATE twist_business.series_sessions
+			SET
+				stage = 'awaiting_choice',
+				round_game_id = NULL,
+				current_side = NULL,
+				updated_at = now()
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(ctx, uq, s.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return itemIDs, nil
+}
+
+func (r *SeriesRepo) Cashout(ctx context.Context, userID int64, gameID int, payout float64) (_ int64, err error) {
+	defer observe(ctx, "series", "Cashout", time.Now(), &err)
+	return r.cashout(ctx, userID, gameID, payout, false)
+}
+
+func (r *SeriesRepo) ForceClose(ctx context.Context, userID int64, gameID int, payout float64) (_ int64, err error) {
+	defer observe(ctx, "series", "ForceClose", time.Now(), &err)
+	return r.cashout(ctx, userID, gameID, payout, true)
+}
+
+func (r *SeriesRepo) cashout(ctx context.Context, userID int64, gameID int, payout float64, allowInRound bool) (int64, error) {
 	if userID <= 0 {
 		return 0, fmt.Errorf("invalid user_id")
 	}
@@ -359,7 +545,7 @@ func (r *SeriesRepo) Cashout(ctx context.Context, userID int64, gameID int, payo
 	if err != nil {
 		return 0, err
 	}
-	if s.Stage != "awaiting_choice" {
+	if s.Stage != "awaiting_choice" && !(allowInRound && s.Stage == "in_round") {
 		return 0, fmt.Errorf("series is not awaiting_choice")
 	}
 
@@ -458,6 +644,141 @@ func (r *SeriesRepo) Cashout(ctx context.Context, userID int64, gameID int, payo
 	return s.ID, nil
 }
 
+func (r *SeriesRepo) VoidRound(ctx context.Context, gameID int) (err error) {
+	defer observe(ctx, "series", "VoidRound", time.Now(), &err)
+	if gameID <= 0 {
+		return fmt.Errorf("invalid game_id")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	const bq = `
+		UPDATE twist_business.game_bets
+		SET
+			status = 'cancelled',
+			payout_ton = 0,
+			settled_at = now()
+		WHERE game_id = $1
+		  AND status = 'accepted'
+	`
+	if _, err := tx.Exec(ctx, bq, gameID); err != nil {
+		return err
+	}
+
+	const steps = `
+		INSERT INTO twist_business.series_steps (
+			session_id,
+			game_id,
+			event,
+			chosen_side,
+			wins_after,
+			multiplier_after,
+			claimable_after
+		)
+		SELECT id, $1, 'void', current_side, wins, multiplier, claimable_ton
+		FROM twist_business.series_sessions
+		WHERE active = TRUE
+		  AND stage = 'in_round'
+		  AND round_game_id = $1
+	`
+	if _, err := tx.Exec(ctx, steps, gameID); err != nil {
+		return err
+	}
+
+	const fresh = `
+		UPDATE twist_business.series_sessions
+		SET
+			active = FALSE,
+			stage = 'voided',
+			round_game_id = NULL,
+			current_side = NULL,
+			updated_at = now(),
+			closed_at = now()
+		WHERE active = TRUE
+		  AND stage = 'in_round'
+		  AND round_game_id = $1
+		  AND wins = 0
+	`
+	if _, err := tx.Exec(ctx, fresh, gameID); err != nil {
+		return err
+	}
+
+	const restore = `
+		UPDATE twist_business.series_sessions
+		SET
+			stage = 'awaiting_choice',
+			round_game_id = NULL,
+			current_side = NULL,
+			updated_at = now()
+		WHERE active = TRUE
+		  AND stage = 'in_round'
+		  AND round_game_id = $1
+	`
+	if _, err := tx.Exec(ctx, restore, gameID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *SeriesRepo) ListByUser(ctx context.Context, userID int64, beforeID int64, limit int) (_ []SeriesSession, err error) {
+	defer observe(ctx, "series", "ListByUser", time.Now(), &err)
+	if userID <= 0 {
+		return nil, fmt.Errorf("invalid user_id")
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit")
+	}
+
+	const q = `
+		SELECT
+			id,
+			user_id,
+			initial_game_id,
+			active,
+			stage,
+			round_game_id,
+			current_side,
+			stake_ton,
+			wins,
+			multiplier,
+			claimable_ton,
+			cashed_out_payout_ton,
+			created_at,
+			updated_at,
+			closed_at
+		FROM twist_business.series_sessions
+		WHERE user_id = $1
+		  AND ($2::bigint = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, q, userID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]SeriesSession, 0, limit)
+	for rows.Next() {
+		s, err := scanSeriesSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
 func (r *SeriesRepo) getActiveForUpdate(ctx context.Context, tx pgx.Tx, userID int64) (*SeriesSession, error) {
 	const q = `
 		SELECT
@@ -536,4 +857,4 @@ func scanSeriesSession(row pgx.Row) (*SeriesSession, error) {
 	}
 
 	return &out, nil
-}
\ No newline at end of file
+}
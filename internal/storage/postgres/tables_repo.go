@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CoinflipTable struct {
+	ID                    int
+	Name                  string
+	BettingTime           *int
+	TimeTillResult        *int
+	NextGameDelay         *int
+	SingleMultiplier      *float64
+	SeriesFirstMultiplier *float64
+	SeriesStepMultiplier  *float64
+	PayoutMode            *string
+	PoolRakePercent       *float64
+	CoinCount             *int
+	CoinEdgePercent       *float64
+	MinStakeTon           *float64
+	MaxStakeTon           *float64
+	MaxBetItems           *int
+	UserRoundCapTon       *float64
+	MaxRoundLiabilityTon  *float64
+	ItemTypes             []string
+}
+
+type TablesRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewTablesRepo(db *pgxpool.Pool) *TablesRepo {
+	return &TablesRepo{db: db}
+}
+
+func (r *TablesRepo) ListActive(ctx context.Context) (_ []CoinflipTable, err error) {
+	defer observe(ctx, "tables", "ListActive", time.Now(), &err)
+
+	const q = `
+		SELECT
+			table_id,
+			table_name,
+			betting_time,
+			time_till_result,
+			next_game_delay,
+			single_multiplier::float8,
+			series_first_multiplier::float8,
+			series_step_multiplier::float8,
+			payout_mode,
+			pool_rake_percent::float8,
+			coin_count,
+			coin_edge_percent::float8,
+			min_stake_ton::float8,
+			max_stake_ton::float8,
+			max_bet_items,
+			user_round_cap_ton::float8,
+			max_round_liability_ton::float8,
+			item_types
+		FROM twist_business.coinflip_tables
+		WHERE active = TRUE
+		ORDER BY table_id
+	`
+
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]CoinflipTable, 0)
+	for rows.Next() {
+		var t CoinflipTable
+		if err := rows.Scan(
+			&t.ID,
+			&t.Name,
+			&t.BettingTime,
+			&t.TimeTillResult,
+			&t.NextGameDelay,
+			&t.SingleMultiplier,
+			&t.SeriesFirstMultiplier,
+			&t.SeriesStepMultiplier,
+			&t.PayoutMode,
+			&t.PoolRakePercent,
+			&t.CoinCount,
+			&t.CoinEdgePercent,
+			&t.MinStakeTon,
+			&t.MaxStakeTon,
+			&t.MaxBetItems,
+			&t.UserRoundCapTon,
+			&t.MaxRoundLiabilityTon,
+			&t.ItemTypes,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
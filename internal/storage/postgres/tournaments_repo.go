@@ -0,0 +1,413 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Tournament struct {
+	ID     int
+	Name   string
+	Period string
+}
+
+type TournamentRun struct {
+	ID               int64
+	TournamentID     int
+	Name             string
+	Period           string
+	StartsAt         time.Time
+	EndsAt           time.Time
+	Status           string
+	VolumePoints     float64
+	SeriesPoints     float64
+	MultiplierPoints float64
+	PrizesTon        []float64
+	ItemPrizeRanks   int
+}
+
+type TournamentScore struct {
+	UserID         int64
+	VolumeTon      float64
+	LongestSeries  int
+	BestMultiplier float64
+}
+
+type TournamentResult struct {
+	Rank           int
+	UserID         int64
+	Points         float64
+	VolumeTon      float64
+	LongestSeries  int
+	BestMultiplier float64
+	PrizeTon       float64
+	PrizeItemID    *int64
+}
+
+type TournamentsRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewTournamentsRepo(db *pgxpool.Pool) *TournamentsRepo {
+	return &TournamentsRepo{db: db}
+}
+
+func (r *TournamentsRepo) ListActive(ctx context.Context) (_ []Tournament, err error) {
+	defer observe(ctx, "tournaments", "ListActive", time.Now(), &err)
+	const q = `
+		SELECT tournament_id, name, period
+		FROM twist_business.coinflip_tournaments
+		WHERE active = TRUE
+		ORDER BY tournament_id
+	`
+
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Tournament, 0)
+	for rows.Next() {
+		var t Tournament
+		if err := rows.Scan(&t.ID, &t.Name, &t.Period); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (r *TournamentsRepo) EnsureRun(ctx context.Context, tournamentID int, startsAt, endsAt time.Time) (err error) {
+	defer observe(ctx, "tournaments", "EnsureRun", time.Now(), &err)
+	if tournamentID <= 0 {
+		return fmt.Errorf("invalid tournament_id")
+	}
+	if !endsAt.After(startsAt) {
+		return fmt.Errorf("invalid tournament window")
+	}
+
+	const q = `
+		INSERT INTO twist_business.coinflip_tournament_runs (tournament_id, starts_at, ends_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tournament_id, starts_at) DO NOTHING
+	`
+	_, err = r.db.Exec(ctx, q, tournamentID, startsAt, endsAt)
+	return err
+}
+
+const tournamentRunSelect = `
+		SELECT
+			r.id,
+			r.tournament_id,
+			t.name,
+			t.period,
+			r.starts_at,
+			r.ends_at,
+			r.status,
+			t.volume_points::float8,
+			t.series_points::float8,
+			t.multiplier_points::float8,
+			t.prizes_ton::float8[],
+			t.item_prize_ranks
+		FROM twist_business.coinflip_tournament_runs r
+		JOIN twist_business.coinflip_tournaments t ON t.tournament_id = r.tournament_id
+`
+
+func (r *TournamentsRepo) ListRuns(ctx context.Context, status string, limit int) (_ []TournamentRun, err error) {
+	defer observe(ctx, "tournaments", "ListRuns", time.Now(), &err)
+	if limit <= 0 {
+		limit = 20
+	}
+
+	q := tournamentRunSelect + `
+		WHERE ($1::text = '' OR r.status = $1)
+		ORDER BY r.ends_at DESC, r.id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, q, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]TournamentRun, 0)
+	for rows.Next() {
+		run, err := scanTournamentRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	return out, rows.Err()
+}
+
+func (r *TournamentsRepo) GetRun(ctx context.Context, runID int64) (_ *TournamentRun, err error) {
+	defer observe(ctx, "tournaments", "GetRun", time.Now(), &err)
+	if runID <= 0 {
+		return nil, fmt.Errorf("invalid run_id")
+	}
+
+	q := tournamentRunSelect + `
+		WHERE r.id = $1
+	`
+
+	run, err := scanTournamentRun(r.db.QueryRow(ctx, q, runID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func scanTournamentRun(row pgx.Row) (TournamentRun, error) {
+	var run TournamentRun
+	err := row.Scan(
+		&run.ID,
+		&run.TournamentID,
+		&run.Name,
+		&run.Period,
+		&run.StartsAt,
+		&run.EndsAt,
+		&run.Status,
+		&run.VolumePoints,
+		&run.SeriesPoints,
+		&run.MultiplierPoints,
+		&run.PrizesTon,
+		&run.ItemPrizeRanks,
+	)
+	return run, err
+}
+
+func (r *TournamentsRepo) Scores(ctx context.Context, startsAt, endsAt time.Time) (_ []TournamentScore, err error) {
+	defer observe(ctx, "tournaments", "Scores", time.Now(), &err)
+	const q = `
+		WITH vol AS (
+			SELECT user_id, SUM(stake_ton) AS volume_ton
+			FROM twist_business.game_bets
+			WHERE created_at >= $1
+			  AND created_at < $2
+			  AND status NOT IN ('accepted', 'cancelled')
+			  AND status NOT LIKE '%\_refund'
+			GROUP BY user_id
+		),
+		ser AS (
+			SELECT
+				s.user_id,
+				MAX(st.wins_after) FILTER (WHERE st.event = 'win') AS longest_series,
+				MAX(st.multiplier_after) FILTER (WHERE st.event = 'cashout') AS best_multiplier
+			FROM twist_business.series_steps st
+			JOIN twist_business.series_sessions s ON s.id = st.session_id
+			WHERE st.created_at >= $1
+			  AND st.created_at < $2
+			GROUP BY s.user_id
+		)
+		SELECT
+			COALESCE(v.user_id, s.user_id),
+			COALESCE(v.volume_ton, 0)::float8,
+			COALESCE(s.longest_series, 0),
+			COALESCE(s.best_multiplier, 0)::float8
+		FROM vol v
+		FULL JOIN ser s ON s.user_id = v.user_id
+	`
+
+	rows, err := r.db.Query(ctx, q, startsAt, endsAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]TournamentScore, 0)
+	for rows.Next() {
+		var s TournamentScore
+		if err := rows.Scan(&s.UserID, &s.VolumeTon, &s.LongestSeries, &s.BestMultiplier); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *TournamentsRepo) Settle(ctx context.Context, runID int64, results []TournamentResult) (_ []TournamentResult, err error) {
+	defer observe(ctx, "tournaments", "Settle", time.Now(), &err)
+	if runID <= 0 {
+		return nil, fmt.Errorf("invalid run_id")
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	const lockQ = `
+		SELECT t.item_prize_ranks, t.prize_owner_id, t.prize_item_ids
+		FROM twist_business.coinflip_tournament_runs r
+		JOIN twist_business.coinflip_tournaments t ON t.tournament_id = r.tournament_id
+		WHERE r.id = $1
+		  AND r.status = 'running'
+		FOR UPDATE OF r
+	`
+	var itemRanks int
+	var ownerID *int64
+	var pool []int64
+	if err := tx.QueryRow(ctx, lockQ, runID).Scan(&itemRanks, &ownerID, &pool); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	const itemQ = `
+		UPDATE twist_business.items
+		SET user_id = $3, locked = false
+		WHERE item_id = (
+			SELECT item_id
+			FROM twist_business.items
+			WHERE item_id = ANY($1)
+			  AND user_id = $2
+			  AND locked = true
+			ORDER BY array_position($1, item_id::bigint)
+			LIMIT 1
+			FOR UPDATE
+		)
+		RETURNING item_id
+	`
+	const resultQ = `
+		INSERT INTO twist_business.coinflip_tournament_results (
+			run_id, rank, user_id, points, volume_ton, longest_series, best_multiplier, prize_ton, prize_item_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	const walletQ = `
+		INSERT INTO twist_business.user_wallets (user_id, balance_ton)
+		VALUES ($1, 0)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+	const creditQ = `
+		WITH ins AS (
+			INSERT INTO twist_business.wallet_transactions (user_id, tournament_run_id, kind, amount_ton)
+			VALUES ($1, $2, 'tournament_prize', $3)
+			ON CONFLICT DO NOTHING
+			RETURNING user_id, amount_ton
+		)
+		UPDATE twist_business.user_wallets uw
+		SET
+			balance_ton = uw.balance_ton + ins.amount_ton,
+			updated_at = now()
+		FROM ins
+		WHERE uw.user_id = ins.user_id
+	`
+
+	for i := range results {
+		res := &results[i]
+		res.PrizeItemID = nil
+		if res.Rank <= itemRanks && ownerID != nil && len(pool) > 0 {
+			var itemID int64
+			err := tx.QueryRow(ctx, itemQ, pool, *ownerID, res.UserID).Scan(&itemID)
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+			case err != nil:
+				return nil, err
+			default:
+				res.PrizeItemID = &itemID
+			}
+		}
+
+		if _, err := tx.Exec(ctx, resultQ,
+			runID,
+			res.Rank,
+			res.UserID,
+			res.Points,
+			res.VolumeTon,
+			res.LongestSeries,
+			res.BestMultiplier,
+			res.PrizeTon,
+			res.PrizeItemID,
+		); err != nil {
+			return nil, err
+		}
+
+		if res.PrizeTon > 0 {
+			if _, err := tx.Exec(ctx, walletQ, res.UserID); err != nil {
+				return nil, err
+			}
+			if _, err := tx.Exec(ctx, creditQ, res.UserID, runID, res.PrizeTon); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	const doneQ = `
+		UPDATE twist_business.coinflip_tournament_runs
+		SET status = 'settled', settled_at = now()
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(ctx, doneQ, runID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *TournamentsRepo) Results(ctx context.Context, runID int64, limit int) (_ []TournamentResult, err error) {
+	defer observe(ctx, "tournaments", "Results", time.Now(), &err)
+	if runID <= 0 {
+		return nil, fmt.Errorf("invalid run_id")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	const q = `
+		SELECT
+			rank,
+			user_id,
+			points::float8,
+			volume_ton::float8,
+			longest_series,
+			best_multiplier::float8,
+			prize_ton::float8,
+			prize_item_id
+		FROM twist_business.coinflip_tournament_results
+		WHERE run_id = $1
+		ORDER BY rank
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, q, runID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]TournamentResult, 0)
+	for rows.Next() {
+		var res TournamentResult
+		if err := rows.Scan(
+			&res.Rank,
+			&res.UserID,
+			&res.Points,
+			&res.VolumeTon,
+			&res.LongestSeries,
+			&res.BestMultiplier,
+			&res.PrizeTon,
+			&res.PrizeItemID,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, res)
+	}
+	return out, rows.Err()
+}
@@ -2,8 +2,12 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
+	"unicode/utf8"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -15,7 +19,8 @@ func NewUsersRepo(db *pgxpool.Pool) *UsersRepo {
 	return &UsersRepo{db: db}
 }
 
-func (r *UsersRepo) EnsureUser(ctx context.Context, userID int64) error {
+func (r *UsersRepo) EnsureUser(ctx context.Context, userID int64) (err error) {
+	defer observe(ctx, "users", "EnsureUser", time.Now(), &err)
 	if userID <= 0 {
 		return fmt.Errorf("invalid user_id")
 	}
@@ -25,6 +30,176 @@ func (r *UsersRepo) EnsureUser(ctx context.Context, userID int64) error {
 		VALUES ($1, NULL, now())
 		ON CONFLICT (user_id) DO NOTHING
 	`
-	_, err := r.db.Exec(ctx, q, userID)
+	_, err = r.db.Exec(ctx, q, userID)
 	return err
 }
+
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func (r *UsersRepo) UpsertTelegramUser(ctx context.Context, userID int64, username, firstName, photoURL string) (err error) {
+	defer observe(ctx, "users", "UpsertTelegramUser", time.Now(), &err)
+	if userID <= 0 {
+		return fmt.Errorf("invalid user_id")
+	}
+	username = truncateUTF8(username, 32)
+
+	const q = `
+		INSERT INTO twist_business.users (
+			user_id,
+			username,
+			first_name,
+			photo_url,
+			registration_date,
+			last_active_at
+		)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), now(), now())
+		ON CONFLICT (user_id) DO UPDATE SET
+			username       = COALESCE(EXCLUDED.username, twist_business.users.username),
+			first_name     = COALESCE(EXCLUDED.first_name, twist_business.users.first_name),
+			photo_url      = COALESCE(EXCLUDED.photo_url, twist_business.users.photo_url),
+			last_active_at = now()
+	`
+	_, err = r.db.Exec(ctx, q, userID, username, firstName, photoURL)
+	return err
+}
+
+const countedBetStatuses = `(
+	'single_win',
+	'single_lose',
+	'pool_win',
+	'pool_lose',
+	'multi_win',
+	'multi_lose',
+	'series_lost',
+	'series_cashed_out'
+)`
+
+const wonBetStatuses = `('single_win', 'pool_win', 'multi_win', 'series_cashed_out')`
+
+func (r *UsersRepo) ApplyBetStats(ctx context.Context) (_ int64, err error) {
+	defer observe(ctx, "users", "ApplyBetStats", time.Now(), &err)
+	q := `
+		WITH counted AS (
+			UPDATE twist_business.game_bets
+			SET stats_counted_at = now()
+			WHERE stats_counted_at IS NULL
+			  AND status IN ` + countedBetStatuses + `
+			RETURNING
+				user_id,
+				game_id,
+				mode,
+				series_session_id,
+				status,
+				stake_ton,
+				payout_ton,
+				COALESCE(settled_at, created_at) AS active_at
+		),
+		games AS (
+			SELECT
+				user_id,
+				BOOL_OR(status IN ` + wonBetStatuses + `) AS won,
+				SUM(stake_ton) AS bets_sum,
+				COALESCE(SUM(payout_ton) FILTER (WHERE status IN ` + wonBetStatuses + `), 0) AS win_sum,
+				MAX(active_at) AS active_at
+			FROM counted
+			GROUP BY
+				user_id,
+				series_session_id,
+				CASE WHEN series_session_id IS NULL THEN game_id END,
+				CASE WHEN series_session_id IS NULL THEN mode END
+		),
+		agg AS (
+			SELECT
+				user_id,
+				COUNT(*) AS games,
+				COUNT(*) FILTER (WHERE won) AS wins,
+				SUM(bets_sum) AS bets_sum,
+				SUM(win_sum) AS win_sum,
+				MAX(active_at) AS active_at
+			FROM games
+			GROUP BY user_id
+		)
+		UPDATE twist_business.users u
+		SET
+			games_count = u.games_count + agg.games,
+			win_count = u.win_count + agg.wins,
+			bets_sum = u.bets_sum + agg.bets_sum,
+			win_sum = u.win_sum + agg.win_sum,
+			last_active_at = GREATEST(u.last_active_at, agg.active_at)
+		FROM agg
+		WHERE u.user_id = agg.user_id
+	`
+	tag, err := r.db.Exec(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+type ProfileStats struct {
+	UserID         int64
+	GamesCount     int
+	WinCount       int
+	BetsSum        float64
+	WinSum         float64
+	LastActiveAt   time.Time
+	LongestSeries  int
+	BestMultiplier float64
+}
+
+func (r *UsersRepo) ProfileStats(ctx context.Context, userID int64) (_ *ProfileStats, err error) {
+	defer observe(ctx, "users", "ProfileStats", time.Now(), &err)
+	if userID <= 0 {
+		return nil, fmt.Errorf("invalid user_id")
+	}
+
+	q := `
+		SELECT
+			u.user_id,
+			u.games_count,
+			u.win_count,
+			u.bets_sum::float8,
+			u.win_sum::float8,
+			u.last_active_at,
+			COALESCE((
+				SELECT MAX(wins)
+				FROM twist_business.series_sessions
+				WHERE user_id = u.user_id
+			), 0),
+			COALESCE((
+				SELECT MAX(payout_ton / stake_ton)
+				FROM twist_business.game_bets
+				WHERE user_id = u.user_id
+				  AND status IN ` + wonBetStatuses + `
+			), 0)::float8
+		FROM twist_business.users u
+		WHERE u.user_id = $1
+	`
+
+	var ps ProfileStats
+	err = r.db.QueryRow(ctx, q, userID).Scan(
+		&ps.UserID,
+		&ps.GamesCount,
+		&ps.WinCount,
+		&ps.BetsSum,
+		&ps.WinSum,
+		&ps.LastActiveAt,
+		&ps.LongestSeries,
+		&ps.BestMultiplier,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ps, nil
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"short", "player", "player"},
+		{"exact", strings.Repeat("a", 32), strings.Repeat("a", 32)},
+		{"ascii", strings.Repeat("a", 40), strings.Repeat("a", 32)},
+		{"split two byte rune", strings.Repeat("a", 31) + "é", strings.Repeat("a", 31)},
+		{"split four byte rune", strings.Repeat("a", 30) + "😀", strings.Repeat("a", 30)},
+		{"cyrillic", strings.Repeat("ж", 20), strings.Repeat("ж", 16)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateUTF8(tt.in, 32)
+			if got != tt.want || !utf8.ValidString(got) || len(got) > 32 {
+				t.Fatalf("truncateUTF8 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
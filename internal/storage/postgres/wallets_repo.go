@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -15,7 +16,8 @@ func NewWalletsRepo(db *pgxpool.Pool) *WalletsRepo {
 	return &WalletsRepo{db: db}
 }
 
-func (r *WalletsRepo) EnsureWallet(ctx context.Context, userID int64) error {
+func (r *WalletsRepo) EnsureWallet(ctx context.Context, userID int64) (err error) {
+	defer observe(ctx, "wallets", "EnsureWallet", time.Now(), &err)
 	if userID <= 0 {
 		return fmt.Errorf("invalid user_id")
 	}
@@ -25,6 +27,6 @@ func (r *WalletsRepo) EnsureWallet(ctx context.Context, userID int64) error {
 		VALUES ($1, 0)
 		ON CONFLICT (user_id) DO NOTHING
 	`
-	_, err := r.db.Exec(ctx, q, userID)
+	_, err = r.db.Exec(ctx, q, userID)
 	return err
 }
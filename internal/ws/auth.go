@@ -0,0 +1,177 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Session struct {
+	UserID    int64
+	Token     string
+	SessionID string
+	Evicted   []string
+
+	userLockID string
+}
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Session, error)
+	Touch(ctx context.Context, s *Session) error
+	Release(ctx context.Context, s *Session) error
+	Issue(ctx context.Context, userID int64, ttl time.Duration) (string, error)
+	Verify(ctx context.Context, token string) (int64, error)
+}
+
+type RedisAuthenticator struct {
+	store *TokenStore
+}
+
+func NewRedisAuthenticator(store *TokenStore) *RedisAuthenticator {
+	return &RedisAuthenticator{store: store}
+}
+
+func (a *RedisAuthenticator) Authenticate(ctx context.Context, token string) (*Session, error) {
+	uid, sid, evicted, ok, err := a.store.LockWithSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || uid == 0 {
+		return nil, ErrTokenNotFound
+	}
+	return &Session{UserID: uid, Token: stringsTrim(token), SessionID: sid, Evicted: evicted}, nil
+}
+
+func (a *RedisAuthenticator) Touch(ctx context.Context, sess *Session) error {
+	if sess == nil {
+		return nil
+	}
+	return a.store.Touch(ctx, sess.Token, sess.SessionID)
+}
+
+func (a *RedisAuthenticator) Release(ctx context.Context, sess *Session) error {
+	if sess == nil {
+		return nil
+	}
+	return a.store.UnlockWithSession(ctx, sess.Token, sess.SessionID)
+}
+
+func (a *RedisAuthenticator) Issue(ctx context.Context, userID int64, ttl time.Duration) (string, error) {
+	return a.store.Issue(ctx, userID, ttl)
+}
+
+func (a *RedisAuthenticator) Verify(ctx context.Context, token string) (int64, error) {
+	return a.store.UserID(ctx, token)
+}
+
+type SingleSession struct {
+	inner Authenticator
+	rdb   *redis.Client
+	ttl   time.Duration
+
+	touchScript   *redis.Script
+	releaseScript *redis.Script
+
+	opTimeout time.Duration
+}
+
+func NewSingleSession(inner Authenticator, rdb *redis.Client, ttl time.Duration) *SingleSession {
+	touchLua := `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+  return 0
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`
+
+	releaseLua := `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+  return 0
+end
+redis.call("DEL", KEYS[1])
+return 1
+`
+
+	if ttl <= 0 {
+		ttl = 2 * time.Minute
+	}
+
+	return &SingleSession{
+		inner:         inner,
+		rdb:           rdb,
+		ttl:           ttl,
+		touchScript:   redis.NewScript(touchLua),
+		releaseScript: redis.NewScript(releaseLua),
+		opTimeout:     2 * time.Second,
+	}
+}
+
+func redisUserSessionKey(userID int64) string { return fmt.Sprintf("auth_session:%d", userID) }
+
+func (a *SingleSession) Authenticate(ctx context.Context, token string) (*Session, error) {
+	sess, err := a.inner.Authenticate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	sid, err := newSessionID()
+	if err != nil {
+		_ = a.inner.Release(ctx, sess)
+		return nil, err
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, a.opTimeout)
+	defer cancel()
+
+	ok, err := a.rdb.SetNX(opCtx, redisUserSessionKey(sess.UserID), sid, a.ttl).Result()
+	if err != nil || !ok {
+		_ = a.inner.Release(ctx, sess)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenLocked
+	}
+
+	sess.userLockID = sid
+	return sess, nil
+}
+
+func (a *SingleSession) Touch(ctx context.Context, sess *Session) error {
+	if sess == nil {
+		return nil
+	}
+	if err := a.inner.Touch(ctx, sess); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.opTimeout)
+	defer cancel()
+
+	_, err := runScript(ctx, "user_session_touch", a.touchScript, a.rdb, []string{redisUserSessionKey(sess.UserID)}, sess.userLockID, a.ttl.Milliseconds())
+	return err
+}
+
+func (a *SingleSession) Release(ctx context.Context, sess *Session) error {
+	if sess == nil {
+		return nil
+	}
+	if err := a.inner.Release(ctx, sess); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.opTimeout)
+	defer cancel()
+
+	_, err := runScript(ctx, "user_session_release", a.releaseScript, a.rdb, []string{redisUserSessionKey(sess.UserID)}, sess.userLockID)
+	return err
+}
+
+func (a *SingleSession) Issue(ctx context.Context, userID int64, ttl time.Duration) (string, error) {
+	return a.inner.Issue(ctx, userID, ttl)
+}
+
+func (a *SingleSession) Verify(ctx context.Context, token string) (int64, error) {
+	return a.inner.Verify(ctx, token)
+}
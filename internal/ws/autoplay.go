@@ -0,0 +1,353 @@
+package ws
+
+import (
+	"CoinFlip/internal/game"
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/storage/postgres"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+func (h *Handler) autoplayState(userID int64, skipped string) AutoplayState {
+	st := AutoplayState{Event: EventAutoplayState, Rules: h.Autoplay.Rules(), Skipped: skipped}
+	if s, ok := h.Autoplay.Get(userID); ok {
+		st.Active = true
+		st.Session = &s
+	}
+	return st
+}
+
+func (h *Handler) sendAutoplayEnded(s game.AutoplaySession) {
+	h.Hub.SendToUser(s.UserID, AutoplayState{
+		Event:   EventAutoplayState,
+		Rules:   h.Autoplay.Rules(),
+		Session: &s,
+	})
+}
+
+func (h *Handler) AutoplayPending(tableID int) bool {
+	return h.Autoplay != nil && h.Autoplay.Pending(tableID)
+}
+
+func (h *Handler) autoplayStart(ctx context.Context, conn *websocket.Conn, raw []byte) {
+	mlg := logging.FromContext(ctx)
+
+	var msg AutoplayStartMsg
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		h.fail(ctx, conn, "bad autoplay_start json")
+		return
+	}
+
+	userID := h.Hub.UserID(conn)
+	if userID == 0 {
+		h.fail(ctx, conn, "not authorized")
+		return
+	}
+	if _, ok := h.Autoplay.Get(userID); ok {
+		h.fail(ctx, conn, game.ReasonAutoplayActive)
+		return
+	}
+
+	eng := h.engineFor(conn)
+	mode := msg.Mode
+	if mode == "" && eng.BetMode() == game.ModeSeries {
+		mode = game.ModeSingle
+	}
+	mode, side, ok, reason := eng.NormalizeBet(mode, msg.Side)
+	if !ok {
+		h.fail(ctx, conn, reason)
+		return
+	}
+
+	plan := game.AutoplayPlan{
+		TableID:       eng.TableID(),
+		Side:          side,
+		Mode:          mode,
+		Source:        msg.Source,
+		StakeTon:      msg.StakeTon,
+		Rounds:        msg.Rounds,
+		StopLossTon:   msg.StopLossTon,
+		TakeProfitTon: msg.TakeProfitTon,
+	}
+	for _, bi := range msg.BetItems {
+		id, err := strconv.Atoi(strings.TrimSpace(bi.ItemID))
+		if err != nil || id <= 0 {
+			h.fail(ctx, conn, "bad item_id: "+bi.ItemID)
+			return
+		}
+		plan.ItemIDs = append(plan.ItemIDs, id)
+	}
+	if reason := plan.Validate(h.Autoplay.Rules()); reason != "" {
+		h.fail(ctx, conn, reason)
+		return
+	}
+
+	if plan.Source == game.AutoplayItems {
+		avail, err := h.ItemsRepo.AvailableItems(ctx, plan.ItemIDs, userID)
+		if err != nil {
+			mlg.Error("ws: autoplay items failed", logging.KeyErr, err)
+			h.fail(ctx, conn, "db error: autoplay items")
+			return
+		}
+		if len(avail) == 0 {
+			h.fail(ctx, conn, "item not found / not owned / already locked")
+			return
+		}
+	}
+
+	id, err := h.AutoplayRepo.Create(ctx, postgres.CreateAutoplayParams{
+		UserID:        userID,
+		TableID:       plan.TableID,
+		Side:          plan.Side,
+		Mode:          plan.Mode,
+		Source:        plan.Source,
+		StakeTon:      plan.StakeTon,
+		ItemIDs:       plan.ItemIDs,
+		Rounds:        plan.Rounds,
+		StopLossTon:   plan.StopLossTon,
+		TakeProfitTon: plan.TakeProfitTon,
+	})
+	if errors.Is(err, postgres.ErrAutoplayActive) {
+		h.fail(ctx, conn, game.ReasonAutoplayActive)
+		return
+	}
+	if err != nil {
+		mlg.Error("ws: create autoplay failed", logging.KeyErr, err)
+		h.fail(ctx, conn, "db error: create autoplay")
+		return
+	}
+
+	h.Autoplay.Add(game.AutoplaySession{
+		ID:     id,
+		UserID: userID,
+		Plan:   plan,
+		Status: game.AutoplayActive,
+	})
+	mlg.Info("ws: autoplay started", "autoplay_id", id, "side", plan.Side, "mode", plan.Mode, "source", plan.Source, "rounds", plan.Rounds)
+
+	_ = h.Hub.SendJSON(conn, h.autoplayState(userID, ""))
+
+	h.RunAutoplay(ctx, eng)
+}
+
+func (h *Handler) autoplayStop(ctx context.Context, conn *websocket.Conn) {
+	userID := h.Hub.UserID(conn)
+	if userID == 0 {
+		h.fail(ctx, conn, "not authorized")
+		return
+	}
+
+	s, ok := h.Autoplay.Remove(userID)
+	if !ok {
+		h.fail(ctx, conn, "no active autoplay")
+		return
+	}
+	if err := h.AutoplayRepo.Stop(ctx, s.ID, game.AutoplayStopped, game.AutoplayUserStop); err != nil {
+		h.Autoplay.Add(s)
+		h.rollback(ctx, "autoplay_stop", nil)
+		logging.FromContext(ctx).Error("ws: stop autoplay failed", "autoplay_id", s.ID, logging.KeyErr, err)
+		h.fail(ctx, conn, "db error: stop autoplay")
+		return
+	}
+
+	s.Status = game.AutoplayStopped
+	s.StopReason = game.AutoplayUserStop
+	logging.FromContext(ctx).Info("ws: autoplay stopped", "autoplay_id", s.ID, "rounds_played", s.Progress.RoundsPlayed)
+	h.sendAutoplayEnded(s)
+}
+
+func (h *Handler) endAutoplay(ctx context.Context, s game.AutoplaySession, reason string) {
+	if _, ok := h.Autoplay.Remove(s.UserID); !ok {
+		return
+	}
+	s.Status = game.AutoplayStatusFor(reason)
+	s.StopReason = reason
+
+	lg := logging.FromContext(ctx).With(logging.KeyUserID, s.UserID, "autoplay_id", s.ID)
+	if err := h.AutoplayRepo.Stop(ctx, s.ID, s.Status, reason); err != nil {
+		lg.Error("ws: end autoplay failed", logging.KeyErr, err)
+	}
+	lg.Info("ws: autoplay ended", "reason", reason, "rounds_played", s.Progress.RoundsPlayed, "net_ton", s.Progress.NetTon)
+	h.sendAutoplayEnded(s)
+}
+
+func (h *Handler) RunAutoplay(ctx context.Context, eng *game.Engine) {
+	if h.Autoplay == nil || h.AutoplayRepo == nil || h.BetsRepo == nil || h.ItemsRepo == nil {
+		return
+	}
+
+	snap := eng.Snapshot()
+	if snap.Phase != game.PhaseBetting {
+		return
+	}
+	for _, s := range h.Autoplay.Due(eng.TableID(), snap.GameID) {
+		h.placeAutoplay(ctx, eng, s)
+	}
+}
+
+func (h *Handler) placeAutoplay(ctx context.Context, eng *game.Engine, s game.AutoplaySession) {
+	lg := logging.FromContext(ctx).With(logging.KeyUserID, s.UserID, "autoplay_id", s.ID)
+	userID := s.UserID
+
+	var items []game.ItemRef
+	var dbItems []postgres.Item
+	var lockedIDs []int
+
+	if s.Plan.Source == game.AutoplayWallet {
+		items = []game.ItemRef{{Type: game.AutoplayWallet, Name: "TON", CostTon: s.Plan.StakeTon}}
+	} else {
+		avail, err := h.ItemsRepo.AvailableItems(ctx, s.Plan.ItemIDs, userID)
+		if err != nil {
+			lg.Error("ws: autoplay items failed", logging.KeyErr, err)
+			h.Hub.SendToUser(userID, h.autoplayState(userID, "db error: autoplay items"))
+			return
+		}
+		if len(avail) == 0 {
+			h.endAutoplay(ctx, s, game.AutoplayFundsExhausted)
+			return
+		}
+
+		refs := make([]game.ItemRef, 0, len(avail))
+		for _, it := range avail {
+			refs = append(refs, game.ItemRef{
+				Type:     it.Type,
+				ItemID:   strconv.Itoa(it.ItemID),
+				Name:     it.Name,
+				PhotoURL: it.PhotoURL,
+				CostTon:  it.CostTon,
+			})
+		}
+		items = s.Plan.PickItems(refs, eng.Limits().MaxBetItems)
+
+		ids := duelItemIDs(items)
+		locked, err := h.ItemsRepo.LockItems(ctx, ids, userID)
+		if err != nil {
+			h.Hub.SendToUser(userID, h.autoplayState(userID, "item not found / not owned / already locked"))
+			return
+		}
+		dbItems = locked
+		lockedIDs = ids
+	}
+
+	snap, accepted, ok, reason := eng.AddBet(userID, s.Plan.Side, s.Plan.Mode, items)
+	if !ok {
+		h.rollback(ctx, "unlock_items", h.ItemsRepo.UnlockItems(ctx, lockedIDs))
+		if game.AutoplayFatal(reason) {
+			lg.Info("ws: autoplay bet rejected", "reason", reason)
+			h.endAutoplay(ctx, s, game.AutoplayRejected)
+			return
+		}
+		h.Hub.SendToUser(userID, h.autoplayState(userID, reason))
+		return
+	}
+
+	sid := s.ID
+	var err error
+	if s.Plan.Source == game.AutoplayWallet {
+		err = h.BetsRepo.InsertWalletBet(ctx, postgres.CreateBetRow{
+			GameID:            snap.GameID,
+			UserID:            userID,
+			Side:              s.Plan.Side,
+			Mode:              s.Plan.Mode,
+			AutoplaySessionID: &sid,
+			ItemName:          "TON",
+			StakeTon:          s.Plan.StakeTon,
+		})
+	} else {
+		rows := make([]postgres.CreateBetRow, 0, len(dbItems))
+		for _, it := range dbItems {
+			rows = append(rows, postgres.CreateBetRow{
+				GameID:            snap.GameID,
+				UserID:            userID,
+				Side:              s.Plan.Side,
+				Mode:              s.Plan.Mode,
+				AutoplaySessionID: &sid,
+				ItemID:            it.ItemID,
+				ItemType:          it.Type,
+				ItemName:          it.Name,
+				ItemPhotoURL:      it.PhotoURL,
+				StakeTon:          it.CostTon,
+			})
+		}
+		err = h.BetsRepo.InsertAcceptedBets(ctx, rows)
+	}
+	if err != nil {
+		eng.RollbackAcceptedBet(snap.GameID, userID, s.Plan.Mode, len(items))
+		h.rollback(ctx, "engine_bet", nil)
+		h.rollback(ctx, "unlock_items", h.ItemsRepo.UnlockItems(ctx, lockedIDs))
+		if errors.Is(err, postgres.ErrInsufficientFunds) {
+			h.endAutoplay(ctx, s, game.AutoplayFundsExhausted)
+			return
+		}
+		lg.Error("ws: save autoplay bet failed", logging.KeyErr, err)
+		h.Hub.SendToUser(userID, h.autoplayState(userID, "db error: save bets"))
+		return
+	}
+
+	h.addLocked(snap.GameID, lockedIDs)
+	stake := 0.0
+	for _, it := range items {
+		stake += it.CostTon
+	}
+	lg.Info("ws: autoplay bet accepted", "side", s.Plan.Side, "mode", s.Plan.Mode, "items", accepted, "stake", stake)
+
+	h.Hub.SendToUser(userID, BetsAccepted{
+		Event:    EventBetsAccepted,
+		TableID:  eng.TableID(),
+		GameID:   snap.GameID,
+		Hash:     snap.Hash,
+		Accepted: accepted,
+	})
+	h.Hub.SendToUser(userID, h.autoplayState(userID, ""))
+
+	h.Hub.BroadcastTable(eng.TableID(), NewBets{
+		Event:   EventNewBets,
+		TableID: eng.TableID(),
+		GameID:  snap.GameID,
+		Hash:    snap.Hash,
+		UserID:  userID,
+		Side:    s.Plan.Side,
+		Mode:    s.Plan.Mode,
+		Pool:    poolOdds(eng),
+		Bets:    eng.BetsSnapshotForGame(snap.GameID),
+	})
+}
+
+func (h *Handler) SettleAutoplay(ctx context.Context, gameID int) {
+	if h.Autoplay == nil || h.AutoplayRepo == nil {
+		return
+	}
+
+	lg := logging.FromContext(ctx)
+	rounds, err := h.AutoplayRepo.RecordRound(ctx, gameID)
+	if err != nil {
+		lg.Error("autoplay: record round failed", logging.KeyErr, err)
+		return
+	}
+
+	for _, r := range rounds {
+		s, ok := h.Autoplay.Record(r.SessionID, game.AutoplayProgress{
+			RoundsPlayed: r.RoundsPlayed,
+			WageredTon:   r.WageredTon,
+			NetTon:       r.NetTon,
+			LastGameID:   gameID,
+		})
+		if !ok {
+			continue
+		}
+		if s.Status != game.AutoplayActive {
+			if err := h.AutoplayRepo.Stop(ctx, s.ID, s.Status, s.StopReason); err != nil {
+				lg.Error("autoplay: stop failed", "autoplay_id", s.ID, logging.KeyErr, err)
+			}
+			lg.Info("autoplay: ended", logging.KeyUserID, s.UserID, "autoplay_id", s.ID, "reason", s.StopReason, "net_ton", s.Progress.NetTon)
+			h.sendAutoplayEnded(s)
+			continue
+		}
+		h.Hub.SendToUser(s.UserID, h.autoplayState(s.UserID, ""))
+	}
+}
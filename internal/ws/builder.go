@@ -2,11 +2,23 @@ package ws
 
 import "CoinFlip/internal/game"
 
+func coinFaces(s game.Snapshot) ([]string, *int) {
+	faces := s.FaceNames()
+	if faces == nil {
+		return nil, nil
+	}
+	heads := game.HeadsCount(s.Faces)
+	return faces, &heads
+}
+
 func EventForPhase(s game.Snapshot) any {
+	faces, heads := coinFaces(s)
+
 	switch s.Phase {
 	case game.PhaseBetting:
 		return GameStarted{
 			Event:       EventGameStarted,
+			TableID:     s.TableID,
 			GameID:      s.GameID,
 			Hash:        s.Hash,
 			BettingTime: s.Timer,
@@ -15,26 +27,33 @@ func EventForPhase(s game.Snapshot) any {
 	case game.PhaseGettingResult:
 		return GettingResult{
 			Event:          EventGettingResult,
+			TableID:        s.TableID,
 			GameID:         s.GameID,
 			Hash:           s.Hash,
 			TimeTillResult: s.Timer,
 			ResultSide:     string(s.ResultSide),
+			Faces:          faces,
+			Heads:          heads,
 		}
 
 	case game.PhaseFinished:
 		return GameFinished{
 			Event:      EventGameFinished,
+			TableID:    s.TableID,
 			GameID:     s.GameID,
 			Hash:       s.Hash,
 			ResultSide: string(s.ResultSide),
+			Faces:      faces,
+			Heads:      heads,
 			Seed:       s.Seed,
 		}
 
 	case game.PhaseWaiting:
 		return NewGame{
-			Event:  EventNewGame,
-			GameID: s.GameID,
-			Hash:   s.Hash,
+			Event:   EventNewGame,
+			TableID: s.TableID,
+			GameID:  s.GameID,
+			Hash:    s.Hash,
 		}
 
 	default:
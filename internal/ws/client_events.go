@@ -7,4 +7,13 @@ const (
 	ClientEventBet            ClientEvent = "bet"
 	ClientEventCashout        ClientEvent = "cashout"
 	ClientEventSeriesContinue ClientEvent = "series_continue"
+	ClientEventCancelBet      ClientEvent = "cancel_bet"
+	ClientEventSubscribe      ClientEvent = "subscribe"
+	ClientEventDuelLobby      ClientEvent = "duel_lobby"
+	ClientEventDuelOpen       ClientEvent = "duel_open"
+	ClientEventDuelMatch      ClientEvent = "duel_match"
+	ClientEventAutoplayStart  ClientEvent = "autoplay_start"
+	ClientEventAutoplayStop   ClientEvent = "autoplay_stop"
+	ClientEventStandings      ClientEvent = "tournament_standings"
+	ClientEventProfileStats   ClientEvent = "profile_stats"
 )
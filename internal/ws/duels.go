@@ -0,0 +1,240 @@
+package ws
+
+import (
+	"CoinFlip/internal/game"
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/storage/postgres"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func (h *Handler) duelLobby() DuelLobby {
+	return DuelLobby{
+		Event: EventDuelLobby,
+		Rules: h.Duels.Rules(),
+		Duels: h.Duels.Lobby(),
+	}
+}
+
+func (h *Handler) lockDuelItems(ctx context.Context, conn *websocket.Conn, userID int64, side string, betItems []BetItem) ([]game.ItemRef, []postgres.DuelItemRow, bool) {
+	itemIDs := make([]int, 0, len(betItems))
+	for _, bi := range betItems {
+		id, err := strconv.Atoi(strings.TrimSpace(bi.ItemID))
+		if err != nil || id <= 0 {
+			h.fail(ctx, conn, "bad item_id: "+bi.ItemID)
+			return nil, nil, false
+		}
+		itemIDs = append(itemIDs, id)
+	}
+	if len(itemIDs) == 0 {
+		h.fail(ctx, conn, "empty bet_items")
+		return nil, nil, false
+	}
+
+	dbItems, err := h.ItemsRepo.LockItems(ctx, itemIDs, userID)
+	if err != nil {
+		h.fail(ctx, conn, "item not found / not owned / already locked")
+		return nil, nil, false
+	}
+
+	items := make([]game.ItemRef, 0, len(dbItems))
+	rows := make([]postgres.DuelItemRow, 0, len(dbItems))
+	for _, it := range dbItems {
+		items = append(items, game.ItemRef{
+			Type:     it.Type,
+			ItemID:   strconv.Itoa(it.ItemID),
+			Name:     it.Name,
+			PhotoURL: it.PhotoURL,
+			CostTon:  it.CostTon,
+		})
+		rows = append(rows, postgres.DuelItemRow{
+			UserID:       userID,
+			Side:         side,
+			ItemID:       it.ItemID,
+			ItemType:     it.Type,
+			ItemName:     it.Name,
+			ItemPhotoURL: it.PhotoURL,
+			StakeTon:     it.CostTon,
+		})
+	}
+	return items, rows, true
+}
+
+func duelItemIDs(items []game.ItemRef) []int {
+	out := make([]int, 0, len(items))
+	for _, it := range items {
+		if id, err := strconv.Atoi(it.ItemID); err == nil {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func (h *Handler) duelOpen(ctx context.Context, conn *websocket.Conn, raw []byte) {
+	mlg := logging.FromContext(ctx)
+
+	var msg DuelOpenMsg
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		h.fail(ctx, conn, "bad duel_open json")
+		return
+	}
+	if msg.Side != "heads" && msg.Side != "tails" {
+		h.fail(ctx, conn, "bad side")
+		return
+	}
+
+	userID := h.Hub.UserID(conn)
+	if userID == 0 {
+		h.fail(ctx, conn, "not authorized")
+		return
+	}
+
+	items, rows, ok := h.lockDuelItems(ctx, conn, userID, msg.Side, msg.BetItems)
+	if !ok {
+		return
+	}
+	lockedIDs := duelItemIDs(items)
+
+	sp := engineSpan(ctx, "DuelOpen")
+	d, ok, reason := h.Duels.Open(userID, msg.Side, items, time.Now())
+	sp.End()
+	if !ok {
+		h.rollback(ctx, "unlock_items", h.ItemsRepo.UnlockItems(ctx, lockedIDs))
+		h.fail(ctx, conn, reason)
+		return
+	}
+
+	if err := h.DuelsRepo.Create(ctx, postgres.CreateDuelParams{
+		DuelID:       d.ID,
+		Hash:         d.Hash,
+		Seed:         d.Seed,
+		CreatorID:    userID,
+		CreatorSide:  d.CreatorSide,
+		CreatorStake: d.CreatorStake,
+		ExpiresAt:    d.ExpiresAt,
+		Items:        rows,
+	}); err != nil {
+		h.Duels.Remove(d.ID)
+		h.rollback(ctx, "duel_open", nil)
+		h.rollback(ctx, "unlock_items", h.ItemsRepo.UnlockItems(ctx, lockedIDs))
+		mlg.Error("ws: create duel failed", logging.KeyErr, err)
+		h.fail(ctx, conn, "db error: create duel")
+		return
+	}
+
+	d, _ = h.Duels.Publish(d.ID)
+	mlg.Info("ws: duel opened", "duel_id", d.ID, "side", d.CreatorSide, "items", len(items), "stake", d.CreatorStake)
+
+	h.Hub.BroadcastJSON(DuelUpdate{Event: EventDuelOpened, Duel: d})
+}
+
+func (h *Handler) duelMatch(ctx context.Context, conn *websocket.Conn, raw []byte) {
+	mlg := logging.FromContext(ctx)
+
+	var msg DuelMatchMsg
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		h.fail(ctx, conn, "bad duel_match json")
+		return
+	}
+
+	userID := h.Hub.UserID(conn)
+	if userID == 0 {
+		h.fail(ctx, conn, "not authorized")
+		return
+	}
+
+	open, ok := h.Duels.Get(msg.DuelID)
+	if !ok {
+		h.fail(ctx, conn, game.ReasonDuelNotFound)
+		return
+	}
+	side := string(game.SideHeads)
+	if open.CreatorSide == side {
+		side = string(game.SideTails)
+	}
+
+	items, rows, ok := h.lockDuelItems(ctx, conn, userID, side, msg.BetItems)
+	if !ok {
+		return
+	}
+	lockedIDs := duelItemIDs(items)
+
+	sp := engineSpan(ctx, "DuelMatch")
+	d, ok, reason := h.Duels.Match(msg.DuelID, userID, items, time.Now())
+	sp.End()
+	if !ok {
+		h.rollback(ctx, "unlock_items", h.ItemsRepo.UnlockItems(ctx, lockedIDs))
+		h.fail(ctx, conn, reason)
+		return
+	}
+
+	feeItemID := 0
+	if d.FeeItem != nil {
+		feeItemID, _ = strconv.Atoi(d.FeeItem.ItemID)
+	}
+
+	fromWallet, err := h.DuelsRepo.Resolve(ctx, postgres.ResolveDuelParams{
+		DuelID:        d.ID,
+		OpponentID:    userID,
+		OpponentStake: d.OpponentStake,
+		ResultSide:    d.ResultSide,
+		WinnerID:      d.WinnerID,
+		FeeMode:       d.FeeMode,
+		FeeTon:        d.FeeTon,
+		FeeItemID:     feeItemID,
+		Items:         rows,
+	})
+	if err != nil {
+		if !errors.Is(err, postgres.ErrNotFound) {
+			h.Duels.Restore(d)
+			h.rollback(ctx, "duel_match", nil)
+		}
+		h.rollback(ctx, "unlock_items", h.ItemsRepo.UnlockItems(ctx, lockedIDs))
+		mlg.Error("ws: resolve duel failed", "duel_id", d.ID, logging.KeyErr, err)
+		h.fail(ctx, conn, "db error: resolve duel")
+		return
+	}
+	d = d.SettleFee(fromWallet)
+	game.ObserveDuel(d)
+
+	mlg.Info("ws: duel resolved",
+		"duel_id", d.ID,
+		"result_side", d.ResultSide,
+		"winner_id", d.WinnerID,
+		"pot", d.Pot(),
+		"fee_mode", d.FeeMode,
+		"fee_ton", d.FeeTon,
+	)
+
+	h.Hub.BroadcastJSON(DuelUpdate{Event: EventDuelResolved, Duel: d})
+}
+
+func (h *Handler) ExpireDuels(ctx context.Context) {
+	if h.Duels == nil || h.DuelsRepo == nil || h.ItemsRepo == nil {
+		return
+	}
+
+	for _, d := range h.Duels.Expire(time.Now()) {
+		lg := logging.FromContext(ctx).With("duel_id", d.ID)
+
+		itemIDs, err := h.DuelsRepo.Expire(ctx, d.ID)
+		if err != nil {
+			lg.Error("ws: expire duel failed, retrying next tick", logging.KeyErr, err)
+			h.Duels.Restore(d)
+			continue
+		}
+		if err := h.ItemsRepo.UnlockItems(ctx, itemIDs); err != nil {
+			lg.Error("ws: expire duel unlock items failed", logging.KeyErr, err)
+		}
+		game.ObserveDuel(d)
+		lg.Info("ws: duel expired", "items", len(itemIDs))
+
+		h.Hub.BroadcastJSON(DuelUpdate{Event: EventDuelExpired, Duel: d})
+	}
+}
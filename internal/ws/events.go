@@ -13,7 +13,24 @@ const (
 	EventNewGame       Event = "newGame"
 	EventBetsAccepted  Event = "bets_accepted"
 	EventNewBets       Event = "new_bets"
+	EventBetCancelled  Event = "bet_cancelled"
 	EventSeriesUpdate  Event = "series_update"
 	EventSeriesState   Event = "series_state"
+	EventGamePaused    Event = "game_paused"
+	EventGameResumed   Event = "game_resumed"
+	EventRoundVoided   Event = "round_voided"
+	EventPoolSettled   Event = "pool_settled"
+	EventJackpotUpdate Event = "jackpot_update"
+	EventJackpotWon    Event = "jackpot_won"
+	EventDuelLobby     Event = "duel_lobby"
+	EventDuelOpened    Event = "duel_opened"
+	EventDuelResolved  Event = "duel_resolved"
+	EventDuelExpired   Event = "duel_expired"
+	EventAutoplayState Event = "autoplay_state"
+	EventLeaderboard   Event = "leaderboard_update"
+	EventTournamentEnd Event = "tournament_finished"
+	EventStandings     Event = "tournament_standings"
+	EventProfileStats  Event = "profile_stats"
+	EventServerClosing Event = "server_closing"
 	EventError         Event = "error"
 )
@@ -2,10 +2,12 @@ package ws
 
 import (
 	"CoinFlip/internal/game"
+	"CoinFlip/internal/logging"
 	"CoinFlip/internal/storage/postgres"
+	"CoinFlip/internal/tracing"
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,23 +15,80 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Handler struct {
-	Upgrader websocket.Upgrader
+	Upgrader         websocket.Upgrader
+	ReadLimit        int64
+	CompressionLevel int
+	Schemas          *SchemaValidator
 
-	Engine             *game.Engine
+	Tables             *game.Tables
 	Hub                *Hub
-	TokenStore         *TokenStore
+	Auth               Authenticator
+	SessionPolicy      SessionPolicy
+	MaxSessions        int
 	TokenTouchInterval time.Duration
 
-	ItemsRepo  *postgres.ItemsRepo
-	UsersRepo  *postgres.UsersRepo
-	BetsRepo   *postgres.BetsRepo
-	SeriesRepo *postgres.SeriesRepo
+	TelegramBotToken   string
+	TelegramAuthMaxAge time.Duration
+	TelegramSessionTTL time.Duration
+
+	ItemsRepo       *postgres.ItemsRepo
+	UsersRepo       *postgres.UsersRepo
+	BetsRepo        *postgres.BetsRepo
+	SeriesRepo      *postgres.SeriesRepo
+	DuelsRepo       *postgres.DuelsRepo
+	AutoplayRepo    *postgres.AutoplayRepo
+	TournamentsRepo *postgres.TournamentsRepo
+
+	Duels       *game.Duels
+	Jackpot     *game.Jackpot
+	Autoplay    *game.Autoplays
+	Tournaments *game.Tournaments
+	Leaderboard *Leaderboard
+
+	muTournaments sync.Mutex
 
 	muLocked sync.Mutex
 	locked   map[int][]int
+
+	muConns  sync.Mutex
+	draining bool
+	conns    sync.WaitGroup
+}
+
+func (h *Handler) enter() bool {
+	h.muConns.Lock()
+	defer h.muConns.Unlock()
+
+	if h.draining {
+		return false
+	}
+	h.conns.Add(1)
+	return true
+}
+
+func (h *Handler) Drain() {
+	h.muConns.Lock()
+	h.draining = true
+	h.muConns.Unlock()
+}
+
+func (h *Handler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (h *Handler) ensureLockedMap() {
@@ -52,6 +111,28 @@ func (h *Handler) addLocked(gameID int, ids []int) {
 	h.muLocked.Unlock()
 }
 
+func (h *Handler) dropLocked(gameID int, ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	h.ensureLockedMap()
+
+	drop := make(map[int]struct{}, len(ids))
+	for _, id := range ids {
+		drop[id] = struct{}{}
+	}
+
+	h.muLocked.Lock()
+	kept := h.locked[gameID][:0]
+	for _, id := range h.locked[gameID] {
+		if _, ok := drop[id]; !ok {
+			kept = append(kept, id)
+		}
+	}
+	h.locked[gameID] = kept
+	h.muLocked.Unlock()
+}
+
 func (h *Handler) takeLocked(gameID int) []int {
 	h.ensureLockedMap()
 
@@ -63,7 +144,7 @@ func (h *Handler) takeLocked(gameID int) []int {
 	return ids
 }
 
-func (h *Handler) UnlockForGame(gameID int) {
+func (h *Handler) UnlockForGame(ctx context.Context, gameID int) {
 	if h.ItemsRepo == nil {
 		return
 	}
@@ -73,42 +154,104 @@ func (h *Handler) UnlockForGame(gameID int) {
 		return
 	}
 
-	if err := h.ItemsRepo.UnlockItems(context.Background(), ids); err != nil {
-		log.Printf("ws: unlock items fail game_id=%d err=%v", gameID, err)
+	lg := logging.FromContext(ctx).With(logging.KeyGameID, gameID)
+	if err := h.ItemsRepo.UnlockItems(ctx, ids); err != nil {
+		lg.Error("ws: unlock items failed", logging.KeyErr, err)
 	} else {
-		log.Printf("ws: unlocked items game_id=%d n=%d", gameID, len(ids))
+		lg.Info("ws: unlocked items", "n", len(ids))
 	}
 }
 
+func (h *Handler) engineFor(conn *websocket.Conn) *game.Engine {
+	if e, ok := h.Tables.Get(h.Hub.TableOf(conn)); ok {
+		return e
+	}
+	return h.Tables.Default()
+}
+
+func (h *Handler) firstUpdate(e *game.Engine) FirstUpdate {
+	snap := e.Snapshot()
+	return FirstUpdate{
+		Event:     EventFirstUpdate,
+		TableID:   snap.TableID,
+		GamePhase: string(snap.Phase),
+		Timer:     snap.Timer,
+		GameID:    snap.GameID,
+		Hash:      snap.Hash,
+		Paused:    e.Paused(),
+		Limits:    e.Limits(),
+		Tables:    h.Tables.Infos(),
+		Pool:      poolOdds(e),
+		Jackpot:   h.jackpotState(),
+		Bets:      e.BetsSnapshot(),
+	}
+}
+
+func (h *Handler) jackpotState() *game.JackpotState {
+	if h.Jackpot == nil {
+		return nil
+	}
+	st := h.Jackpot.State()
+	return &st
+}
+
+func poolOdds(e *game.Engine) *game.PoolOdds {
+	if odds, ok := e.PoolOdds(); ok {
+		return &odds
+	}
+	return nil
+}
+
 func (h *Handler) sendErr(conn *websocket.Conn, msg string) {
 	_ = h.Hub.SendJSON(conn, ErrorMsg{
 		Event:   EventError,
 		Message: msg,
+		Code:    game.ReasonCode(msg),
 	})
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ip := r.RemoteAddr
+	ip := logging.ClientIP(r)
+	rid := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+	if rid == "" || len(rid) > 64 {
+		rid = logging.NewRequestID()
+	}
+
+	connCtx := logging.With(context.WithoutCancel(r.Context()), logging.KeyIP, ip)
+	lg := logging.FromContext(connCtx).With(logging.KeyRequestID, rid)
+
+	if !h.enter() {
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.conns.Done()
 
 	conn, err := h.Upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("ws: upgrade fail ip=%s err=%v", ip, err)
+		lg.Warn("ws: upgrade failed", logging.KeyErr, err)
 		return
 	}
 
-	var lockedToken string
-	var sessionID string
+	if h.ReadLimit > 0 {
+		conn.SetReadLimit(h.ReadLimit)
+	}
+	if h.CompressionLevel != 0 {
+		if err := conn.SetCompressionLevel(h.CompressionLevel); err != nil {
+			lg.Warn("ws: compression level failed", logging.KeyErr, err)
+		}
+	}
+
+	var sess *Session
 	stopPing := make(chan struct{})
 
 	defer func() {
 		close(stopPing)
 
-		uid := h.Hub.UserID(conn)
-		log.Printf("ws: disconnect ip=%s uid=%d", ip, uid)
+		lg.Info("ws: disconnect")
 
-		if lockedToken != "" && sessionID != "" && h.TokenStore != nil {
-			if err := h.TokenStore.UnlockWithSession(context.Background(), lockedToken, sessionID); err != nil {
-				log.Printf("ws: token unlock fail ip=%s uid=%d err=%v", ip, uid, err)
+		if sess != nil && h.Auth != nil {
+			if err := h.Auth.Release(connCtx, sess); err != nil {
+				lg.Warn("ws: token unlock failed", logging.KeyErr, err)
 			}
 		}
 
@@ -118,52 +261,111 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.Hub.Register(conn)
 	defer h.Hub.Unregister(conn)
 
-	log.Printf("ws: connect ip=%s", ip)
+	def := h.Tables.Default()
+	h.Hub.Subscribe(conn, def.TableID())
 
-	snap := h.Engine.Snapshot()
-	_ = h.Hub.SendJSON(conn, FirstUpdate{
-		Event:     EventFirstUpdate,
-		GamePhase: string(snap.Phase),
-		Timer:     snap.Timer,
-		GameID:    snap.GameID,
-		Hash:      snap.Hash,
-		Bets:      h.Engine.BetsSnapshot(),
-	})
+	lg.Info("ws: connect")
+
+	_ = h.Hub.SendJSON(conn, h.firstUpdate(def))
 
 	var login LoginMsg
 	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Minute))
-	if err := conn.ReadJSON(&login); err != nil {
-		log.Printf("ws: auth fail ip=%s reason=read_error err=%v", ip, err)
+	_, rawLogin, err := conn.ReadMessage()
+	if err != nil {
+		lg.Info("ws: auth failed", "reason", "read_error", logging.KeyErr, err)
 		return
 	}
+	if h.Schemas != nil {
+		if _, err := h.Schemas.Validate(rawLogin); err != nil {
+			lg.Info("ws: auth failed", "reason", "schema", logging.KeyErr, err)
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1008, "login required"))
+			return
+		}
+	}
+	if err := json.Unmarshal(rawLogin, &login); err != nil {
+		lg.Info("ws: auth failed", "reason", "bad_json", logging.KeyErr, err)
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1008, "login required"))
+		return
+	}
+
+	token := strings.TrimSpace(login.Token)
+	initData := strings.TrimSpace(login.InitData)
 
-	if login.ClientEvent != ClientEventLogin || strings.TrimSpace(login.Token) == "" {
-		log.Printf("ws: auth fail ip=%s reason=login_required", ip)
+	if login.ClientEvent != ClientEventLogin || (token == "" && initData == "") {
+		lg.Info("ws: auth failed", "reason", "login_required")
 		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1008, "login required"))
 		return
 	}
 
-	if h.TokenStore == nil {
-		log.Printf("ws: auth fail ip=%s reason=token_store_nil", ip)
-		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1011, "server misconfigured: token store"))
+	if h.Auth == nil {
+		lg.Error("ws: auth failed", "reason", "authenticator_nil")
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1011, "server misconfigured: authenticator"))
 		return
 	}
 
-	token := strings.TrimSpace(login.Token)
-	uid, sid, ok, err := h.TokenStore.LockWithSession(context.Background(), token)
-	if err != nil || !ok || uid == 0 {
-		log.Printf("ws: auth fail ip=%s reason=redis_token err=%v ok=%v uid=%d", ip, err, ok, uid)
+	var issuedToken string
+	if token == "" {
+		tgUser, err := VerifyTelegramInitData(initData, h.TelegramBotToken, h.TelegramAuthMaxAge, time.Now())
+		if err != nil {
+			lg.Info("ws: auth failed", "reason", "telegram_init_data", logging.KeyErr, err)
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1008, "invalid init data"))
+			return
+		}
+
+		if h.UsersRepo != nil {
+			if err := h.UsersRepo.UpsertTelegramUser(connCtx, tgUser.ID, tgUser.Username, tgUser.FirstName, tgUser.PhotoURL); err != nil {
+				lg.Error("ws: auth failed", "reason", "upsert_user", logging.KeyUserID, tgUser.ID, logging.KeyErr, err)
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1011, "db error: upsert user"))
+				return
+			}
+		}
+
+		token, err = h.Auth.Issue(connCtx, tgUser.ID, h.TelegramSessionTTL)
+		if err != nil {
+			lg.Error("ws: auth failed", "reason", "issue_token", logging.KeyUserID, tgUser.ID, logging.KeyErr, err)
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1011, "session issue failed"))
+			return
+		}
+		issuedToken = token
+	}
+
+	authed, err := h.Auth.Authenticate(connCtx, token)
+	if errors.Is(err, ErrTokenLocked) {
+		lg.Info("ws: auth failed", "reason", "session_limit", logging.KeyErr, err)
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1008, "session limit reached"))
+		return
+	}
+	if err != nil || authed == nil || authed.UserID == 0 {
+		lg.Info("ws: auth failed", "reason", "token", logging.KeyErr, err)
 		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1008, "invalid token"))
 		return
 	}
 
-	lockedToken = token
-	sessionID = sid
-	h.Hub.MarkAuthed(conn, uid)
+	sess = authed
+	uid := sess.UserID
+
+	connCtx = logging.With(connCtx, logging.KeyUserID, uid, logging.KeySessionID, sess.SessionID)
+	lg = logging.FromContext(connCtx).With(logging.KeyRequestID, rid)
+
+	for _, old := range h.Hub.ConnsBySession(uid, sess.Evicted) {
+		h.Hub.Kick(old, "session replaced")
+	}
+
+	replace := h.SessionPolicy == SessionPolicyReplace
+	kicked, admitted := h.Hub.Admit(conn, uid, sess.SessionID, h.SessionPolicy.Limit(h.MaxSessions), replace)
+	if !admitted {
+		lg.Info("ws: auth failed", "reason", "session_limit")
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1008, "session limit reached"))
+		return
+	}
+	for _, old := range kicked {
+		lg.Info("ws: session replaced", "old_ip", old.RemoteAddr().String())
+		h.Hub.Kick(old, "session replaced")
+	}
 
 	if h.UsersRepo != nil {
-		if err := h.UsersRepo.EnsureUser(context.Background(), uid); err != nil {
-			log.Printf("ws: ensure user fail uid=%d err=%v", uid, err)
+		if err := h.UsersRepo.EnsureUser(connCtx, uid); err != nil {
+			lg.Error("ws: ensure user failed", logging.KeyErr, err)
 			h.sendErr(conn, "db error: ensure user")
 			return
 		}
@@ -179,8 +381,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	conn.SetPongHandler(func(string) error {
 		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
 
-		if lockedToken != "" && sessionID != "" && h.TokenStore != nil {
-			_ = h.TokenStore.Touch(context.Background(), lockedToken, sessionID)
+		if h.Auth != nil {
+			_ = h.Auth.Touch(connCtx, sess)
 		}
 		return nil
 	})
@@ -192,8 +394,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		for {
 			select {
 			case <-ticker.C:
-				if lockedToken != "" && sessionID != "" && h.TokenStore != nil {
-					_ = h.TokenStore.Touch(context.Background(), lockedToken, sessionID)
+				if h.Auth != nil {
+					_ = h.Auth.Touch(connCtx, sess)
 				}
 				_ = conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(5*time.Second))
 
@@ -203,19 +405,22 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	log.Printf("ws: auth ok ip=%s uid=%d", ip, uid)
+	lg.Info("ws: auth ok")
 
-	snap = h.Engine.Snapshot()
+	snap := h.engineFor(conn).Snapshot()
 	_ = h.Hub.SendJSON(conn, Authorized{
 		Event:  EventAuthorized,
 		GameID: snap.GameID,
 		Hash:   snap.Hash,
 		Online: h.Hub.Online(),
+		Token:  issuedToken,
 	})
 
-	if ss, ok := h.Engine.SeriesSnapshot(uid); ok {
+	if owner, ok := h.Tables.SeriesOwner(uid); ok {
+		ss, _ := owner.SeriesSnapshot(uid)
 		_ = h.Hub.SendJSON(conn, SeriesStateMsg{
 			Event:      EventSeriesState,
+			TableID:    owner.TableID(),
 			UserID:     ss.UserID,
 			Side:       ss.Side,
 			Stake:      ss.Stake,
@@ -227,14 +432,30 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	if h.Autoplay != nil {
+		if _, ok := h.Autoplay.Get(uid); ok {
+			_ = h.Hub.SendJSON(conn, h.autoplayState(uid, ""))
+		}
+	}
+
 	for {
 		_, raw, err := conn.ReadMessage()
 		if err != nil {
 			return
 		}
 
-		if lockedToken != "" && sessionID != "" && h.TokenStore != nil {
-			_ = h.TokenStore.Touch(context.Background(), lockedToken, sessionID)
+		if h.Auth != nil {
+			_ = h.Auth.Touch(connCtx, sess)
+		}
+
+		snap := h.engineFor(conn).Snapshot()
+		ctx := logging.With(connCtx, logging.KeyRequestID, logging.NewRequestID(), "table_id", snap.TableID, logging.KeyGameID, snap.GameID)
+
+		if h.Schemas != nil {
+			if _, err := h.Schemas.Validate(raw); err != nil {
+				h.sendErr(conn, err.Error())
+				continue
+			}
 		}
 
 		var base struct {
@@ -245,264 +466,474 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		switch base.ClientEvent {
-		case ClientEventCashout:
-			userID := h.Hub.UserID(conn)
-			if userID == 0 {
-				h.sendErr(conn, "not authorized")
-				continue
-			}
+		h.dispatch(ctx, conn, base.ClientEvent, raw)
+	}
+}
 
-			prevSS, _ := h.Engine.SeriesSnapshot(userID)
+func (h *Handler) fail(ctx context.Context, conn *websocket.Conn, msg string) {
+	tracing.Fail(trace.SpanFromContext(ctx), msg)
+	h.sendErr(conn, msg)
+}
 
-			stake, mult, payout, ok, reason := h.Engine.Cashout(userID)
-			if !ok {
-				h.sendErr(conn, reason)
-				continue
-			}
+func (h *Handler) rollback(ctx context.Context, step string, err error) {
+	tracing.Rollback(ctx, step, err)
+	if err != nil {
+		logging.FromContext(ctx).Warn("ws: rollback failed", "step", step, logging.KeyErr, err)
+	}
+}
+
+func engineSpan(ctx context.Context, call string) trace.Span {
+	_, span := tracing.Start(ctx, "engine."+call)
+	return span
+}
+
+func (h *Handler) dispatch(ctx context.Context, conn *websocket.Conn, event ClientEvent, raw []byte) {
+	ctx, span := tracing.Start(ctx, "ws."+string(event))
+	defer span.End()
+
+	if id := tracing.TraceID(ctx); id != "" {
+		ctx = logging.With(ctx, "trace_id", id)
+	}
+	mlg := logging.FromContext(ctx)
+
+	var snap game.Snapshot
+
+	switch event {
+	case ClientEventSubscribe:
+		var msg SubscribeMsg
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			h.fail(ctx, conn, "bad subscribe json")
+			return
+		}
+
+		eng, ok := h.Tables.Get(msg.TableID)
+		if !ok {
+			h.fail(ctx, conn, "unknown table")
+			return
+		}
+		h.Hub.Subscribe(conn, eng.TableID())
+		mlg.Info("ws: subscribed", "table_id", eng.TableID())
+
+		_ = h.Hub.SendJSON(conn, h.firstUpdate(eng))
+
+	case ClientEventDuelLobby:
+		if h.Duels == nil {
+			h.fail(ctx, conn, "duels disabled")
+			return
+		}
+		_ = h.Hub.SendJSON(conn, h.duelLobby())
+
+	case ClientEventDuelOpen, ClientEventDuelMatch:
+		if h.Duels == nil || h.DuelsRepo == nil || h.ItemsRepo == nil {
+			h.fail(ctx, conn, "server misconfigured: repos")
+			return
+		}
+		if event == ClientEventDuelOpen {
+			h.duelOpen(ctx, conn, raw)
+		} else {
+			h.duelMatch(ctx, conn, raw)
+		}
+
+	case ClientEventAutoplayStart, ClientEventAutoplayStop:
+		if h.Autoplay == nil || h.AutoplayRepo == nil || h.ItemsRepo == nil || h.BetsRepo == nil {
+			h.fail(ctx, conn, game.ReasonAutoplayDisabled)
+			return
+		}
+		if event == ClientEventAutoplayStart {
+			h.autoplayStart(ctx, conn, raw)
+		} else {
+			h.autoplayStop(ctx, conn)
+		}
+
+	case ClientEventProfileStats:
+		if h.UsersRepo == nil {
+			h.fail(ctx, conn, "server misconfigured: repos")
+			return
+		}
+		h.profileStats(ctx, conn)
+
+	case ClientEventStandings:
+		if h.Tournaments == nil || h.TournamentsRepo == nil || h.Leaderboard == nil {
+			h.fail(ctx, conn, "tournaments disabled")
+			return
+		}
+		h.tournamentStandings(ctx, conn, raw)
+
+	case ClientEventCashout:
+		userID := h.Hub.UserID(conn)
+		if userID == 0 {
+			h.fail(ctx, conn, "not authorized")
+			return
+		}
+
+		eng, ok := h.Tables.SeriesOwner(userID)
+		if !ok {
+			h.fail(ctx, conn, "no active series")
+			return
+		}
+
+		prevSS, _ := eng.SeriesSnapshot(userID)
+
+		sp := engineSpan(ctx, "Cashout")
+		stake, mult, payout, ok, reason := eng.Cashout(userID)
+		sp.End()
+		if !ok {
+			h.fail(ctx, conn, reason)
+			return
+		}
 
-			if h.SeriesRepo != nil {
-				snap = h.Engine.Snapshot()
-				if _, err := h.SeriesRepo.Cashout(context.Background(), userID, snap.GameID, payout); err != nil {
-					if prevSS != nil {
-						h.Engine.RestoreSeriesSnapshot(*prevSS)
-					}
-					h.sendErr(conn, "db error: cashout series")
-					continue
+		if h.SeriesRepo != nil {
+			snap = eng.Snapshot()
+			if _, err := h.SeriesRepo.Cashout(ctx, userID, snap.GameID, payout); err != nil {
+				if prevSS != nil {
+					eng.RestoreSeriesSnapshot(*prevSS)
+					h.rollback(ctx, "restore_series", nil)
 				}
+				mlg.Error("ws: cashout failed", logging.KeyErr, err)
+				h.fail(ctx, conn, "db error: cashout series")
+				return
 			}
+		}
 
-			snap = h.Engine.Snapshot()
-			_ = h.Hub.SendJSON(conn, CashoutResult{
-				Event:      EventCashout,
-				GameID:     snap.GameID,
-				UserID:     userID,
-				Stake:      stake,
-				Multiplier: mult,
-				Payout:     payout,
-			})
+		mlg.Info("ws: cashout", "stake", stake, "multiplier", mult, "payout", payout)
+
+		snap = eng.Snapshot()
+		_ = h.Hub.SendJSON(conn, CashoutResult{
+			Event:      EventCashout,
+			TableID:    eng.TableID(),
+			GameID:     snap.GameID,
+			UserID:     userID,
+			Stake:      stake,
+			Multiplier: mult,
+			Payout:     payout,
+		})
 
-			_ = h.Hub.SendJSON(conn, SeriesStateMsg{
-				Event:      EventSeriesState,
-				UserID:     userID,
-				Side:       "",
-				Stake:      0,
-				Wins:       0,
-				Multiplier: 0,
-				Claimable:  0,
-				Stage:      "",
-				Active:     false,
-			})
+		_ = h.Hub.SendJSON(conn, SeriesStateMsg{
+			Event:      EventSeriesState,
+			TableID:    eng.TableID(),
+			UserID:     userID,
+			Side:       "",
+			Stake:      0,
+			Wins:       0,
+			Multiplier: 0,
+			Claimable:  0,
+			Stage:      "",
+			Active:     false,
+		})
 
-		case ClientEventSeriesContinue:
-			var msg SeriesContinueMsg
-			if err := json.Unmarshal(raw, &msg); err != nil {
-				h.sendErr(conn, "bad series_continue json")
-				continue
-			}
+	case ClientEventSeriesContinue:
+		var msg SeriesContinueMsg
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			h.fail(ctx, conn, "bad series_continue json")
+			return
+		}
 
-			userID := h.Hub.UserID(conn)
-			if userID == 0 {
-				h.sendErr(conn, "not authorized")
-				continue
-			}
+		userID := h.Hub.UserID(conn)
+		if userID == 0 {
+			h.fail(ctx, conn, "not authorized")
+			return
+		}
 
-			prevSS, _ := h.Engine.SeriesSnapshot(userID)
+		eng, ok := h.Tables.SeriesOwner(userID)
+		if !ok {
+			h.fail(ctx, conn, "no active series")
+			return
+		}
 
-			ss, ok, reason := h.Engine.SeriesContinue(userID, msg.Side)
-			if !ok {
-				h.sendErr(conn, reason)
-				continue
-			}
+		prevSS, _ := eng.SeriesSnapshot(userID)
+
+		sp := engineSpan(ctx, "SeriesContinue")
+		ss, ok, reason := eng.SeriesContinue(userID, msg.Side)
+		sp.End()
+		if !ok {
+			h.fail(ctx, conn, reason)
+			return
+		}
 
-			if h.SeriesRepo != nil {
-				snap = h.Engine.Snapshot()
-				if err := h.SeriesRepo.Continue(context.Background(), userID, snap.GameID, msg.Side); err != nil {
-					if prevSS != nil {
-						h.Engine.RestoreSeriesSnapshot(*prevSS)
-					}
-					h.sendErr(conn, "db error: continue series")
-					continue
+		if h.SeriesRepo != nil {
+			snap = eng.Snapshot()
+			if err := h.SeriesRepo.Continue(ctx, userID, snap.GameID, msg.Side); err != nil {
+				if prevSS != nil {
+					eng.RestoreSeriesSnapshot(*prevSS)
+					h.rollback(ctx, "restore_series", nil)
 				}
+				mlg.Error("ws: series continue failed", logging.KeyErr, err)
+				h.fail(ctx, conn, "db error: continue series")
+				return
 			}
+		}
 
-			_ = h.Hub.SendJSON(conn, SeriesStateMsg{
-				Event:      EventSeriesState,
-				UserID:     ss.UserID,
-				Side:       ss.Side,
-				Stake:      ss.Stake,
-				Wins:       ss.Wins,
-				Multiplier: ss.Multiplier,
-				Claimable:  ss.Claimable,
-				Stage:      string(ss.Stage),
-				Active:     ss.Active,
-			})
+		_ = h.Hub.SendJSON(conn, SeriesStateMsg{
+			Event:      EventSeriesState,
+			TableID:    eng.TableID(),
+			UserID:     ss.UserID,
+			Side:       ss.Side,
+			Stake:      ss.Stake,
+			Wins:       ss.Wins,
+			Multiplier: ss.Multiplier,
+			Claimable:  ss.Claimable,
+			Stage:      string(ss.Stage),
+			Active:     ss.Active,
+		})
 
-		case ClientEventBet:
-			var bet BetMsg
-			if err := json.Unmarshal(raw, &bet); err != nil {
-				h.sendErr(conn, "bad bet json")
-				continue
-			}
+	case ClientEventCancelBet:
+		userID := h.Hub.UserID(conn)
+		if userID == 0 {
+			h.fail(ctx, conn, "not authorized")
+			return
+		}
+		if h.ItemsRepo == nil || h.SeriesRepo == nil || h.BetsRepo == nil {
+			h.fail(ctx, conn, "server misconfigured: repos")
+			return
+		}
 
-			userID := h.Hub.UserID(conn)
-			if userID == 0 {
-				h.sendErr(conn, "not authorized")
-				continue
-			}
+		eng, ok := h.Tables.SeriesOwner(userID)
+		if !ok {
+			eng = h.engineFor(conn)
+		}
 
-			if bet.UserID != 0 && bet.UserID != userID {
-				h.sendErr(conn, "user_id mismatch")
-				continue
-			}
+		sp := engineSpan(ctx, "CancelBet")
+		cb, ok, reason := eng.CancelBet(userID)
+		sp.End()
+		if !ok {
+			h.fail(ctx, conn, reason)
+			return
+		}
 
-			if bet.Side != "heads" && bet.Side != "tails" {
-				h.sendErr(conn, "bad side")
-				continue
-			}
+		var itemIDs []int
+		var err error
+		if cb.Mode == game.ModeSeries {
+			itemIDs, err = h.SeriesRepo.CancelBet(ctx, userID, cb.GameID)
+		} else {
+			itemIDs, err = h.BetsRepo.CancelRoundBets(ctx, userID, cb.GameID, cb.Mode)
+		}
+		if err != nil {
+			eng.RestoreCancelledBet(cb)
+			h.rollback(ctx, "restore_bet", nil)
+			mlg.Error("ws: cancel bet failed", logging.KeyErr, err)
+			h.fail(ctx, conn, "db error: cancel bet")
+			return
+		}
 
-			mode := "series"
+		if err := h.ItemsRepo.UnlockItems(ctx, itemIDs); err != nil {
+			mlg.Error("ws: cancel bet unlock items failed", logging.KeyErr, err)
+		}
+		h.dropLocked(cb.GameID, itemIDs)
 
-			if len(bet.BetItems) == 0 {
-				h.sendErr(conn, "empty bet_items")
-				continue
+		if cb.Mode != game.ModeSeries {
+			if _, err := h.BetsRepo.RefundWalletBets(ctx, cb.GameID); err != nil {
+				mlg.Error("ws: cancel bet wallet refund failed", logging.KeyErr, err)
 			}
+		}
 
-			if h.ItemsRepo == nil {
-				h.sendErr(conn, "server misconfigured: items repo")
-				continue
-			}
-			if h.BetsRepo == nil {
-				h.sendErr(conn, "server misconfigured: bets repo")
-				continue
-			}
-			if h.SeriesRepo == nil {
-				h.sendErr(conn, "server misconfigured: series repo")
-				continue
-			}
+		mlg.Info("ws: bet cancelled", "items", len(itemIDs), "stake", cb.Stake)
 
-			ctx := context.Background()
+		if cb.Mode == game.ModeSeries {
+			_ = h.Hub.SendJSON(conn, SeriesStateMsg{Event: EventSeriesState, TableID: eng.TableID(), UserID: userID})
+		}
 
-			itemIDs := make([]int, 0, len(bet.BetItems))
-			for _, bi := range bet.BetItems {
-				id, err := strconv.Atoi(strings.TrimSpace(bi.ItemID))
-				if err != nil || id <= 0 {
-					h.sendErr(conn, "bad item_id: "+bi.ItemID)
-					itemIDs = nil
-					break
-				}
-				itemIDs = append(itemIDs, id)
-			}
-			if len(itemIDs) == 0 {
-				continue
-			}
+		snap = eng.Snapshot()
+		_, bsp := tracing.Start(ctx, "hub.broadcast")
+		h.Hub.BroadcastTable(eng.TableID(), BetCancelled{
+			Event:   EventBetCancelled,
+			TableID: eng.TableID(),
+			GameID:  cb.GameID,
+			Hash:    snap.Hash,
+			UserID:  userID,
+			Stake:   cb.Stake,
+			Items:   len(cb.Items),
+			Pool:    poolOdds(eng),
+			Bets:    eng.BetsSnapshotForGame(cb.GameID),
+		})
+		bsp.End()
 
-			dbItems, err := h.ItemsRepo.LockItems(ctx, itemIDs, userID)
-			if err != nil {
-				h.sendErr(conn, "item not found / not owned / already locked")
-				continue
-			}
+	case ClientEventBet:
+		var bet BetMsg
+		if err := json.Unmarshal(raw, &bet); err != nil {
+			h.fail(ctx, conn, "bad bet json")
+			return
+		}
 
-			items := make([]game.ItemRef, 0, len(dbItems))
-			lockedIDs := make([]int, 0, len(dbItems))
+		userID := h.Hub.UserID(conn)
+		if userID == 0 {
+			h.fail(ctx, conn, "not authorized")
+			return
+		}
 
-			for _, it := range dbItems {
-				lockedIDs = append(lockedIDs, it.ItemID)
+		if bet.UserID != 0 && bet.UserID != userID {
+			h.fail(ctx, conn, "user_id mismatch")
+			return
+		}
 
-				items = append(items, game.ItemRef{
-					Type:     it.Type,
-					ItemID:   strconv.Itoa(it.ItemID),
-					Name:     it.Name,
-					PhotoURL: it.PhotoURL,
-					CostTon:  it.CostTon,
-				})
-			}
+		eng := h.engineFor(conn)
+		if owner, ok := h.Tables.SeriesOwner(userID); ok && owner != eng {
+			h.fail(ctx, conn, "active series on another table")
+			return
+		}
 
-			snap, accepted, ok, reason := h.Engine.AddBet(userID, bet.Side, mode, items)
-			if !ok {
-				_ = h.ItemsRepo.UnlockItems(ctx, lockedIDs)
-				h.sendErr(conn, reason)
-				continue
-			}
+		mode, side, ok, reason := eng.NormalizeBet(bet.Mode, bet.Side)
+		if !ok {
+			h.fail(ctx, conn, reason)
+			return
+		}
+		bet.Side = side
+
+		if len(bet.BetItems) == 0 {
+			h.fail(ctx, conn, "empty bet_items")
+			return
+		}
 
-			totalStake := 0.0
-			for _, it := range items {
-				totalStake += it.CostTon
+		if h.ItemsRepo == nil {
+			h.fail(ctx, conn, "server misconfigured: items repo")
+			return
+		}
+		if h.BetsRepo == nil {
+			h.fail(ctx, conn, "server misconfigured: bets repo")
+			return
+		}
+		if h.SeriesRepo == nil {
+			h.fail(ctx, conn, "server misconfigured: series repo")
+			return
+		}
+
+		itemIDs := make([]int, 0, len(bet.BetItems))
+		for _, bi := range bet.BetItems {
+			id, err := strconv.Atoi(strings.TrimSpace(bi.ItemID))
+			if err != nil || id <= 0 {
+				h.fail(ctx, conn, "bad item_id: "+bi.ItemID)
+				itemIDs = nil
+				break
 			}
+			itemIDs = append(itemIDs, id)
+		}
+		if len(itemIDs) == 0 {
+			return
+		}
+
+		dbItems, err := h.ItemsRepo.LockItems(ctx, itemIDs, userID)
+		if err != nil {
+			h.fail(ctx, conn, "item not found / not owned / already locked")
+			return
+		}
 
-			sid, err := h.SeriesRepo.CreateSession(ctx, postgres.CreateSeriesSessionParams{
+		items := make([]game.ItemRef, 0, len(dbItems))
+		lockedIDs := make([]int, 0, len(dbItems))
+
+		for _, it := range dbItems {
+			lockedIDs = append(lockedIDs, it.ItemID)
+
+			items = append(items, game.ItemRef{
+				Type:     it.Type,
+				ItemID:   strconv.Itoa(it.ItemID),
+				Name:     it.Name,
+				PhotoURL: it.PhotoURL,
+				CostTon:  it.CostTon,
+			})
+		}
+
+		sp := engineSpan(ctx, "AddBet")
+		snap, accepted, ok, reason := eng.AddBet(userID, bet.Side, mode, items)
+		sp.End()
+		if !ok {
+			h.rollback(ctx, "unlock_items", h.ItemsRepo.UnlockItems(ctx, lockedIDs))
+			h.fail(ctx, conn, reason)
+			return
+		}
+
+		totalStake := 0.0
+		for _, it := range items {
+			totalStake += it.CostTon
+		}
+
+		var seriesSessionID *int64
+		var sid int64
+		if mode == game.ModeSeries {
+			sid, err = h.SeriesRepo.CreateSession(ctx, postgres.CreateSeriesSessionParams{
 				UserID:        userID,
 				InitialGameID: snap.GameID,
 				CurrentSide:   bet.Side,
 				StakeTon:      totalStake,
 			})
 			if err != nil {
-				h.Engine.RollbackAcceptedBet(snap.GameID, userID, mode, len(items))
-				_ = h.ItemsRepo.UnlockItems(ctx, lockedIDs)
-				h.sendErr(conn, "db error: create series session")
-				continue
-			}
-			seriesSessionID := &sid
-
-			rows := make([]postgres.CreateBetRow, 0, len(dbItems))
-			for _, it := range dbItems {
-				rows = append(rows, postgres.CreateBetRow{
-					GameID:          snap.GameID,
-					UserID:          userID,
-					Side:            bet.Side,
-					Mode:            mode,
-					SeriesSessionID: seriesSessionID,
-					ItemID:          it.ItemID,
-					ItemType:        it.Type,
-					ItemName:        it.Name,
-					ItemPhotoURL:    it.PhotoURL,
-					StakeTon:        it.CostTon,
-				})
-			}
-
-			if err := h.BetsRepo.InsertAcceptedBets(ctx, rows); err != nil {
-				_ = h.SeriesRepo.DeleteSession(ctx, *seriesSessionID)
-				h.Engine.RollbackAcceptedBet(snap.GameID, userID, mode, len(items))
-				_ = h.ItemsRepo.UnlockItems(ctx, lockedIDs)
-				h.sendErr(conn, "db error: save bets")
-				continue
+				eng.RollbackAcceptedBet(snap.GameID, userID, mode, len(items))
+				h.rollback(ctx, "engine_bet", nil)
+				h.rollback(ctx, "unlock_items", h.ItemsRepo.UnlockItems(ctx, lockedIDs))
+				mlg.Error("ws: create series session failed", logging.KeyErr, err)
+				h.fail(ctx, conn, "db error: create series session")
+				return
 			}
+			seriesSessionID = &sid
+		}
 
-			h.addLocked(snap.GameID, lockedIDs)
-
-			_ = h.Hub.SendJSON(conn, BetsAccepted{
-				Event:    EventBetsAccepted,
-				GameID:   snap.GameID,
-				Hash:     snap.Hash,
-				Accepted: accepted,
+		rows := make([]postgres.CreateBetRow, 0, len(dbItems))
+		for _, it := range dbItems {
+			rows = append(rows, postgres.CreateBetRow{
+				GameID:          snap.GameID,
+				UserID:          userID,
+				Side:            bet.Side,
+				Mode:            mode,
+				SeriesSessionID: seriesSessionID,
+				ItemID:          it.ItemID,
+				ItemType:        it.Type,
+				ItemName:        it.Name,
+				ItemPhotoURL:    it.PhotoURL,
+				StakeTon:        it.CostTon,
 			})
+		}
 
-			if ss, ok := h.Engine.SeriesSnapshot(userID); ok {
-				_ = h.Hub.SendJSON(conn, SeriesStateMsg{
-					Event:      EventSeriesState,
-					UserID:     ss.UserID,
-					Side:       ss.Side,
-					Stake:      ss.Stake,
-					Wins:       ss.Wins,
-					Multiplier: ss.Multiplier,
-					Claimable:  ss.Claimable,
-					Stage:      string(ss.Stage),
-					Active:     ss.Active,
-				})
+		if err := h.BetsRepo.InsertAcceptedBets(ctx, rows); err != nil {
+			if seriesSessionID != nil {
+				h.rollback(ctx, "delete_series_session", h.SeriesRepo.DeleteSession(ctx, *seriesSessionID))
 			}
+			eng.RollbackAcceptedBet(snap.GameID, userID, mode, len(items))
+			h.rollback(ctx, "engine_bet", nil)
+			h.rollback(ctx, "unlock_items", h.ItemsRepo.UnlockItems(ctx, lockedIDs))
+			mlg.Error("ws: save bets failed", logging.KeyErr, err)
+			h.fail(ctx, conn, "db error: save bets")
+			return
+		}
 
-			h.Hub.BroadcastJSON(NewBets{
-				Event:  EventNewBets,
-				GameID: snap.GameID,
-				Hash:   snap.Hash,
-				UserID: userID,
-				Side:   bet.Side,
-				Mode:   "series",
-				Bets:   h.Engine.BetsSnapshotForGame(snap.GameID),
-			})
+		h.addLocked(snap.GameID, lockedIDs)
+		mlg.Info("ws: bet accepted", "side", bet.Side, "mode", mode, "items", accepted, "stake", totalStake, "series_session_id", sid)
+
+		_ = h.Hub.SendJSON(conn, BetsAccepted{
+			Event:    EventBetsAccepted,
+			TableID:  eng.TableID(),
+			GameID:   snap.GameID,
+			Hash:     snap.Hash,
+			Accepted: accepted,
+		})
 
-		default:
-			h.sendErr(conn, "unknown client_event: "+string(base.ClientEvent))
+		if ss, ok := eng.SeriesSnapshot(userID); ok {
+			_ = h.Hub.SendJSON(conn, SeriesStateMsg{
+				Event:      EventSeriesState,
+				TableID:    eng.TableID(),
+				UserID:     ss.UserID,
+				Side:       ss.Side,
+				Stake:      ss.Stake,
+				Wins:       ss.Wins,
+				Multiplier: ss.Multiplier,
+				Claimable:  ss.Claimable,
+				Stage:      string(ss.Stage),
+				Active:     ss.Active,
+			})
 		}
+
+		_, bsp := tracing.Start(ctx, "hub.broadcast")
+		h.Hub.BroadcastTable(eng.TableID(), NewBets{
+			Event:   EventNewBets,
+			TableID: eng.TableID(),
+			GameID:  snap.GameID,
+			Hash:    snap.Hash,
+			UserID:  userID,
+			Side:    bet.Side,
+			Mode:    mode,
+			Pool:    poolOdds(eng),
+			Bets:    eng.BetsSnapshotForGame(snap.GameID),
+		})
+		bsp.End()
+
+	default:
+		h.fail(ctx, conn, "unknown client_event: "+string(event))
 	}
 }
@@ -1,9 +1,12 @@
 package ws
 
 import (
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/metrics"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,22 +14,37 @@ import (
 )
 
 type connState struct {
-	userID int64
-	authed bool
-	writeM sync.Mutex
+	userID    int64
+	sessionID string
+	authed    bool
+	authedAt  time.Time
+	tableID   int
+	writeM    sync.Mutex
 }
 
 type Hub struct {
 	mu    sync.RWMutex
 	conns map[*websocket.Conn]*connState
+	users map[int64]map[*websocket.Conn]struct{}
+
+	compress         bool
+	compressMinBytes int
 }
 
 func NewHub() *Hub {
 	return &Hub{
 		conns: make(map[*websocket.Conn]*connState),
+		users: make(map[int64]map[*websocket.Conn]struct{}),
 	}
 }
 
+func (h *Hub) SetCompression(enabled bool, minBytes int) {
+	h.mu.Lock()
+	h.compress = enabled
+	h.compressMinBytes = minBytes
+	h.mu.Unlock()
+}
+
 func (h *Hub) Register(c *websocket.Conn) {
 	h.mu.Lock()
 	h.conns[c] = &connState{}
@@ -35,17 +53,141 @@ func (h *Hub) Register(c *websocket.Conn) {
 
 func (h *Hub) Unregister(c *websocket.Conn) {
 	h.mu.Lock()
+	if st, ok := h.conns[c]; ok && st != nil && st.authed {
+		h.detachUserLocked(st.userID, c)
+	}
 	delete(h.conns, c)
 	h.mu.Unlock()
 }
 
-func (h *Hub) MarkAuthed(c *websocket.Conn, uid int64) {
+func (h *Hub) detachUserLocked(uid int64, c *websocket.Conn) {
+	m := h.users[uid]
+	if m == nil {
+		return
+	}
+	delete(m, c)
+	if len(m) == 0 {
+		delete(h.users, uid)
+	}
+}
+
+func (h *Hub) Admit(c *websocket.Conn, uid int64, sessionID string, limit int, replace bool) ([]*websocket.Conn, bool) {
+	if limit <= 0 {
+		limit = 1
+	}
+
 	h.mu.Lock()
-	if st, ok := h.conns[c]; ok && st != nil {
-		st.authed = true
-		st.userID = uid
+	defer h.mu.Unlock()
+
+	st, ok := h.conns[c]
+	if !ok || st == nil {
+		return nil, false
+	}
+
+	existing := make([]*websocket.Conn, 0, len(h.users[uid]))
+	for other := range h.users[uid] {
+		if other != c {
+			existing = append(existing, other)
+		}
+	}
+
+	var evicted []*websocket.Conn
+	if len(existing) >= limit {
+		if !replace {
+			return nil, false
+		}
+
+		sort.Slice(existing, func(i, j int) bool {
+			return h.conns[existing[i]].authedAt.Before(h.conns[existing[j]].authedAt)
+		})
+		for len(existing) >= limit {
+			old := existing[0]
+			existing = existing[1:]
+			h.detachUserLocked(uid, old)
+			if ost := h.conns[old]; ost != nil {
+				ost.authed = false
+			}
+			evicted = append(evicted, old)
+		}
+	}
+
+	st.authed = true
+	st.userID = uid
+	st.sessionID = sessionID
+	st.authedAt = time.Now()
+
+	if h.users[uid] == nil {
+		h.users[uid] = make(map[*websocket.Conn]struct{})
+	}
+	h.users[uid][c] = struct{}{}
+
+	return evicted, true
+}
+
+func (h *Hub) ConnsBySession(uid int64, sessionIDs []string) []*websocket.Conn {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	want := make(map[string]struct{}, len(sessionIDs))
+	for _, sid := range sessionIDs {
+		want[sid] = struct{}{}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	out := make([]*websocket.Conn, 0)
+	for c := range h.users[uid] {
+		if st := h.conns[c]; st != nil {
+			if _, ok := want[st.sessionID]; ok {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+func (h *Hub) Kick(c *websocket.Conn, reason string) {
+	h.mu.Lock()
+	st := h.conns[c]
+	if st != nil && st.authed {
+		h.detachUserLocked(st.userID, c)
+		st.authed = false
 	}
 	h.mu.Unlock()
+
+	if st != nil {
+		st.writeM.Lock()
+		_ = c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(1008, reason), time.Now().Add(time.Second))
+		st.writeM.Unlock()
+	}
+	_ = c.Close()
+}
+
+func (h *Hub) CloseAll(code int, reason string) int {
+	h.mu.Lock()
+	conns := make([]*websocket.Conn, 0, len(h.conns))
+	states := make([]*connState, 0, len(h.conns))
+	for c, st := range h.conns {
+		if st != nil && st.authed {
+			h.detachUserLocked(st.userID, c)
+			st.authed = false
+		}
+		conns = append(conns, c)
+		states = append(states, st)
+	}
+	h.mu.Unlock()
+
+	for i, c := range conns {
+		if st := states[i]; st != nil {
+			st.writeM.Lock()
+			_ = c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+			st.writeM.Unlock()
+		}
+		_ = c.Close()
+	}
+	return len(conns)
 }
 
 func (h *Hub) UserID(c *websocket.Conn) int64 {
@@ -59,6 +201,41 @@ func (h *Hub) UserID(c *websocket.Conn) int64 {
 	return st.userID
 }
 
+func (h *Hub) Subscribe(c *websocket.Conn, tableID int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st := h.conns[c]
+	if st == nil {
+		return false
+	}
+	st.tableID = tableID
+	return true
+}
+
+func (h *Hub) TableOf(c *websocket.Conn) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if st := h.conns[c]; st != nil {
+		return st.tableID
+	}
+	return 0
+}
+
+func (h *Hub) OnlineAt(tableID int) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	n := 0
+	for _, st := range h.conns {
+		if st != nil && st.authed && st.tableID == tableID {
+			n++
+		}
+	}
+	return n
+}
+
 func (h *Hub) Online() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -80,22 +257,27 @@ func (h *Hub) writeJSON(c *websocket.Conn, v any) error {
 
 	h.mu.RLock()
 	st := h.conns[c]
+	compress := h.compress && len(b) >= h.compressMinBytes
 	h.mu.RUnlock()
 
 	if st == nil {
 		return fmt.Errorf("connection not registered")
 	}
 
+	metrics.WSWriteQueue.Inc()
 	st.writeM.Lock()
+	metrics.WSWriteQueue.Dec()
 	defer st.writeM.Unlock()
 
+	c.EnableWriteCompression(compress)
 	_ = c.SetWriteDeadline(time.Now().Add(5 * time.Second))
 	return c.WriteMessage(websocket.TextMessage, b)
 }
 
 func (h *Hub) SendJSON(c *websocket.Conn, v any) error {
 	if err := h.writeJSON(c, v); err != nil {
-		log.Printf("hub: send fail ip=%s err=%v", c.RemoteAddr(), err)
+		metrics.WSSendFailures.Inc()
+		slog.Warn("hub: send failed", logging.KeyIP, c.RemoteAddr().String(), logging.KeyErr, err)
 		return err
 	}
 	return nil
@@ -116,11 +298,26 @@ func (h *Hub) BroadcastJSON(v any) {
 	}
 }
 
-func (h *Hub) SendToUser(userID int64, v any) {
+func (h *Hub) BroadcastTable(tableID int, v any) {
 	h.mu.RLock()
-	conns := make([]*websocket.Conn, 0)
+	conns := make([]*websocket.Conn, 0, len(h.conns))
 	for c, st := range h.conns {
-		if st != nil && st.authed && st.userID == userID {
+		if st != nil && st.authed && st.tableID == tableID {
+			conns = append(conns, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range conns {
+		_ = h.SendJSON(c, v)
+	}
+}
+
+func (h *Hub) SendToUser(userID int64, v any) {
+	h.mu.RLock()
+	conns := make([]*websocket.Conn, 0, len(h.users[userID]))
+	for c := range h.users[userID] {
+		if st := h.conns[c]; st != nil && st.authed {
 			conns = append(conns, c)
 		}
 	}
@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"CoinFlip/internal/game"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const leaderboardKeyPrefix = "coinflip:tournament:standings:"
+
+type Leaderboard struct {
+	rdb *redis.Client
+}
+
+func NewLeaderboard(rdb *redis.Client) *Leaderboard {
+	return &Leaderboard{rdb: rdb}
+}
+
+func leaderboardKey(runID int64) string {
+	return leaderboardKeyPrefix + strconv.FormatInt(runID, 10)
+}
+
+func (l *Leaderboard) Replace(ctx context.Context, runID int64, standings []game.Standing, ttl time.Duration) error {
+	key := leaderboardKey(runID)
+
+	members := make(map[string]any, len(standings))
+	for _, st := range standings {
+		raw, err := json.Marshal(st)
+		if err != nil {
+			return err
+		}
+		members[strconv.FormatInt(st.UserID, 10)] = raw
+	}
+
+	pipe := l.rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(members) > 0 {
+		pipe.HSet(ctx, key, members)
+		pipe.Expire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (l *Leaderboard) Rank(ctx context.Context, runID int64, userID int64) (game.Standing, bool, error) {
+	raw, err := l.rdb.HGet(ctx, leaderboardKey(runID), strconv.FormatInt(userID, 10)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return game.Standing{}, false, nil
+	}
+	if err != nil {
+		return game.Standing{}, false, err
+	}
+
+	var st game.Standing
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return game.Standing{}, false, err
+	}
+	return st, true, nil
+}
@@ -0,0 +1,44 @@
+package ws
+
+import (
+	"CoinFlip/internal/logging"
+	"context"
+
+	"github.com/gorilla/websocket"
+)
+
+func (h *Handler) profileStats(ctx context.Context, conn *websocket.Conn) {
+	userID := h.Hub.UserID(conn)
+	if userID == 0 {
+		h.fail(ctx, conn, "not authorized")
+		return
+	}
+
+	ps, err := h.UsersRepo.ProfileStats(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("ws: profile stats failed", logging.KeyErr, err)
+		h.fail(ctx, conn, "db error: profile stats")
+		return
+	}
+
+	_ = h.Hub.SendJSON(conn, ProfileStats{
+		Event:          EventProfileStats,
+		UserID:         ps.UserID,
+		GamesCount:     ps.GamesCount,
+		WinCount:       ps.WinCount,
+		BetsSum:        ps.BetsSum,
+		WinSum:         ps.WinSum,
+		LastActiveAt:   ps.LastActiveAt,
+		LongestSeries:  ps.LongestSeries,
+		BestMultiplier: ps.BestMultiplier,
+	})
+}
+
+func (h *Handler) ApplyBetStats(ctx context.Context) {
+	if h.UsersRepo == nil {
+		return
+	}
+	if _, err := h.UsersRepo.ApplyBetStats(ctx); err != nil {
+		logging.FromContext(ctx).Error("users: apply bet stats failed", logging.KeyErr, err)
+	}
+}
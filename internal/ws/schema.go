@@ -0,0 +1,298 @@
+package ws
+
+import (
+	"CoinFlip/internal/config"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindNumber
+	kindBool
+	kindArray
+	kindObject
+)
+
+func (k fieldKind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindNumber:
+		return "number"
+	case kindBool:
+		return "bool"
+	case kindArray:
+		return "array"
+	case kindObject:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+type fieldSpec struct {
+	kind     fieldKind
+	required bool
+	maxLen   int
+	elem     *messageSchema
+}
+
+type messageSchema struct {
+	maxBytes int
+	fields   map[string]fieldSpec
+}
+
+const maxAutoplayItems = 1000
+
+type SchemaValidator struct {
+	mu      sync.RWMutex
+	schemas map[ClientEvent]messageSchema
+}
+
+func NewSchemaValidator(maxBetItems int) *SchemaValidator {
+	return &SchemaValidator{schemas: buildSchemas(maxBetItems)}
+}
+
+func (v *SchemaValidator) SetMaxBetItems(maxBetItems int) {
+	schemas := buildSchemas(maxBetItems)
+
+	v.mu.Lock()
+	v.schemas = schemas
+	v.mu.Unlock()
+}
+
+func buildSchemas(maxBetItems int) map[ClientEvent]messageSchema {
+	if maxBetItems <= 0 {
+		maxBetItems = 50
+	}
+
+	betItem := &messageSchema{
+		fields: map[string]fieldSpec{
+			"type":    {kind: kindString, maxLen: 32},
+			"item_id": {kind: kindString, required: true, maxLen: 20},
+		},
+	}
+
+	return map[ClientEvent]messageSchema{
+		ClientEventLogin: {
+			maxBytes: 8192,
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+				"token":        {kind: kindString, maxLen: 512},
+				"init_data":    {kind: kindString, maxLen: 4096},
+			},
+		},
+		ClientEventBet: {
+			maxBytes: config.BetFrameBytes(maxBetItems),
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+				"user_id":      {kind: kindNumber},
+				"side":         {kind: kindString, required: true, maxLen: 16},
+				"mode":         {kind: kindString, maxLen: 16},
+				"bet_items":    {kind: kindArray, required: true, maxLen: maxBetItems, elem: betItem},
+			},
+		},
+		ClientEventCashout: {
+			maxBytes: 256,
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+			},
+		},
+		ClientEventSubscribe: {
+			maxBytes: 256,
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+				"table_id":     {kind: kindNumber, required: true},
+			},
+		},
+		ClientEventCancelBet: {
+			maxBytes: 256,
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+			},
+		},
+		ClientEventDuelLobby: {
+			maxBytes: 256,
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+			},
+		},
+		ClientEventDuelOpen: {
+			maxBytes: config.BetFrameBytes(maxBetItems),
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+				"side":         {kind: kindString, required: true, maxLen: 16},
+				"bet_items":    {kind: kindArray, required: true, maxLen: maxBetItems, elem: betItem},
+			},
+		},
+		ClientEventDuelMatch: {
+			maxBytes: config.BetFrameBytes(maxBetItems),
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+				"duel_id":      {kind: kindNumber, required: true},
+				"bet_items":    {kind: kindArray, required: true, maxLen: maxBetItems, elem: betItem},
+			},
+		},
+		ClientEventAutoplayStart: {
+			maxBytes: config.AutoplayFrameBytes(maxAutoplayItems),
+			fields: map[string]fieldSpec{
+				"client_event":    {kind: kindString, required: true, maxLen: 32},
+				"side":            {kind: kindString, required: true, maxLen: 16},
+				"mode":            {kind: kindString, maxLen: 16},
+				"source":          {kind: kindString, required: true, maxLen: 16},
+				"stake_ton":       {kind: kindNumber},
+				"bet_items":       {kind: kindArray, maxLen: maxAutoplayItems, elem: betItem},
+				"rounds":          {kind: kindNumber, required: true},
+				"stop_loss_ton":   {kind: kindNumber},
+				"take_profit_ton": {kind: kindNumber},
+			},
+		},
+		ClientEventAutoplayStop: {
+			maxBytes: 256,
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+			},
+		},
+		ClientEventStandings: {
+			maxBytes: 256,
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+				"run_id":       {kind: kindNumber, required: true},
+			},
+		},
+		ClientEventProfileStats: {
+			maxBytes: 256,
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+			},
+		},
+		ClientEventSeriesContinue: {
+			maxBytes: 256,
+			fields: map[string]fieldSpec{
+				"client_event": {kind: kindString, required: true, maxLen: 32},
+				"side":         {kind: kindString, required: true, maxLen: 16},
+			},
+		},
+	}
+}
+
+func (v *SchemaValidator) Validate(raw []byte) (ClientEvent, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", fmt.Errorf("bad json")
+	}
+
+	evRaw, ok := fields["client_event"]
+	if !ok {
+		return "", fmt.Errorf("missing client_event")
+	}
+	var ev ClientEvent
+	if err := json.Unmarshal(evRaw, &ev); err != nil {
+		return "", fmt.Errorf("bad client_event")
+	}
+
+	v.mu.RLock()
+	schema, ok := v.schemas[ev]
+	v.mu.RUnlock()
+	if !ok {
+		return ev, fmt.Errorf("unknown client_event: %s", ev)
+	}
+	if schema.maxBytes > 0 && len(raw) > schema.maxBytes {
+		return ev, fmt.Errorf("%s message too large", ev)
+	}
+
+	if err := validateFields(fields, schema); err != nil {
+		return ev, err
+	}
+	return ev, nil
+}
+
+func validateFields(fields map[string]json.RawMessage, schema messageSchema) error {
+	for name, spec := range schema.fields {
+		val, ok := fields[name]
+		if !ok || isJSONNull(val) {
+			if spec.required {
+				return fmt.Errorf("missing field %s", name)
+			}
+			continue
+		}
+		if err := validateValue(name, val, spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(name string, val json.RawMessage, spec fieldSpec) error {
+	if kindOf(val) != spec.kind {
+		return fmt.Errorf("field %s must be %s", name, spec.kind)
+	}
+
+	switch spec.kind {
+	case kindString:
+		var s string
+		if err := json.Unmarshal(val, &s); err != nil {
+			return fmt.Errorf("field %s must be string", name)
+		}
+		if spec.maxLen > 0 && len(s) > spec.maxLen {
+			return fmt.Errorf("field %s too long", name)
+		}
+
+	case kindArray:
+		var arr []json.RawMessage
+		if err := json.Unmarshal(val, &arr); err != nil {
+			return fmt.Errorf("field %s must be array", name)
+		}
+		if spec.maxLen > 0 && len(arr) > spec.maxLen {
+			return fmt.Errorf("field %s has too many elements", name)
+		}
+		if spec.elem == nil {
+			return nil
+		}
+		for _, el := range arr {
+			if kindOf(el) != kindObject {
+				return fmt.Errorf("field %s elements must be object", name)
+			}
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(el, &obj); err != nil {
+				return fmt.Errorf("field %s elements must be object", name)
+			}
+			if err := validateFields(obj, *spec.elem); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isJSONNull(val json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(val), []byte("null"))
+}
+
+func kindOf(val json.RawMessage) fieldKind {
+	val = bytes.TrimSpace(val)
+	if len(val) == 0 {
+		return -1
+	}
+
+	switch val[0] {
+	case '"':
+		return kindString
+	case '[':
+		return kindArray
+	case '{':
+		return kindObject
+	case 't', 'f':
+		return kindBool
+	case 'n':
+		return -1
+	default:
+		return kindNumber
+	}
+}
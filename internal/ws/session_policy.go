@@ -0,0 +1,29 @@
+package ws
+
+import "fmt"
+
+type SessionPolicy string
+
+const (
+	SessionPolicyReject  SessionPolicy = "reject"
+	SessionPolicyReplace SessionPolicy = "replace"
+	SessionPolicyAllow   SessionPolicy = "allow"
+)
+
+func ParseSessionPolicy(s string) (SessionPolicy, error) {
+	switch p := SessionPolicy(s); p {
+	case SessionPolicyReject, SessionPolicyReplace, SessionPolicyAllow:
+		return p, nil
+	case "":
+		return SessionPolicyReject, nil
+	default:
+		return "", fmt.Errorf("unknown session policy %q", s)
+	}
+}
+
+func (p SessionPolicy) Limit(maxConcurrent int) int {
+	if p == SessionPolicyAllow && maxConcurrent > 1 {
+		return maxConcurrent
+	}
+	return 1
+}
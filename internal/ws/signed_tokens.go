@@ -0,0 +1,192 @@
+package ws
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenMalformed  = errors.New("token malformed")
+	ErrTokenSignature  = errors.New("token signature mismatch")
+	ErrTokenExpired    = errors.New("token expired")
+	ErrTokenUnknownKid = errors.New("token signed with unknown key")
+)
+
+type signedTokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type signedTokenClaims struct {
+	Sub string `json:"sub"`
+	Iat int64  `json:"iat"`
+	Exp int64  `json:"exp"`
+	Jti string `json:"jti"`
+}
+
+type SignedTokens struct {
+	keys      map[string][]byte
+	activeKid string
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+func NewSignedTokens(keys map[string][]byte, activeKid string, ttl time.Duration) (*SignedTokens, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys")
+	}
+	if _, ok := keys[activeKid]; !ok {
+		return nil, fmt.Errorf("active kid %q not in key set", activeKid)
+	}
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	cp := make(map[string][]byte, len(keys))
+	for kid, k := range keys {
+		if len(k) < 32 {
+			return nil, fmt.Errorf("signing key %q shorter than 32 bytes", kid)
+		}
+		cp[kid] = append([]byte(nil), k...)
+	}
+
+	return &SignedTokens{
+		keys:      cp,
+		activeKid: activeKid,
+		ttl:       ttl,
+		now:       time.Now,
+	}, nil
+}
+
+func ParseSigningKeys(spec string) (map[string][]byte, error) {
+	out := make(map[string][]byte)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(part, ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("bad signing key entry %q", part)
+		}
+		out[kid] = []byte(secret)
+	}
+	return out, nil
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func (t *SignedTokens) sign(kid string, signingInput string) ([]byte, bool) {
+	key, ok := t.keys[kid]
+	if !ok {
+		return nil, false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil), true
+}
+
+func (t *SignedTokens) Issue(_ context.Context, userID int64, ttl time.Duration) (string, error) {
+	if userID <= 0 {
+		return "", fmt.Errorf("invalid user_id")
+	}
+	if ttl <= 0 {
+		ttl = t.ttl
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := t.now()
+	hb, err := json.Marshal(signedTokenHeader{Alg: "HS256", Typ: "JWT", Kid: t.activeKid})
+	if err != nil {
+		return "", err
+	}
+	cb, err := json.Marshal(signedTokenClaims{
+		Sub: strconv.FormatInt(userID, 10),
+		Iat: now.Unix(),
+		Exp: now.Add(ttl).Unix(),
+		Jti: hex.EncodeToString(jti),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	input := b64(hb) + "." + b64(cb)
+	sig, _ := t.sign(t.activeKid, input)
+	return input + "." + b64(sig), nil
+}
+
+func (t *SignedTokens) Authenticate(_ context.Context, token string) (*Session, error) {
+	token = stringsTrim(token)
+	if token == "" {
+		return nil, ErrTokenEmpty
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	hb, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	var hdr signedTokenHeader
+	if err := json.Unmarshal(hb, &hdr); err != nil || hdr.Alg != "HS256" {
+		return nil, ErrTokenMalformed
+	}
+
+	want, ok := t.sign(hdr.Kid, parts[0]+"."+parts[1])
+	if !ok {
+		return nil, ErrTokenUnknownKid
+	}
+	got, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(got, want) {
+		return nil, ErrTokenSignature
+	}
+
+	cb, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	var claims signedTokenClaims
+	if err := json.Unmarshal(cb, &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if claims.Exp <= t.now().Unix() {
+		return nil, ErrTokenExpired
+	}
+
+	uid, err := strconv.ParseInt(claims.Sub, 10, 64)
+	if err != nil || uid <= 0 {
+		return nil, ErrTokenMalformed
+	}
+
+	return &Session{UserID: uid, Token: token, SessionID: claims.Jti}, nil
+}
+
+func (t *SignedTokens) Touch(context.Context, *Session) error { return nil }
+
+func (t *SignedTokens) Release(context.Context, *Session) error { return nil }
+
+func (t *SignedTokens) Verify(ctx context.Context, token string) (int64, error) {
+	sess, err := t.Authenticate(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	return sess.UserID, nil
+}
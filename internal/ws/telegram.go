@@ -0,0 +1,100 @@
+package ws
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInitDataEmpty     = errors.New("init data empty")
+	ErrInitDataBadHash   = errors.New("init data hash mismatch")
+	ErrInitDataExpired   = errors.New("init data expired")
+	ErrInitDataNoUser    = errors.New("init data has no user")
+	ErrTelegramBotNotSet = errors.New("telegram bot token not configured")
+)
+
+type TelegramUser struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	PhotoURL  string `json:"photo_url"`
+}
+
+func VerifyTelegramInitData(initData, botToken string, maxAge time.Duration, now time.Time) (*TelegramUser, error) {
+	if botToken == "" {
+		return nil, ErrTelegramBotNotSet
+	}
+	initData = strings.TrimSpace(initData)
+	if initData == "" {
+		return nil, ErrInitDataEmpty
+	}
+
+	vals, err := url.ParseQuery(initData)
+	if err != nil {
+		return nil, err
+	}
+
+	gotHash := vals.Get("hash")
+	if gotHash == "" {
+		return nil, ErrInitDataBadHash
+	}
+
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		if k == "hash" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+"="+vals.Get(k))
+	}
+	dataCheck := strings.Join(lines, "\n")
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(botToken))
+
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(dataCheck))
+	want := mac.Sum(nil)
+
+	got, err := hex.DecodeString(gotHash)
+	if err != nil || !hmac.Equal(got, want) {
+		return nil, ErrInitDataBadHash
+	}
+
+	authDate, err := strconv.ParseInt(vals.Get("auth_date"), 10, 64)
+	if err != nil || authDate <= 0 {
+		return nil, ErrInitDataExpired
+	}
+	if maxAge > 0 && now.Sub(time.Unix(authDate, 0)) > maxAge {
+		return nil, ErrInitDataExpired
+	}
+
+	rawUser := vals.Get("user")
+	if rawUser == "" {
+		return nil, ErrInitDataNoUser
+	}
+
+	var u TelegramUser
+	if err := json.Unmarshal([]byte(rawUser), &u); err != nil {
+		return nil, err
+	}
+	if u.ID <= 0 {
+		return nil, ErrInitDataNoUser
+	}
+
+	return &u, nil
+}
@@ -1,12 +1,14 @@
 package ws
 
 import (
+	"CoinFlip/internal/metrics"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -18,6 +20,17 @@ var (
 	ErrTokenLocked   = errors.New("token locked")
 )
 
+func runScript(ctx context.Context, name string, script *redis.Script, rdb *redis.Client, keys []string, args ...interface{}) (interface{}, error) {
+	start := time.Now()
+	res, err := script.Run(ctx, rdb, keys, args...).Result()
+	if errors.Is(err, redis.Nil) {
+		metrics.ObserveScript(name, start, nil)
+	} else {
+		metrics.ObserveScript(name, start, err)
+	}
+	return res, err
+}
+
 type TokenStore struct {
 	rdb *redis.Client
 
@@ -25,6 +38,10 @@ type TokenStore struct {
 	unlockScript *redis.Script
 	touchScript  *redis.Script
 
+	policy       SessionPolicy
+	maxSessions  int
+	staleSeconds int
+
 	opTimeout time.Duration
 }
 
@@ -40,35 +57,93 @@ if not uid then
   return {0, 0}
 end
 
+local now = tonumber(ARGV[2])
+local max = tonumber(ARGV[4])
+local stale = tonumber(ARGV[5])
+
 local locked = redis.call("HGET", KEYS[1], "locked")
-if locked and tonumber(locked) ~= 0 then
-  return {tonumber(uid), 0}
+if locked and tonumber(locked) ~= 0 and redis.call("HLEN", KEYS[2]) == 0 then
+  local legacy = redis.call("HGET", KEYS[1], "session_id")
+  if legacy then
+    local seen = redis.call("HGET", KEYS[1], "last_seen") or ARGV[2]
+    redis.call("HSET", KEYS[2], legacy, seen)
+    redis.call("ZADD", KEYS[3], seen, legacy .. ":" .. ARGV[6])
+  end
+end
+
+local live = {}
+local sessions = redis.call("HGETALL", KEYS[2])
+for i = 1, #sessions, 2 do
+  local seen = tonumber(sessions[i + 1]) or 0
+  if stale > 0 and now - seen >= stale then
+    redis.call("HDEL", KEYS[2], sessions[i])
+    redis.call("ZREM", KEYS[3], sessions[i] .. ":" .. ARGV[6])
+  else
+    table.insert(live, {sessions[i], seen})
+  end
+end
+
+local evicted = {}
+if #live >= max then
+  if ARGV[3] ~= "replace" then
+    return {tonumber(uid), 0}
+  end
+  table.sort(live, function(a, b) return a[2] < b[2] end)
+  while #live >= max do
+    local old = table.remove(live, 1)
+    redis.call("HDEL", KEYS[2], old[1])
+    redis.call("ZREM", KEYS[3], old[1] .. ":" .. ARGV[6])
+    table.insert(evicted, old[1])
+  end
 end
 
-redis.call("HSET", KEYS[1], "locked", 1)
+redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+redis.call("ZADD", KEYS[3], ARGV[2], ARGV[1] .. ":" .. ARGV[6])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl > 0 then
+  redis.call("PEXPIRE", KEYS[2], ttl)
+end
+redis.call("HSET", KEYS[1], "locked", #live + 1)
 redis.call("HSET", KEYS[1], "session_id", ARGV[1])
 redis.call("HSET", KEYS[1], "last_seen", ARGV[2])
-return {tonumber(uid), 1}
+
+local out = {tonumber(uid), 1}
+for _, sid in ipairs(evicted) do
+  table.insert(out, sid)
+end
+return out
 `
 
 	unlockLua := `
+local member = ARGV[1] .. ":" .. ARGV[2]
+if ARGV[3] then
+  local seen = redis.call("ZSCORE", KEYS[3], member)
+  if seen and tonumber(seen) > tonumber(ARGV[3]) then
+    return 0
+  end
+end
+
+redis.call("ZREM", KEYS[3], member)
 local exists = redis.call("EXISTS", KEYS[1])
 if exists == 0 then
+  redis.call("DEL", KEYS[2])
   return 0
 end
 
+local removed = redis.call("HDEL", KEYS[2], ARGV[1])
 local sid = redis.call("HGET", KEYS[1], "session_id")
-if not sid then
+if removed == 0 and sid ~= ARGV[1] then
   return 0
 end
 
-if sid ~= ARGV[1] then
-  return 0
+local left = redis.call("HLEN", KEYS[2])
+if left == 0 then
+  redis.call("HSET", KEYS[1], "locked", 0)
+  redis.call("HDEL", KEYS[1], "session_id")
+  redis.call("HDEL", KEYS[1], "last_seen")
+else
+  redis.call("HSET", KEYS[1], "locked", left)
 end
-
-redis.call("HSET", KEYS[1], "locked", 0)
-redis.call("HDEL", KEYS[1], "session_id")
-redis.call("HDEL", KEYS[1], "last_seen")
 return 1
 `
 
@@ -83,14 +158,15 @@ if not locked or tonumber(locked) == 0 then
   return 0
 end
 
-local sid = redis.call("HGET", KEYS[1], "session_id")
-if not sid then
-  return 0
-end
-if sid ~= ARGV[1] then
-  return 0
+if redis.call("HEXISTS", KEYS[2], ARGV[1]) == 0 then
+  local sid = redis.call("HGET", KEYS[1], "session_id")
+  if not sid or sid ~= ARGV[1] then
+    return 0
+  end
 end
 
+redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+redis.call("ZADD", KEYS[3], ARGV[2], ARGV[1] .. ":" .. ARGV[3])
 redis.call("HSET", KEYS[1], "last_seen", ARGV[2])
 return 1
 `
@@ -100,12 +176,28 @@ return 1
 		lockScript:   redis.NewScript(lockLua),
 		unlockScript: redis.NewScript(unlockLua),
 		touchScript:  redis.NewScript(touchLua),
+		policy:       SessionPolicyReject,
+		maxSessions:  1,
 		opTimeout:    2 * time.Second,
 	}
 }
 
+func (s *TokenStore) SetSessionPolicy(policy SessionPolicy, maxConcurrent int, staleSeconds int) {
+	s.policy = policy
+	s.maxSessions = policy.Limit(maxConcurrent)
+	s.staleSeconds = staleSeconds
+}
+
 func redisTokenKey(token string) string { return "auth_token:" + token }
 
+func redisTokenSessionsKey(token string) string { return "auth_token_sessions:" + token }
+
+const redisLockedSessionsKey = "auth_token_locked"
+
+func redisTokenKeys(token string) []string {
+	return []string{redisTokenKey(token), redisTokenSessionsKey(token), redisLockedSessionsKey}
+}
+
 func newSessionID() (string, error) {
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
@@ -114,48 +206,109 @@ func newSessionID() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-func (s *TokenStore) LockWithSession(ctx context.Context, token string) (userID int64, sessionID string, ok bool, err error) {
+func (s *TokenStore) Issue(ctx context.Context, userID int64, ttl time.Duration) (string, error) {
+	if s == nil || s.rdb == nil {
+		return "", fmt.Errorf("token store misconfigured")
+	}
+	if userID <= 0 {
+		return "", fmt.Errorf("invalid user_id")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout)
+	defer cancel()
+
+	key := redisTokenKey(token)
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, key, "user_id", userID, "locked", 0)
+	if ttl > 0 {
+		pipe.Expire(ctx, key, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *TokenStore) UserID(ctx context.Context, token string) (int64, error) {
 	if s == nil || s.rdb == nil {
-		return 0, "", false, fmt.Errorf("token store misconfigured")
+		return 0, fmt.Errorf("token store misconfigured")
 	}
 	token = stringsTrim(token)
 	if token == "" {
-		return 0, "", false, ErrTokenEmpty
+		return 0, ErrTokenEmpty
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opTimeout)
+	defer cancel()
+
+	v, err := s.rdb.HGet(ctx, redisTokenKey(token), "user_id").Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrTokenNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	uid, err := toInt64(v)
+	if err != nil || uid <= 0 {
+		return 0, ErrTokenNotFound
+	}
+	return uid, nil
+}
+
+func (s *TokenStore) LockWithSession(ctx context.Context, token string) (userID int64, sessionID string, evicted []string, ok bool, err error) {
+	if s == nil || s.rdb == nil {
+		return 0, "", nil, false, fmt.Errorf("token store misconfigured")
+	}
+	token = stringsTrim(token)
+	if token == "" {
+		return 0, "", nil, false, ErrTokenEmpty
 	}
 
 	sid, err := newSessionID()
 	if err != nil {
-		return 0, "", false, err
+		return 0, "", nil, false, err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, s.opTimeout)
 	defer cancel()
 
 	now := time.Now().Unix()
-	key := redisTokenKey(token)
-
-	res, err := s.lockScript.Run(ctx, s.rdb, []string{key}, sid, now).Result()
+	res, err := runScript(ctx, "token_lock", s.lockScript, s.rdb, redisTokenKeys(token), sid, now, string(s.policy), s.maxSessions, s.staleSeconds, token)
 	if err != nil {
-		return 0, "", false, err
+		return 0, "", nil, false, err
 	}
 
 	arr, okArr := res.([]interface{})
-	if !okArr || len(arr) != 2 {
-		return 0, "", false, fmt.Errorf("unexpected lua result: %T %#v", res, res)
+	if !okArr || len(arr) < 2 {
+		return 0, "", nil, false, fmt.Errorf("unexpected lua result: %T %#v", res, res)
 	}
 
 	uid, err := toInt64(arr[0])
 	if err != nil || uid <= 0 {
-		return 0, "", false, ErrTokenNotFound
+		return 0, "", nil, false, ErrTokenNotFound
 	}
 	okInt, err := toInt64(arr[1])
 	if err != nil {
-		return uid, "", false, err
+		return uid, "", nil, false, err
 	}
-	if okInt == 1 {
-		return uid, sid, true, nil
+	if okInt != 1 {
+		return uid, "", nil, false, ErrTokenLocked
 	}
-	return uid, "", false, ErrTokenLocked
+
+	for _, v := range arr[2:] {
+		if old, isStr := v.(string); isStr {
+			evicted = append(evicted, old)
+		}
+	}
+	return uid, sid, evicted, true, nil
 }
 
 func (s *TokenStore) UnlockWithSession(ctx context.Context, token, sessionID string) error {
@@ -171,8 +324,7 @@ func (s *TokenStore) UnlockWithSession(ctx context.Context, token, sessionID str
 	ctx, cancel := context.WithTimeout(ctx, s.opTimeout)
 	defer cancel()
 
-	key := redisTokenKey(token)
-	_, err := s.unlockScript.Run(ctx, s.rdb, []string{key}, sessionID).Result()
+	_, err := runScript(ctx, "token_unlock", s.unlockScript, s.rdb, redisTokenKeys(token), sessionID, token)
 	return err
 }
 
@@ -190,8 +342,7 @@ func (s *TokenStore) Touch(ctx context.Context, token, sessionID string) error {
 	defer cancel()
 
 	now := time.Now().Unix()
-	key := redisTokenKey(token)
-	_, err := s.touchScript.Run(ctx, s.rdb, []string{key}, sessionID, now).Result()
+	_, err := runScript(ctx, "token_touch", s.touchScript, s.rdb, redisTokenKeys(token), sessionID, now, token)
 	return err
 }
 
@@ -206,58 +357,56 @@ func (s *TokenStore) CleanupStale(ctx context.Context, staleSeconds int) (int, e
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	now := time.Now().Unix()
-	var cursor uint64
+	if err := s.unlockScript.Load(ctx, s.rdb).Err(); err != nil {
+		return 0, err
+	}
+
+	const batch = 200
+	cutoff := time.Now().Unix() - int64(staleSeconds)
 	unlocked := 0
 
 	for {
-		keys, next, err := s.rdb.Scan(ctx, cursor, "auth_token:*", 200).Result()
+		members, err := s.rdb.ZRangeByScore(ctx, redisLockedSessionsKey, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(cutoff, 10),
+			Count: batch,
+		}).Result()
 		if err != nil {
 			return unlocked, err
 		}
+		if len(members) == 0 {
+			return unlocked, nil
+		}
 
-		for _, key := range keys {
-			vals, err := s.rdb.HMGet(ctx, key, "locked", "last_seen").Result()
-			if err != nil {
-				continue
-			}
-
-			lockedStr := fmt.Sprint(vals[0])
-			if lockedStr == "" || lockedStr == "<nil>" {
-				continue
-			}
-			lockedInt, _ := strconv.ParseInt(stringsTrim(lockedStr), 10, 64)
-			if lockedInt == 0 {
-				continue
-			}
-
-			lastSeenStr := fmt.Sprint(vals[1])
-			if lastSeenStr == "" || lastSeenStr == "<nil>" {
-				_, _ = s.rdb.HSet(ctx, key, "locked", 0).Result()
-				_, _ = s.rdb.HDel(ctx, key, "session_id", "last_seen").Result()
-				unlocked++
+		start := time.Now()
+		pipe := s.rdb.Pipeline()
+		cmds := make([]*redis.Cmd, 0, len(members))
+		for _, m := range members {
+			sid, token, ok := strings.Cut(m, ":")
+			if !ok {
+				pipe.ZRem(ctx, redisLockedSessionsKey, m)
 				continue
 			}
+			cmds = append(cmds, s.unlockScript.EvalSha(ctx, pipe, redisTokenKeys(token), sid, token, cutoff))
+		}
+		_, err = pipe.Exec(ctx)
+		metrics.ObserveScript("token_cleanup", start, err)
+		if err != nil {
+			return unlocked, err
+		}
 
-			lastSeen, err := strconv.ParseInt(stringsTrim(lastSeenStr), 10, 64)
+		for _, cmd := range cmds {
+			n, err := cmd.Int64()
 			if err != nil {
-				continue
-			}
-
-			if now-lastSeen >= int64(staleSeconds) {
-				_, _ = s.rdb.HSet(ctx, key, "locked", 0).Result()
-				_, _ = s.rdb.HDel(ctx, key, "session_id", "last_seen").Result()
-				unlocked++
+				return unlocked, err
 			}
+			unlocked += int(n)
 		}
 
-		cursor = next
-		if cursor == 0 {
-			break
+		if len(members) < batch {
+			return unlocked, nil
 		}
 	}
-
-	return unlocked, nil
 }
 
 func toInt64(v interface{}) (int64, error) {
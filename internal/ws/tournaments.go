@@ -0,0 +1,259 @@
+package ws
+
+import (
+	"CoinFlip/internal/game"
+	"CoinFlip/internal/logging"
+	"CoinFlip/internal/storage/postgres"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const leaderboardRetention = 7 * 24 * time.Hour
+
+func tournamentInfo(run postgres.TournamentRun) TournamentInfo {
+	return TournamentInfo{
+		RunID:        run.ID,
+		TournamentID: run.TournamentID,
+		Name:         run.Name,
+		Period:       run.Period,
+		Status:       run.Status,
+		StartsAt:     run.StartsAt,
+		EndsAt:       run.EndsAt,
+		PrizesTon:    run.PrizesTon,
+	}
+}
+
+func standingsFromResults(results []postgres.TournamentResult) []game.Standing {
+	out := make([]game.Standing, 0, len(results))
+	for _, r := range results {
+		st := game.Standing{
+			Rank:        r.Rank,
+			Points:      r.Points,
+			PrizeTon:    r.PrizeTon,
+			PrizeItemID: r.PrizeItemID,
+		}
+		st.TournamentScore = game.TournamentScore{
+			UserID:         r.UserID,
+			VolumeTon:      r.VolumeTon,
+			LongestSeries:  r.LongestSeries,
+			BestMultiplier: r.BestMultiplier,
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+func resultsFromStandings(standings []game.Standing) []postgres.TournamentResult {
+	out := make([]postgres.TournamentResult, 0, len(standings))
+	for _, st := range standings {
+		out = append(out, postgres.TournamentResult{
+			Rank:           st.Rank,
+			UserID:         st.UserID,
+			Points:         st.Points,
+			VolumeTon:      st.VolumeTon,
+			LongestSeries:  st.LongestSeries,
+			BestMultiplier: st.BestMultiplier,
+			PrizeTon:       st.PrizeTon,
+			PrizeItemID:    st.PrizeItemID,
+		})
+	}
+	return out
+}
+
+func (h *Handler) standings(ctx context.Context, run postgres.TournamentRun) ([]game.Standing, error) {
+	rows, err := h.TournamentsRepo.Scores(ctx, run.StartsAt, run.EndsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make([]game.TournamentScore, 0, len(rows))
+	for _, r := range rows {
+		scores = append(scores, game.TournamentScore{
+			UserID:         r.UserID,
+			VolumeTon:      r.VolumeTon,
+			LongestSeries:  r.LongestSeries,
+			BestMultiplier: r.BestMultiplier,
+		})
+	}
+	scoring := game.TournamentScoring{
+		VolumePoints:     run.VolumePoints,
+		SeriesPoints:     run.SeriesPoints,
+		MultiplierPoints: run.MultiplierPoints,
+	}
+	return game.RankStandings(scoring, run.PrizesTon, scores), nil
+}
+
+func (h *Handler) LiveStandings(runID int64) []postgres.TournamentResult {
+	if h.Tournaments == nil {
+		return nil
+	}
+	top, ok := h.Tournaments.Top(runID)
+	if !ok {
+		return nil
+	}
+	return resultsFromStandings(top)
+}
+
+func (h *Handler) RefreshTournaments(ctx context.Context, now time.Time) {
+	if h.Tournaments == nil || h.TournamentsRepo == nil || h.Leaderboard == nil {
+		return
+	}
+	if !h.muTournaments.TryLock() {
+		return
+	}
+	defer h.muTournaments.Unlock()
+
+	lg := logging.FromContext(ctx)
+
+	defs, err := h.TournamentsRepo.ListActive(ctx)
+	if err != nil {
+		lg.Error("tournaments: list failed", logging.KeyErr, err)
+		return
+	}
+	for _, t := range defs {
+		start, end, ok := game.TournamentWindow(t.Period, now)
+		if !ok {
+			continue
+		}
+		if err := h.TournamentsRepo.EnsureRun(ctx, t.ID, start, end); err != nil {
+			lg.Error("tournaments: ensure run failed", "tournament_id", t.ID, logging.KeyErr, err)
+		}
+	}
+
+	runs, err := h.TournamentsRepo.ListRuns(ctx, game.TournamentRunning, 100)
+	if err != nil {
+		lg.Error("tournaments: list runs failed", logging.KeyErr, err)
+		return
+	}
+
+	topN := h.Tournaments.Rules().TopN
+	for _, run := range runs {
+		if now.Before(run.StartsAt) {
+			continue
+		}
+		if now.After(run.EndsAt.Add(game.TournamentSettleGrace)) {
+			h.settleTournament(ctx, run)
+			continue
+		}
+
+		st, err := h.standings(ctx, run)
+		if err != nil {
+			lg.Error("tournaments: scores failed", "run_id", run.ID, logging.KeyErr, err)
+			continue
+		}
+		ttl := time.Until(run.EndsAt) + leaderboardRetention
+		if err := h.Leaderboard.Replace(ctx, run.ID, st, ttl); err != nil {
+			lg.Warn("tournaments: leaderboard update failed", "run_id", run.ID, logging.KeyErr, err)
+		}
+
+		top := st[:min(topN, len(st))]
+		if h.Tournaments.Changed(run.ID, top) {
+			h.Hub.BroadcastJSON(LeaderboardUpdate{
+				Event:      EventLeaderboard,
+				Tournament: tournamentInfo(run),
+				Top:        top,
+			})
+		}
+	}
+}
+
+func (h *Handler) settleTournament(ctx context.Context, run postgres.TournamentRun) {
+	lg := logging.FromContext(ctx).With("run_id", run.ID)
+
+	st, err := h.standings(ctx, run)
+	if err != nil {
+		lg.Error("tournaments: final scores failed", logging.KeyErr, err)
+		return
+	}
+	if err := h.Leaderboard.Replace(ctx, run.ID, st, leaderboardRetention); err != nil {
+		lg.Warn("tournaments: leaderboard update failed", logging.KeyErr, err)
+	}
+
+	results, err := h.TournamentsRepo.Settle(ctx, run.ID, resultsFromStandings(st))
+	if err != nil {
+		lg.Error("tournaments: settle failed", logging.KeyErr, err)
+		return
+	}
+	h.Tournaments.Forget(run.ID)
+	if results == nil {
+		return
+	}
+
+	final := standingsFromResults(results)
+	top := final[:min(h.Tournaments.Rules().TopN, len(final))]
+	lg.Info("tournaments: settled", "name", run.Name, "players", len(final))
+
+	run.Status = game.TournamentSettled
+	h.Hub.BroadcastJSON(LeaderboardUpdate{
+		Event:      EventTournamentEnd,
+		Tournament: tournamentInfo(run),
+		Top:        top,
+	})
+}
+
+func (h *Handler) tournamentStandings(ctx context.Context, conn *websocket.Conn, raw []byte) {
+	mlg := logging.FromContext(ctx)
+
+	var msg StandingsMsg
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		h.fail(ctx, conn, "bad tournament_standings json")
+		return
+	}
+
+	run, err := h.TournamentsRepo.GetRun(ctx, msg.RunID)
+	if errors.Is(err, postgres.ErrNotFound) {
+		h.fail(ctx, conn, "unknown tournament run")
+		return
+	}
+	if err != nil {
+		mlg.Error("ws: tournament run failed", logging.KeyErr, err)
+		h.fail(ctx, conn, "db error: tournament run")
+		return
+	}
+
+	topN := h.Tournaments.Rules().TopN
+	var top []game.Standing
+	if run.Status == game.TournamentSettled {
+		results, err := h.TournamentsRepo.Results(ctx, run.ID, topN)
+		if err != nil {
+			mlg.Error("ws: tournament results failed", logging.KeyErr, err)
+			h.fail(ctx, conn, "db error: tournament results")
+			return
+		}
+		top = standingsFromResults(results)
+	} else {
+		top, _ = h.Tournaments.Top(run.ID)
+	}
+	if top == nil {
+		top = []game.Standing{}
+	}
+
+	out := TournamentStandings{
+		Event:      EventStandings,
+		Tournament: tournamentInfo(*run),
+		Top:        top,
+	}
+
+	if userID := h.Hub.UserID(conn); userID != 0 {
+		for i := range top {
+			if top[i].UserID == userID {
+				out.Me = &top[i]
+				break
+			}
+		}
+		if out.Me == nil {
+			me, ok, err := h.Leaderboard.Rank(ctx, run.ID, userID)
+			if err != nil {
+				mlg.Warn("ws: tournament rank failed", logging.KeyErr, err)
+			} else if ok {
+				out.Me = &me
+			}
+		}
+	}
+
+	_ = h.Hub.SendJSON(conn, out)
+}
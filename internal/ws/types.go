@@ -1,17 +1,29 @@
 package ws
 
+import (
+	"CoinFlip/internal/game"
+	"time"
+)
+
 type FirstUpdate struct {
-	Event     Event       `json:"event"`
-	GamePhase string      `json:"game_phase"`
-	Timer     int         `json:"timer"`
-	GameID    int         `json:"game_id"`
-	Hash      string      `json:"hash"`
-	Bets      interface{} `json:"bets"`
+	Event     Event              `json:"event"`
+	TableID   int                `json:"table_id"`
+	GamePhase string             `json:"game_phase"`
+	Timer     int                `json:"timer"`
+	GameID    int                `json:"game_id"`
+	Hash      string             `json:"hash"`
+	Paused    bool               `json:"paused"`
+	Limits    game.Limits        `json:"limits"`
+	Tables    []game.TableInfo   `json:"tables"`
+	Pool      *game.PoolOdds     `json:"pool,omitempty"`
+	Jackpot   *game.JackpotState `json:"jackpot,omitempty"`
+	Bets      interface{}        `json:"bets"`
 }
 
 type LoginMsg struct {
 	ClientEvent ClientEvent `json:"client_event"`
 	Token       string      `json:"token"`
+	InitData    string      `json:"init_data"`
 }
 
 type Authorized struct {
@@ -19,6 +31,7 @@ type Authorized struct {
 	GameID int    `json:"game_id"`
 	Hash   string `json:"hash"`
 	Online int    `json:"online"`
+	Token  string `json:"token,omitempty"`
 }
 
 type OnlineMsg struct {
@@ -28,31 +41,39 @@ type OnlineMsg struct {
 
 type GameStarted struct {
 	Event       Event  `json:"event"`
+	TableID     int    `json:"table_id"`
 	GameID      int    `json:"game_id"`
 	Hash        string `json:"hash"`
 	BettingTime int    `json:"betting_time"`
 }
 
 type GettingResult struct {
-	Event          Event  `json:"event"`
-	GameID         int    `json:"game_id"`
-	Hash           string `json:"hash"`
-	TimeTillResult int    `json:"time_till_result"`
-	ResultSide     string `json:"result_side"`
+	Event          Event    `json:"event"`
+	TableID        int      `json:"table_id"`
+	GameID         int      `json:"game_id"`
+	Hash           string   `json:"hash"`
+	TimeTillResult int      `json:"time_till_result"`
+	ResultSide     string   `json:"result_side"`
+	Faces          []string `json:"faces,omitempty"`
+	Heads          *int     `json:"heads,omitempty"`
 }
 
 type GameFinished struct {
-	Event      Event  `json:"event"`
-	GameID     int    `json:"game_id"`
-	Hash       string `json:"hash"`
-	ResultSide string `json:"result_side"`
-	Seed       string `json:"seed"`
+	Event      Event    `json:"event"`
+	TableID    int      `json:"table_id"`
+	GameID     int      `json:"game_id"`
+	Hash       string   `json:"hash"`
+	ResultSide string   `json:"result_side"`
+	Faces      []string `json:"faces,omitempty"`
+	Heads      *int     `json:"heads,omitempty"`
+	Seed       string   `json:"seed"`
 }
 
 type NewGame struct {
-	Event  Event  `json:"event"`
-	GameID int    `json:"game_id"`
-	Hash   string `json:"hash"`
+	Event   Event  `json:"event"`
+	TableID int    `json:"table_id"`
+	GameID  int    `json:"game_id"`
+	Hash    string `json:"hash"`
 }
 
 type BetMsg struct {
@@ -63,11 +84,28 @@ type BetMsg struct {
 	BetItems    []BetItem   `json:"bet_items"`
 }
 
+type SubscribeMsg struct {
+	ClientEvent ClientEvent `json:"client_event"`
+	TableID     int         `json:"table_id"`
+}
+
 type SeriesContinueMsg struct {
 	ClientEvent ClientEvent `json:"client_event"`
 	Side        string      `json:"side"`
 }
 
+type DuelOpenMsg struct {
+	ClientEvent ClientEvent `json:"client_event"`
+	Side        string      `json:"side"`
+	BetItems    []BetItem   `json:"bet_items"`
+}
+
+type DuelMatchMsg struct {
+	ClientEvent ClientEvent `json:"client_event"`
+	DuelID      int64       `json:"duel_id"`
+	BetItems    []BetItem   `json:"bet_items"`
+}
+
 type BetItem struct {
 	Type   string `json:"type"`
 	ItemID string `json:"item_id"`
@@ -75,6 +113,7 @@ type BetItem struct {
 
 type BetsAccepted struct {
 	Event    Event  `json:"event"`
+	TableID  int    `json:"table_id"`
 	GameID   int    `json:"game_id"`
 	Hash     string `json:"hash"`
 	Accepted int    `json:"accepted"`
@@ -82,6 +121,7 @@ type BetsAccepted struct {
 
 type CashoutResult struct {
 	Event      Event   `json:"event"`
+	TableID    int     `json:"table_id"`
 	GameID     int     `json:"game_id"`
 	UserID     int64   `json:"user_id"`
 	Stake      float64 `json:"stake"`
@@ -90,22 +130,68 @@ type CashoutResult struct {
 }
 
 type NewBets struct {
-	Event  Event       `json:"event"`
-	GameID int         `json:"game_id"`
-	Hash   string      `json:"hash"`
-	UserID int64       `json:"user_id"`
-	Side   string      `json:"side"`
-	Mode   string      `json:"mode"`
-	Bets   interface{} `json:"bets"`
+	Event   Event          `json:"event"`
+	TableID int            `json:"table_id"`
+	GameID  int            `json:"game_id"`
+	Hash    string         `json:"hash"`
+	UserID  int64          `json:"user_id"`
+	Side    string         `json:"side"`
+	Mode    string         `json:"mode"`
+	Pool    *game.PoolOdds `json:"pool,omitempty"`
+	Bets    interface{}    `json:"bets"`
+}
+
+type BetCancelled struct {
+	Event   Event          `json:"event"`
+	TableID int            `json:"table_id"`
+	GameID  int            `json:"game_id"`
+	Hash    string         `json:"hash"`
+	UserID  int64          `json:"user_id"`
+	Stake   float64        `json:"stake"`
+	Items   int            `json:"items"`
+	Pool    *game.PoolOdds `json:"pool,omitempty"`
+	Bets    interface{}    `json:"bets"`
+}
+
+type PoolSettled struct {
+	Event      Event                           `json:"event"`
+	TableID    int                             `json:"table_id"`
+	GameID     int                             `json:"game_id"`
+	Hash       string                          `json:"hash"`
+	ResultSide string                          `json:"result_side"`
+	Refunded   bool                            `json:"refunded"`
+	Pool       game.PoolOdds                   `json:"pool"`
+	Results    map[int64]game.UserSingleResult `json:"results"`
+}
+
+type JackpotUpdate struct {
+	Event        Event   `json:"event"`
+	TableID      int     `json:"table_id"`
+	GameID       int     `json:"game_id"`
+	Contribution float64 `json:"contribution"`
+	Balance      float64 `json:"balance"`
+}
+
+type JackpotWon struct {
+	Event   Event                `json:"event"`
+	TableID int                  `json:"table_id"`
+	GameID  int                  `json:"game_id"`
+	Hash    string               `json:"hash"`
+	Seed    string               `json:"seed"`
+	Amount  float64              `json:"amount"`
+	Balance float64              `json:"balance"`
+	Winners []game.JackpotWinner `json:"winners"`
 }
 
 type ErrorMsg struct {
 	Event   Event  `json:"event"`
 	Message string `json:"error"`
+	Code    string `json:"code,omitempty"`
 }
 
 type SeriesUpdate struct {
 	Event      Event   `json:"event"`
+	TableID    int     `json:"table_id"`
 	GameID     int     `json:"game_id"`
 	UserID     int64   `json:"user_id"`
 	Side       string  `json:"side"`
@@ -120,6 +206,7 @@ type SeriesUpdate struct {
 
 type SeriesStateMsg struct {
 	Event      Event   `json:"event"`
+	TableID    int     `json:"table_id"`
 	UserID     int64   `json:"user_id"`
 	Side       string  `json:"side"`
 	Stake      float64 `json:"stake"`
@@ -129,3 +216,94 @@ type SeriesStateMsg struct {
 	Stage      string  `json:"stage"`
 	Active     bool    `json:"active"`
 }
+
+type GameControl struct {
+	Event   Event `json:"event"`
+	TableID int   `json:"table_id"`
+	GameID  int   `json:"game_id"`
+	Timer   int   `json:"timer"`
+}
+
+type ServerClosing struct {
+	Event         Event `json:"event"`
+	ReconnectInMs int   `json:"reconnect_in_ms"`
+}
+
+type RoundVoided struct {
+	Event      Event `json:"event"`
+	TableID    int   `json:"table_id"`
+	GameID     int   `json:"game_id"`
+	NextGameID int   `json:"next_game_id"`
+}
+
+type DuelLobby struct {
+	Event Event          `json:"event"`
+	Rules game.DuelRules `json:"rules"`
+	Duels []game.Duel    `json:"duels"`
+}
+
+type AutoplayStartMsg struct {
+	ClientEvent   ClientEvent `json:"client_event"`
+	Side          string      `json:"side"`
+	Mode          string      `json:"mode"`
+	Source        string      `json:"source"`
+	StakeTon      float64     `json:"stake_ton"`
+	BetItems      []BetItem   `json:"bet_items"`
+	Rounds        int         `json:"rounds"`
+	StopLossTon   float64     `json:"stop_loss_ton"`
+	TakeProfitTon float64     `json:"take_profit_ton"`
+}
+
+type AutoplayState struct {
+	Event   Event                 `json:"event"`
+	Active  bool                  `json:"active"`
+	Rules   game.AutoplayRules    `json:"rules"`
+	Session *game.AutoplaySession `json:"session,omitempty"`
+	Skipped string                `json:"skipped,omitempty"`
+}
+
+type StandingsMsg struct {
+	ClientEvent ClientEvent `json:"client_event"`
+	RunID       int64       `json:"run_id"`
+}
+
+type TournamentInfo struct {
+	RunID        int64     `json:"run_id"`
+	TournamentID int       `json:"tournament_id"`
+	Name         string    `json:"name"`
+	Period       string    `json:"period"`
+	Status       string    `json:"status"`
+	StartsAt     time.Time `json:"starts_at"`
+	EndsAt       time.Time `json:"ends_at"`
+	PrizesTon    []float64 `json:"prizes_ton"`
+}
+
+type LeaderboardUpdate struct {
+	Event      Event           `json:"event"`
+	Tournament TournamentInfo  `json:"tournament"`
+	Top        []game.Standing `json:"top"`
+}
+
+type TournamentStandings struct {
+	Event      Event           `json:"event"`
+	Tournament TournamentInfo  `json:"tournament"`
+	Top        []game.Standing `json:"top"`
+	Me         *game.Standing  `json:"me,omitempty"`
+}
+
+type ProfileStats struct {
+	Event          Event     `json:"event"`
+	UserID         int64     `json:"user_id"`
+	GamesCount     int       `json:"games_count"`
+	WinCount       int       `json:"win_count"`
+	BetsSum        float64   `json:"bets_sum"`
+	WinSum         float64   `json:"win_sum"`
+	LastActiveAt   time.Time `json:"last_active_at"`
+	LongestSeries  int       `json:"longest_series"`
+	BestMultiplier float64   `json:"best_multiplier"`
+}
+
+type DuelUpdate struct {
+	Event Event     `json:"event"`
+	Duel  game.Duel `json:"duel"`
+}
@@ -0,0 +1,16 @@
+ALTER TABLE twist_business.game_rounds
+    ADD COLUMN IF NOT EXISTS voided_at TIMESTAMPTZ NULL;
+
+ALTER TABLE twist_business.series_sessions
+    DROP CONSTRAINT IF EXISTS series_sessions_stage_check;
+
+ALTER TABLE twist_business.series_sessions
+    ADD CONSTRAINT series_sessions_stage_check
+    CHECK (stage IN ('in_round', 'awaiting_choice', 'cashed_out', 'lost', 'voided'));
+
+ALTER TABLE twist_business.series_steps
+    DROP CONSTRAINT IF EXISTS series_steps_event_check;
+
+ALTER TABLE twist_business.series_steps
+    ADD CONSTRAINT series_steps_event_check
+    CHECK (event IN ('win', 'lose', 'cashout', 'void'));
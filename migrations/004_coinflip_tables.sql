@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_tables (
+    table_id                SERIAL PRIMARY KEY,
+    table_name              TEXT NOT NULL UNIQUE,
+    active                  BOOLEAN NOT NULL DEFAULT TRUE,
+    betting_time            INT NULL CHECK (betting_time > 0),
+    time_till_result        INT NULL CHECK (time_till_result > 0),
+    next_game_delay         INT NULL CHECK (next_game_delay > 0),
+    single_multiplier       NUMERIC(10,4) NULL CHECK (single_multiplier > 1),
+    series_first_multiplier NUMERIC(10,4) NULL CHECK (series_first_multiplier > 1),
+    series_step_multiplier  NUMERIC(10,4) NULL CHECK (series_step_multiplier > 1),
+    min_stake_ton           NUMERIC(20,8) NULL CHECK (min_stake_ton > 0),
+    max_stake_ton           NUMERIC(20,8) NULL CHECK (max_stake_ton >= 0),
+    max_bet_items           INT NULL CHECK (max_bet_items > 0),
+    user_round_cap_ton      NUMERIC(20,8) NULL CHECK (user_round_cap_ton >= 0),
+    max_round_liability_ton NUMERIC(20,8) NULL CHECK (max_round_liability_ton >= 0),
+    item_types              TEXT[] NULL,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- NULL columns inherit the server config. Table 1 carries the rounds played
+-- before tables existed.
+INSERT INTO twist_business.coinflip_tables (table_id, table_name)
+VALUES (1, 'main')
+ON CONFLICT (table_id) DO NOTHING;
+
+SELECT setval(
+    pg_get_serial_sequence('twist_business.coinflip_tables', 'table_id'),
+    GREATEST((SELECT MAX(table_id) FROM twist_business.coinflip_tables), 1)
+);
+
+ALTER TABLE twist_business.game_rounds
+    ADD COLUMN IF NOT EXISTS table_id INT NOT NULL DEFAULT 1
+    REFERENCES twist_business.coinflip_tables(table_id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS ix_game_rounds_table_game
+    ON twist_business.game_rounds(table_id, game_id DESC);
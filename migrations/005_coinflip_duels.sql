@@ -0,0 +1,69 @@
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_duels (
+    duel_id        BIGINT PRIMARY KEY,
+    status         TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'expired')),
+    hash           TEXT NOT NULL,
+    seed           TEXT NOT NULL,
+    creator_id     BIGINT NOT NULL,
+    creator_side   TEXT NOT NULL CHECK (creator_side IN ('heads', 'tails')),
+    creator_stake  NUMERIC(20,8) NOT NULL CHECK (creator_stake > 0),
+    opponent_id    BIGINT NULL,
+    opponent_stake NUMERIC(20,8) NULL,
+    result_side    TEXT NULL CHECK (result_side IN ('heads', 'tails')),
+    winner_id      BIGINT NULL,
+    fee_mode       TEXT NULL CHECK (fee_mode IN ('ton', 'item')),
+    fee_ton        NUMERIC(20,8) NOT NULL DEFAULT 0,
+    fee_item_id    BIGINT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at     TIMESTAMPTZ NOT NULL,
+    settled_at     TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS ix_coinflip_duels_open
+    ON twist_business.coinflip_duels(expires_at)
+    WHERE status = 'open';
+
+CREATE INDEX IF NOT EXISTS ix_coinflip_duels_creator
+    ON twist_business.coinflip_duels(creator_id);
+
+CREATE INDEX IF NOT EXISTS ix_coinflip_duels_opponent
+    ON twist_business.coinflip_duels(opponent_id);
+
+-- One row per staked item, mirroring game_bets. payout_ton is the value the
+-- winner receives through the item; the house fee item pays 0.
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_duel_items (
+    id             BIGSERIAL PRIMARY KEY,
+    duel_id        BIGINT NOT NULL REFERENCES twist_business.coinflip_duels(duel_id) ON DELETE CASCADE,
+    user_id        BIGINT NOT NULL,
+    side           TEXT NOT NULL CHECK (side IN ('heads', 'tails')),
+    item_id        BIGINT NOT NULL,
+    item_type      TEXT NOT NULL,
+    item_name      TEXT NOT NULL,
+    item_photo_url TEXT NULL,
+    stake_ton      NUMERIC(20,8) NOT NULL CHECK (stake_ton >= 0),
+    status         TEXT NOT NULL DEFAULT 'accepted'
+                   CHECK (status IN ('accepted', 'duel_win', 'duel_lose', 'duel_fee', 'expired')),
+    payout_ton     NUMERIC(20,8) NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    settled_at     TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS ix_coinflip_duel_items_duel_id
+    ON twist_business.coinflip_duel_items(duel_id);
+
+CREATE INDEX IF NOT EXISTS ix_coinflip_duel_items_user_id
+    ON twist_business.coinflip_duel_items(user_id);
+
+ALTER TABLE twist_business.wallet_transactions
+    ADD COLUMN IF NOT EXISTS duel_id BIGINT NULL
+    REFERENCES twist_business.coinflip_duels(duel_id) ON DELETE SET NULL;
+
+ALTER TABLE twist_business.wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check;
+
+ALTER TABLE twist_business.wallet_transactions
+    ADD CONSTRAINT wallet_transactions_kind_check
+    CHECK (kind IN ('single_win', 'series_cashout', 'duel_fee'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_wallet_tx_duel_fee
+    ON twist_business.wallet_transactions(duel_id, kind)
+    WHERE kind = 'duel_fee';
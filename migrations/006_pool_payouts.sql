@@ -0,0 +1,41 @@
+ALTER TABLE twist_business.game_bets
+    DROP CONSTRAINT IF EXISTS game_bets_mode_check;
+
+ALTER TABLE twist_business.game_bets
+    ADD CONSTRAINT game_bets_mode_check
+    CHECK (mode IN ('single', 'series', 'pool'));
+
+ALTER TABLE twist_business.game_bets
+    DROP CONSTRAINT IF EXISTS game_bets_status_check;
+
+ALTER TABLE twist_business.game_bets
+    ADD CONSTRAINT game_bets_status_check
+    CHECK (status IN (
+        'accepted',
+        'single_win',
+        'single_lose',
+        'series_awaiting_choice',
+        'series_lost',
+        'series_cashed_out',
+        'cancelled',
+        'pool_win',
+        'pool_lose',
+        'pool_refund'
+    ));
+
+ALTER TABLE twist_business.wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check;
+
+ALTER TABLE twist_business.wallet_transactions
+    ADD CONSTRAINT wallet_transactions_kind_check
+    CHECK (kind IN ('single_win', 'series_cashout', 'duel_fee', 'pool_win'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_wallet_tx_pool_win
+    ON twist_business.wallet_transactions(user_id, game_id, kind)
+    WHERE kind = 'pool_win';
+
+-- NULL inherits payout_mode / pool_rake_percent from the server config.
+ALTER TABLE twist_business.coinflip_tables
+    ADD COLUMN IF NOT EXISTS payout_mode TEXT NULL CHECK (payout_mode IN ('fixed', 'pool')),
+    ADD COLUMN IF NOT EXISTS pool_rake_percent NUMERIC(6,3) NULL
+        CHECK (pool_rake_percent >= 0 AND pool_rake_percent < 100);
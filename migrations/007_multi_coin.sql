@@ -0,0 +1,59 @@
+ALTER TABLE twist_business.game_rounds
+    ADD COLUMN IF NOT EXISTS coin_count INT NOT NULL DEFAULT 1 CHECK (coin_count BETWEEN 1 AND 16),
+    ADD COLUMN IF NOT EXISTS coin_faces TEXT[] NULL
+        CHECK (coin_faces <@ ARRAY['heads', 'tails']::text[]),
+    ADD COLUMN IF NOT EXISTS heads_count INT NULL CHECK (heads_count >= 0);
+
+-- Multi-coin bets keep their target in side: exact:N, range:A-B or all_same.
+ALTER TABLE twist_business.game_bets
+    DROP CONSTRAINT IF EXISTS game_bets_side_check;
+
+ALTER TABLE twist_business.game_bets
+    ADD CONSTRAINT game_bets_side_check
+    CHECK (
+        side IN ('heads', 'tails')
+        OR (mode = 'multi' AND side ~ '^(exact:[0-9]+|range:[0-9]+-[0-9]+|all_same)$')
+    );
+
+ALTER TABLE twist_business.game_bets
+    DROP CONSTRAINT IF EXISTS game_bets_mode_check;
+
+ALTER TABLE twist_business.game_bets
+    ADD CONSTRAINT game_bets_mode_check
+    CHECK (mode IN ('single', 'series', 'pool', 'multi'));
+
+ALTER TABLE twist_business.game_bets
+    DROP CONSTRAINT IF EXISTS game_bets_status_check;
+
+ALTER TABLE twist_business.game_bets
+    ADD CONSTRAINT game_bets_status_check
+    CHECK (status IN (
+        'accepted',
+        'single_win',
+        'single_lose',
+        'series_awaiting_choice',
+        'series_lost',
+        'series_cashed_out',
+        'cancelled',
+        'pool_win',
+        'pool_lose',
+        'pool_refund',
+        'multi_win',
+        'multi_lose'
+    ));
+
+ALTER TABLE twist_business.wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check;
+
+ALTER TABLE twist_business.wallet_transactions
+    ADD CONSTRAINT wallet_transactions_kind_check
+    CHECK (kind IN ('single_win', 'series_cashout', 'duel_fee', 'pool_win', 'multi_win'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_wallet_tx_multi_win
+    ON twist_business.wallet_transactions(user_id, game_id, kind)
+    WHERE kind = 'multi_win';
+
+ALTER TABLE twist_business.coinflip_tables
+    ADD COLUMN IF NOT EXISTS coin_count INT NULL CHECK (coin_count BETWEEN 1 AND 16),
+    ADD COLUMN IF NOT EXISTS coin_edge_percent NUMERIC(6,3) NULL
+        CHECK (coin_edge_percent >= 0 AND coin_edge_percent < 50);
@@ -0,0 +1,48 @@
+-- Single-row progressive jackpot shared by every table.
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_jackpot (
+    id          INT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    balance_ton NUMERIC(20,8) NOT NULL DEFAULT 0 CHECK (balance_ton >= 0),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO twist_business.coinflip_jackpot (id, balance_ton)
+VALUES (1, 0)
+ON CONFLICT (id) DO NOTHING;
+
+-- Every balance movement; one contribution and at most one payout per round.
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_jackpot_ledger (
+    id          BIGSERIAL PRIMARY KEY,
+    game_id     BIGINT NOT NULL REFERENCES twist_business.game_rounds(game_id) ON DELETE CASCADE,
+    table_id    INT NOT NULL,
+    kind        TEXT NOT NULL CHECK (kind IN ('contribution', 'payout')),
+    amount_ton  NUMERIC(20,8) NOT NULL CHECK (amount_ton > 0),
+    balance_ton NUMERIC(20,8) NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (game_id, kind)
+);
+
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_jackpot_wins (
+    id         BIGSERIAL PRIMARY KEY,
+    game_id    BIGINT NOT NULL REFERENCES twist_business.game_rounds(game_id) ON DELETE CASCADE,
+    table_id   INT NOT NULL,
+    user_id    BIGINT NOT NULL REFERENCES twist_business.users(user_id) ON DELETE CASCADE,
+    trigger    TEXT NOT NULL CHECK (trigger IN ('seed', 'series')),
+    stake_ton  NUMERIC(20,8) NOT NULL CHECK (stake_ton >= 0),
+    amount_ton NUMERIC(20,8) NOT NULL CHECK (amount_ton > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (game_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS ix_coinflip_jackpot_wins_user
+    ON twist_business.coinflip_jackpot_wins(user_id);
+
+ALTER TABLE twist_business.wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check;
+
+ALTER TABLE twist_business.wallet_transactions
+    ADD CONSTRAINT wallet_transactions_kind_check
+    CHECK (kind IN ('single_win', 'series_cashout', 'duel_fee', 'pool_win', 'multi_win', 'jackpot_win'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_wallet_tx_jackpot_win
+    ON twist_business.wallet_transactions(user_id, game_id, kind)
+    WHERE kind = 'jackpot_win';
@@ -0,0 +1,50 @@
+-- The coin may land on its edge; edge_odds records the 1-in-N chance the
+-- round was resolved with so the result can be re-derived from the seed.
+ALTER TABLE twist_business.game_rounds
+    DROP CONSTRAINT IF EXISTS game_rounds_result_side_check;
+
+ALTER TABLE twist_business.game_rounds
+    ADD CONSTRAINT game_rounds_result_side_check
+    CHECK (result_side IN ('heads', 'tails', 'edge'));
+
+ALTER TABLE twist_business.game_rounds
+    ADD COLUMN IF NOT EXISTS edge_odds INT NULL CHECK (edge_odds > 0);
+
+ALTER TABLE twist_business.game_bets
+    DROP CONSTRAINT IF EXISTS game_bets_side_check;
+
+ALTER TABLE twist_business.game_bets
+    ADD CONSTRAINT game_bets_side_check
+    CHECK (
+        side IN ('heads', 'tails')
+        OR (mode = 'single' AND side = 'edge')
+        OR (mode = 'multi' AND side ~ '^(exact:[0-9]+|range:[0-9]+-[0-9]+|all_same)$')
+    );
+
+ALTER TABLE twist_business.game_bets
+    DROP CONSTRAINT IF EXISTS game_bets_status_check;
+
+ALTER TABLE twist_business.game_bets
+    ADD CONSTRAINT game_bets_status_check
+    CHECK (status IN (
+        'accepted',
+        'single_win',
+        'single_lose',
+        'single_refund',
+        'series_awaiting_choice',
+        'series_lost',
+        'series_cashed_out',
+        'cancelled',
+        'pool_win',
+        'pool_lose',
+        'pool_refund',
+        'multi_win',
+        'multi_lose'
+    ));
+
+ALTER TABLE twist_business.series_steps
+    DROP CONSTRAINT IF EXISTS series_steps_event_check;
+
+ALTER TABLE twist_business.series_steps
+    ADD CONSTRAINT series_steps_event_check
+    CHECK (event IN ('win', 'lose', 'cashout', 'void', 'push'));
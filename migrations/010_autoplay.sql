@@ -0,0 +1,76 @@
+-- Autoplay: the server repeats a bet for the user at every betting phase until
+-- the planned rounds are played or a stop condition fires. At most one active
+-- session per user.
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_autoplay_sessions (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT NOT NULL REFERENCES twist_business.users(user_id) ON DELETE CASCADE,
+    table_id        INT NOT NULL,
+    side            TEXT NOT NULL,
+    mode            TEXT NOT NULL CHECK (mode IN ('single', 'pool', 'multi')),
+    source          TEXT NOT NULL CHECK (source IN ('wallet', 'items')),
+    stake_ton       NUMERIC(20,8) NOT NULL CHECK (stake_ton >= 0),
+    item_ids        BIGINT[] NOT NULL DEFAULT '{}',
+    rounds          INT NOT NULL CHECK (rounds > 0),
+    stop_loss_ton   NUMERIC(20,8) NOT NULL DEFAULT 0 CHECK (stop_loss_ton >= 0),
+    take_profit_ton NUMERIC(20,8) NOT NULL DEFAULT 0 CHECK (take_profit_ton >= 0),
+    rounds_played   INT NOT NULL DEFAULT 0,
+    wagered_ton     NUMERIC(20,8) NOT NULL DEFAULT 0,
+    net_ton         NUMERIC(20,8) NOT NULL DEFAULT 0,
+    settled_game_id BIGINT NULL,
+    status          TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'stopped', 'finished')),
+    stop_reason     TEXT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    stopped_at      TIMESTAMPTZ NULL,
+    CHECK (source = 'items' OR stake_ton > 0),
+    CHECK (source = 'wallet' OR cardinality(item_ids) > 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_coinflip_autoplay_active_user
+    ON twist_business.coinflip_autoplay_sessions(user_id)
+    WHERE status = 'active';
+
+-- Wallet-funded bets carry no item; item_type 'wallet' marks them.
+ALTER TABLE twist_business.game_bets
+    ADD COLUMN IF NOT EXISTS autoplay_session_id BIGINT NULL
+    REFERENCES twist_business.coinflip_autoplay_sessions(id) ON DELETE SET NULL;
+
+ALTER TABLE twist_business.game_bets
+    ALTER COLUMN item_id DROP NOT NULL;
+
+ALTER TABLE twist_business.game_bets
+    DROP CONSTRAINT IF EXISTS game_bets_wallet_item_check;
+
+ALTER TABLE twist_business.game_bets
+    ADD CONSTRAINT game_bets_wallet_item_check
+    CHECK ((item_id IS NULL) = (item_type = 'wallet'));
+
+CREATE INDEX IF NOT EXISTS ix_game_bets_autoplay_session_id
+    ON twist_business.game_bets(autoplay_session_id)
+    WHERE autoplay_session_id IS NOT NULL;
+
+-- bet_stake debits the wallet when a wallet bet is accepted; bet_refund
+-- returns it when that bet is cancelled, voided or pushed.
+ALTER TABLE twist_business.wallet_transactions
+    ADD COLUMN IF NOT EXISTS bet_id BIGINT NULL
+    REFERENCES twist_business.game_bets(id) ON DELETE SET NULL;
+
+ALTER TABLE twist_business.wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check;
+
+ALTER TABLE twist_business.wallet_transactions
+    ADD CONSTRAINT wallet_transactions_kind_check
+    CHECK (kind IN (
+        'single_win',
+        'series_cashout',
+        'duel_fee',
+        'pool_win',
+        'multi_win',
+        'jackpot_win',
+        'bet_stake',
+        'bet_refund'
+    ));
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_wallet_tx_bet
+    ON twist_business.wallet_transactions(bet_id, kind)
+    WHERE kind IN ('bet_stake', 'bet_refund');
@@ -0,0 +1,90 @@
+-- Tournament definitions. Points = volume_points per TON wagered
+-- + series_points per win of the longest series
+-- + multiplier_points per x of the best series cashout multiplier.
+-- prizes_ton[i] is paid to rank i; the top item_prize_ranks ranks also get one
+-- item each from prize_item_ids, which must be locked and owned by
+-- prize_owner_id (the house account) to be handed out.
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_tournaments (
+    tournament_id     SERIAL PRIMARY KEY,
+    name              TEXT NOT NULL UNIQUE,
+    period            TEXT NOT NULL CHECK (period IN ('daily', 'weekly')),
+    active            BOOLEAN NOT NULL DEFAULT TRUE,
+    volume_points     NUMERIC(12,4) NOT NULL DEFAULT 1 CHECK (volume_points >= 0),
+    series_points     NUMERIC(12,4) NOT NULL DEFAULT 0 CHECK (series_points >= 0),
+    multiplier_points NUMERIC(12,4) NOT NULL DEFAULT 0 CHECK (multiplier_points >= 0),
+    prizes_ton        NUMERIC(20,8)[] NOT NULL DEFAULT '{}',
+    item_prize_ranks  INT NOT NULL DEFAULT 0 CHECK (item_prize_ranks >= 0),
+    prize_owner_id    BIGINT NULL,
+    prize_item_ids    BIGINT[] NOT NULL DEFAULT '{}',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO twist_business.coinflip_tournaments (name, period, volume_points, series_points, multiplier_points, prizes_ton)
+VALUES
+    ('daily', 'daily', 1, 10, 5, '{10, 5, 2}'),
+    ('weekly', 'weekly', 1, 10, 5, '{50, 25, 10}')
+ON CONFLICT (name) DO NOTHING;
+
+-- One run per tournament and period window.
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_tournament_runs (
+    id            BIGSERIAL PRIMARY KEY,
+    tournament_id INT NOT NULL REFERENCES twist_business.coinflip_tournaments(tournament_id) ON DELETE CASCADE,
+    starts_at     TIMESTAMPTZ NOT NULL,
+    ends_at       TIMESTAMPTZ NOT NULL CHECK (ends_at > starts_at),
+    status        TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'settled')),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    settled_at    TIMESTAMPTZ NULL,
+    UNIQUE (tournament_id, starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS ix_coinflip_tournament_runs_running
+    ON twist_business.coinflip_tournament_runs(ends_at)
+    WHERE status = 'running';
+
+-- Final standings, written once when the run settles.
+CREATE TABLE IF NOT EXISTS twist_business.coinflip_tournament_results (
+    id              BIGSERIAL PRIMARY KEY,
+    run_id          BIGINT NOT NULL REFERENCES twist_business.coinflip_tournament_runs(id) ON DELETE CASCADE,
+    rank            INT NOT NULL CHECK (rank > 0),
+    user_id         BIGINT NOT NULL REFERENCES twist_business.users(user_id) ON DELETE CASCADE,
+    points          NUMERIC(20,8) NOT NULL,
+    volume_ton      NUMERIC(20,8) NOT NULL DEFAULT 0,
+    longest_series  INT NOT NULL DEFAULT 0,
+    best_multiplier NUMERIC(20,8) NOT NULL DEFAULT 0,
+    prize_ton       NUMERIC(20,8) NOT NULL DEFAULT 0,
+    prize_item_id   BIGINT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (run_id, rank),
+    UNIQUE (run_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS ix_game_bets_created_at
+    ON twist_business.game_bets(created_at);
+
+CREATE INDEX IF NOT EXISTS ix_series_steps_created_at
+    ON twist_business.series_steps(created_at);
+
+ALTER TABLE twist_business.wallet_transactions
+    ADD COLUMN IF NOT EXISTS tournament_run_id BIGINT NULL
+    REFERENCES twist_business.coinflip_tournament_runs(id) ON DELETE SET NULL;
+
+ALTER TABLE twist_business.wallet_transactions
+    DROP CONSTRAINT IF EXISTS wallet_transactions_kind_check;
+
+ALTER TABLE twist_business.wallet_transactions
+    ADD CONSTRAINT wallet_transactions_kind_check
+    CHECK (kind IN (
+        'single_win',
+        'series_cashout',
+        'duel_fee',
+        'pool_win',
+        'multi_win',
+        'jackpot_win',
+        'bet_stake',
+        'bet_refund',
+        'tournament_prize'
+    ));
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_wallet_tx_tournament_prize
+    ON twist_business.wallet_transactions(tournament_run_id, user_id, kind)
+    WHERE kind = 'tournament_prize';
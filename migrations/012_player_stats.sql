@@ -0,0 +1,23 @@
+-- A bet is counted into the users.games_count / win_count / bets_sum /
+-- win_sum counters exactly once, when it reaches a final outcome. Refunded,
+-- cancelled and still-open series bets are never counted. Bets settled before
+-- this migration are picked up by the first sweep.
+ALTER TABLE twist_business.game_bets
+    ADD COLUMN IF NOT EXISTS stats_counted_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS ix_game_bets_stats_pending
+    ON twist_business.game_bets(id)
+    WHERE stats_counted_at IS NULL
+      AND status IN (
+          'single_win',
+          'single_lose',
+          'pool_win',
+          'pool_lose',
+          'multi_win',
+          'multi_lose',
+          'series_lost',
+          'series_cashed_out'
+      );
+
+CREATE INDEX IF NOT EXISTS ix_series_sessions_user_id
+    ON twist_business.series_sessions(user_id);